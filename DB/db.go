@@ -17,15 +17,31 @@
 
    Each layer here is completey independent of the implementation of another layer
 
+   Clients speaking the Redis protocol are served by the RESP front-end of the
+   transport layer which works on the observer layer without a translation layer
+
+   RapidoDB can either be run as a TCP server using New and Run or be embedded
+   into a Go program using Open, in which case serving over TCP is optional
+
 */
 
 package db
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
 	"net"
+	"sync"
+	"time"
 
+	"github.com/utkarsh-pro/RapidoDB/audit"
+	"github.com/utkarsh-pro/RapidoDB/config"
+	"github.com/utkarsh-pro/RapidoDB/eventbus"
 	"github.com/utkarsh-pro/RapidoDB/manage"
+	"github.com/utkarsh-pro/RapidoDB/observer"
+	"github.com/utkarsh-pro/RapidoDB/rql"
 	"github.com/utkarsh-pro/RapidoDB/store"
 )
 
@@ -50,75 +66,306 @@ type RapidoDB struct {
 	// PORT on which the server should run
 	PORT string
 
+	// addr is the address on which the server listens
+	addr string
+
+	// respAddr is the address on which the server speaks
+	// the Redis protocol, it is empty if RESP is disabled
+	respAddr string
+
+	// maxClients is the maximum number of connected
+	// clients, zero means unlimited
+	maxClients int
+
+	// maxRequestSize is the maximum size in
+	// bytes of a request of any protocol
+	maxRequestSize int
+
 	// Store that the RapidoDB will be using internally
 	store *store.Store
 
 	// Store that RapidoDB uses to store the DB users info
 	usersStore *store.Store
+
+	// sdb is the client manager layer shared by all the clients
+	sdb *manage.SecureDB
+
+	// local is the observed database used by the embedding
+	// application, it acts with admin privileges
+	local *observer.ObservedDB
+
+	// driver executes the RQL queries of the embedding application
+	driver *rql.Driver
+
+	// settings exposes the configuration to the admins
+	settings *runtimeSettings
+
+	// auditLog is the audit log opened from the configuration
+	// it is nil if auditing is disabled or left to the embedder
+	auditLog *audit.Logger
+
+	// tls encrypts the connections, it is nil if TLS is disabled
+	tls *tlsConfig
+
+	// mu guards the listeners, the clients and the shutdown flag
+	mu sync.Mutex
+
+	// listeners are the listeners of the running TCP servers
+	listeners []net.Listener
+
+	// clients holds the transport of every connected client
+	clients map[client]struct{}
+
+	// handlers keeps track of the running client handlers
+	handlers sync.WaitGroup
+
+	// shuttingDown is set once Shutdown has been called
+	shuttingDown bool
+}
+
+// client is the transport of a connected client of any of the front-ends
+type client interface {
+	Close(notice string) error
+	SetMaxRequestSize(n int)
 }
 
+// shutdownNotice is sent to the clients when the server shuts down
+const shutdownNotice = "Server is shutting down"
+
+// tooManyClientsNotice is sent to the clients which connect
+// while the maximum number of clients are already connected
+const tooManyClientsNotice = "ERR: Too many clients connected"
+
 // New returns an instance of the Server object
 func New(log *log.Logger, PORT, username, password, bckpath string) *RapidoDB {
-	// Create a new store for the database
-	storage := prepareStorageLayer(log, bckpath+"/rapido.db")
+	s, err := Open(Options{
+		Dir:      bckpath,
+		Username: username,
+		Password: password,
+		Log:      log,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open the database: %s", err)
+	}
+
+	s.PORT = PORT
+	s.addr = ":" + PORT
+	return s
+}
 
-	// Create a new store for the users
-	usersDB := store.New(store.NeverExpire, log, bckpath+"/rapido_user.db")
+// NewFromConfig returns an instance of the Server object
+// set up according to the configuration. path is the file the
+// configuration was loaded from, it is where CONFIG REWRITE
+// writes the configuration and can be empty
+func NewFromConfig(log *log.Logger, cfg config.Config, path string) (*RapidoDB, error) {
+	users := make([]User, 0, len(cfg.Users))
+	for _, u := range cfg.Users {
+		users = append(users, User{u.Username, u.Password, u.Access})
+	}
 
-	usersDB.Set(username,
-		manage.NewDBUser(username, password, manage.AdminAccess, manage.Events{}), usersDB.DefaultExpiry(),
-	)
+	var auditLog *audit.Logger
+	if cfg.AuditFile != "" {
+		var err error
+		auditLog, err = audit.Open(audit.Options{
+			Path:       cfg.AuditFile,
+			MaxSize:    int64(cfg.AuditMaxSizeMB) << 20,
+			MaxBackups: cfg.AuditMaxBackups,
+			Reads:      cfg.AuditReads,
+			Log:        log,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open the audit log: %w", err)
+		}
+	}
 
-	return &RapidoDB{log, PORT, storage, usersDB}
+	opts := Options{
+		Dir:              cfg.DataDir,
+		DefaultExpiry:    time.Duration(cfg.DefaultTTL),
+		JanitorInterval:  time.Duration(cfg.JanitorInterval),
+		PersistInterval:  time.Duration(cfg.PersistInterval),
+		FsyncPolicy:      cfg.FsyncPolicy(),
+		MaxClients:       cfg.MaxClients,
+		MaxRequestSizeMB: cfg.MaxRequestSizeMB,
+		Username:         cfg.Admin.Username,
+		Password:         cfg.Admin.Password,
+		Users:            users,
+		TLS:              tlsOptions(cfg),
+		Log:              log,
+	}
+
+	// A nil *audit.Logger must not end up in the interface
+	if auditLog != nil {
+		opts.Auditor = auditLog
+	}
+
+	s, err := Open(opts)
+	if err != nil {
+		if auditLog != nil {
+			auditLog.Close()
+		}
+		return nil, err
+	}
+
+	s.settings.cfg, s.settings.path = cfg, path
+	s.auditLog = auditLog
+	s.sdb.SetLockoutPolicy(lockoutPolicy(cfg))
+
+	_, s.PORT, _ = net.SplitHostPort(cfg.Listen)
+	s.addr = cfg.Listen
+	s.respAddr = cfg.RESPListen
+	return s, nil
 }
 
 // Run method starts the TCP server and sets up the TCP client handlers
+// along with the RESP server if it is enabled. It returns once the
+// server has been shut down
 func (s *RapidoDB) Run() {
-	listener := s.setupTCPServer()
-	defer listener.Close()
+	if s.respAddr != "" {
+		go s.ServeRESP(s.setupTCPServer(s.respAddr))
+	}
+
+	if err := s.Serve(s.setupTCPServer(s.addr)); err != ErrServerClosed {
+		s.log.Println("Stopped serving: ", err.Error())
+	}
+}
+
+// Shutdown gracefully shuts down the server. It stops accepting new
+// connections, waits for the commands being executed to finish, closes
+// the client connections with a notice and finally stops the stores
+// which writes a final snapshot of the data and the users onto the disk
+//
+// If the context expires before the clients are closed then the error
+// of the context is returned right away, the stores are only stopped
+// once the remaining handlers have returned
+func (s *RapidoDB) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	clients := make([]client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
 
-	s.setupTCPClientHandler(listener)
+	s.log.Println("Shutting down the server")
+
+	// Close the clients, each close waits for the
+	// command being executed by the client to finish
+	done := make(chan struct{})
+	go func() {
+		for _, c := range clients {
+			c.Close(shutdownNotice)
+		}
+		s.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return s.stop()
+	case <-ctx.Done():
+		// The handlers still running would act on closed stores
+		go func() {
+			<-done
+			s.stop()
+		}()
+		return ctx.Err()
+	}
 }
 
-// setupTCPServer starts a TCP server and returns the listener
-func (s *RapidoDB) setupTCPServer() net.Listener {
-	listener, err := net.Listen("tcp", ":"+s.PORT)
+// stop stops the stores and closes the audit log, it must only
+// be called once every client handler has returned
+func (s *RapidoDB) stop() error {
+	var err error
+
+	if serr := s.store.Close(); serr != nil {
+		s.log.Println("Failed to stop the data store:", serr)
+		err = firstErr(err, serr)
+	}
+
+	if serr := s.usersStore.Close(); serr != nil {
+		s.log.Println("Failed to stop the users store:", serr)
+		err = firstErr(err, serr)
+	}
+
+	if s.auditLog != nil {
+		if serr := s.auditLog.Close(); serr != nil {
+			s.log.Println("Failed to close the audit log:", serr)
+			err = firstErr(err, serr)
+		}
+	}
+
+	s.log.Println("Server stopped")
+	return err
+}
+
+// setupTCPServer starts a TCP server on the address and returns the listener
+func (s *RapidoDB) setupTCPServer(addr string) net.Listener {
+	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		s.log.Fatalf("Listen setup failed: %s", err)
 	}
 
-	s.log.Println("Started server on PORT", s.PORT)
+	s.log.Println("Started server on", addr)
 	s.log.Println("Accepting Connections")
 
 	return listener
 }
 
 // setupTCPClientHandler sets up the TCP client handler via an infinite loop
-func (s *RapidoDB) setupTCPClientHandler(l net.Listener) {
+// every accepted connection is served by the passed handler. A temporary
+// error of the listener, like running out of file descriptors, is retried
+// after a delay which doubles up to a second, any other error is returned
+func (s *RapidoDB) setupTCPClientHandler(l net.Listener, handler func(net.Conn)) error {
+	// Delay before accepting again after a temporary error
+	var delay time.Duration
+
 	// An infinite loop to listen for any number of TCP clients
 	for {
 		// Accept WAITS for and returns the next connection
 		// to the listener. This is a blocking call.
 		conn, err := l.Accept()
 		if err != nil {
+			if s.isShuttingDown() {
+				return ErrServerClosed
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+
+				s.log.Printf("Unable to accept connection: %v, retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+
 			s.log.Println("Unable to accept connection: ", err.Error())
-			continue
+			return err
 		}
+		delay = 0
 
 		// Handle the client
-		go s.clientHandler(conn)
+		if !s.addHandler() {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go handler(conn)
 	}
 }
 
+// clientHandler serves a client speaking RQL
 func (s *RapidoDB) clientHandler(c net.Conn) {
-	// Print the address of the client
-	s.log.Println("Connected: ", c.RemoteAddr().String())
+	defer s.handlers.Done()
 
-	// get the client manager layer
-	sl := prepareClientManagerLayer(s.store, s.usersStore)
-
-	// get the observer layer and the private event bus
-	ol, eb := prepareObserverLayer(sl)
+	ol, eb, ok := s.prepareClient(c)
+	if !ok {
+		return
+	}
 
 	// get the translation layer
 	tl := prepareTranslationLayer(ol)
@@ -129,6 +376,126 @@ func (s *RapidoDB) clientHandler(c net.Conn) {
 	// setup transport extension using the private event bus
 	prepareTransportExt(trl, eb)
 
+	if username, _ := ol.WhoAmI(); username != "" {
+		trl.Msg("Authenticated as " + username + " by the client certificate")
+	}
+
+	if notice, ok := s.addClient(trl); !ok {
+		trl.Close(notice)
+		return
+	}
+	defer s.removeClient(trl)
+
 	// Initialise the reader for the client
 	trl.InitRead()
 }
+
+// respClientHandler serves a client speaking the Redis protocol
+func (s *RapidoDB) respClientHandler(c net.Conn) {
+	defer s.handlers.Done()
+
+	ol, eb, ok := s.prepareClient(c)
+	if !ok {
+		return
+	}
+
+	// The RESP front-end works on the observer layer directly
+	trl := prepareRESPLayer(c, s.log, ol)
+
+	// push the subscribed events using the private event bus
+	prepareRESPExt(trl, eb)
+
+	if notice, ok := s.addClient(trl); !ok {
+		trl.Close(notice)
+		return
+	}
+	defer s.removeClient(trl)
+
+	trl.InitRead()
+}
+
+// prepareClient prepares the observer layer of the client connected over
+// the connection and its private event bus. It returns false if the
+// connection couldn't be set up, in which case it has been closed
+func (s *RapidoDB) prepareClient(c net.Conn) (*observer.ObservedDB, *eventbus.EventBus, bool) {
+	// Print the address of the client
+	s.log.Println("Connected: ", c.RemoteAddr().String())
+
+	// The clients with a verified certificate are
+	// authenticated as the user of the certificate
+	session := manage.NewSession(c.RemoteAddr().String())
+	if tc, ok := c.(*tls.Conn); ok {
+		if session, ok = s.handshake(tc, session); !ok {
+			c.Close()
+			return nil, nil, false
+		}
+	}
+
+	// get the observer layer acting on behalf of the client's
+	// session and the private event bus
+	ol, eb := prepareObserverLayer(s.sdb, session)
+	return ol, eb, true
+}
+
+// addClient registers the client so that it can be closed on shutdown
+// and sets its maximum request size. It returns false along with the
+// notice for the client if the server is shutting down or if too many
+// clients are connected
+func (s *RapidoDB) addClient(c client) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return shutdownNotice, false
+	}
+
+	if s.maxClients > 0 && len(s.clients) >= s.maxClients {
+		s.log.Println("Rejected client, too many clients connected")
+		return tooManyClientsNotice, false
+	}
+
+	c.SetMaxRequestSize(s.maxRequestSize)
+	s.clients[c] = struct{}{}
+	return "", true
+}
+
+// removeClient removes the client registered by addClient
+func (s *RapidoDB) removeClient(c client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+}
+
+// addHandler registers a client handler with the handlers waited
+// for by Shutdown. It returns false if the server is shutting down,
+// in which case the handler must not be started
+func (s *RapidoDB) addHandler() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+
+	s.handlers.Add(1)
+	return true
+}
+
+// isShuttingDown returns true once Shutdown has been called
+func (s *RapidoDB) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.shuttingDown
+}
+
+// firstErr returns the first non nil error
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/config"
+	"github.com/utkarsh-pro/RapidoDB/manage"
+	"github.com/utkarsh-pro/RapidoDB/observer"
+	"github.com/utkarsh-pro/RapidoDB/store"
+)
+
+const (
+	// dataFile is the name of the file in the data directory
+	// where the data of the database is persisted
+	dataFile = "rapido.db"
+
+	// usersFile is the name of the file in the data directory
+	// where the users of the database are persisted
+	usersFile = "rapido_user.db"
+)
+
+// ErrServerClosed is returned by Serve once the database has been shut down
+var ErrServerClosed = errors.New("RapidoDB has been shut down")
+
+// Options configures a RapidoDB instance opened with Open
+type Options struct {
+	// Dir is the directory in which the data and the users are
+	// persisted. Nothing is persisted if it is left empty
+	Dir string
+
+	// DefaultExpiry is the expiry used for the items stored
+	// without an explicit one. Defaults to store.NeverExpire
+	// which keeps them forever
+	DefaultExpiry time.Duration
+
+	// JanitorInterval is the interval at which the expired items
+	// are removed. Defaults to store.DefaultJanitorInterval
+	JanitorInterval time.Duration
+
+	// PersistInterval is the interval at which the data is
+	// persisted. Defaults to store.DefaultPersistorInterval
+	PersistInterval time.Duration
+
+	// FsyncPolicy is the policy used to flush the write-ahead log
+	FsyncPolicy store.FsyncPolicy
+
+	// MaxClients is the maximum number of clients which can be
+	// connected at the same time. Zero means unlimited
+	MaxClients int
+
+	// MaxRequestSizeMB is the maximum size in megabytes of a request
+	// of any protocol. Defaults to config.DefaultMaxRequestSizeMB
+	MaxRequestSizeMB int
+
+	// Lockout throttles the failed authentication attempts of the
+	// remote clients. Defaults to manage.DefaultLockoutPolicy, a
+	// MaxFailures of less than zero disables the lockouts
+	Lockout manage.LockoutPolicy
+
+	// Auditor records every operation performed by the clients
+	// and the embedding application. Nothing is audited if it is nil
+	Auditor manage.Auditor
+
+	// TLS encrypts the connections accepted by Serve, they
+	// are not encrypted if it is nil
+	TLS *TLSOptions
+
+	// Username and Password of the admin user which is created
+	// when the database is opened. The admin user is needed only
+	// if the database is going to be served over TCP
+	Username string
+	Password string
+
+	// Users are created (or overwritten) when the database is opened
+	Users []User
+
+	// Log is the logger used by the database. Nothing
+	// is logged if it is left nil
+	Log *log.Logger
+}
+
+// User describes a user created when the database is opened
+type User struct {
+	Username string
+	Password string
+	Access   uint
+}
+
+// Open opens a RapidoDB instance which can be used in-process through
+// Set, Get, Delete, Wipe and Exec, and can optionally be exposed to
+// the remote clients using Serve. The data is persisted and expired
+// exactly like it is by the server
+//
+// The embedding application acts with admin privileges on the
+// database hence no authentication is required for in-process use
+func Open(opts Options) (*RapidoDB, error) {
+	if opts.Log == nil {
+		opts.Log = log.New(ioutil.Discard, "", 0)
+	}
+
+	if opts.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.JanitorInterval == 0 {
+		opts.JanitorInterval = store.DefaultJanitorInterval
+	}
+
+	if opts.PersistInterval == 0 {
+		opts.PersistInterval = store.DefaultPersistorInterval
+	}
+
+	if opts.MaxRequestSizeMB == 0 {
+		opts.MaxRequestSizeMB = config.DefaultMaxRequestSizeMB
+	}
+
+	if opts.Lockout == (manage.LockoutPolicy{}) {
+		opts.Lockout = manage.DefaultLockoutPolicy
+	}
+
+	var tlsCfg *tlsConfig
+	if opts.TLS != nil {
+		var err error
+		if tlsCfg, err = loadTLS(*opts.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create a new store for the database
+	storage := prepareStorageLayer(opts, backupPath(opts.Dir, dataFile), opts.DefaultExpiry)
+
+	// Create a new store for the users
+	usersDB := prepareStorageLayer(opts, backupPath(opts.Dir, usersFile), store.NeverExpire)
+
+	users := &manage.UserDB{UnsecureStore: usersDB}
+
+	// Hash the passwords of the users created before hashing was introduced
+	migrated, err := users.MigratePasswords()
+	if err != nil {
+		storage.Close()
+		usersDB.Close()
+		return nil, fmt.Errorf("Failed to migrate the passwords: %w", err)
+	}
+	if migrated > 0 {
+		opts.Log.Println("Hashed the plain text passwords of", migrated, "users")
+	}
+
+	bootstrap := opts.Users
+	if opts.Username != "" {
+		bootstrap = append([]User{{opts.Username, opts.Password, uint(manage.AdminAccess)}}, bootstrap...)
+	}
+
+	for _, u := range bootstrap {
+		access, err := manage.ConvertUintToAccess(u.Access)
+		if err == nil {
+			err = users.Bootstrap(u.Username, u.Password, access)
+		}
+
+		if err != nil {
+			storage.Close()
+			usersDB.Close()
+			return nil, fmt.Errorf("Invalid user %s: %w", u.Username, err)
+		}
+	}
+
+	s := &RapidoDB{
+		log:            opts.Log,
+		store:          storage,
+		usersStore:     usersDB,
+		maxClients:     opts.MaxClients,
+		maxRequestSize: opts.MaxRequestSizeMB << 20,
+		tls:            tlsCfg,
+		clients:        make(map[client]struct{}),
+	}
+	s.settings = newRuntimeSettings(s, configFromOptions(opts), "")
+
+	// The client manager layer is shared by all the clients
+	s.sdb = prepareClientManagerLayer(storage, usersDB, s.settings)
+	s.sdb.SetLockoutPolicy(opts.Lockout)
+	if opts.Auditor != nil {
+		s.sdb.SetAuditor(opts.Auditor)
+	}
+	s.sdb.OnLockout(func(l manage.Lockout) {
+		s.log.Printf("Locked out %s after %d failed authentication attempts", l.Target, l.Failures)
+		observer.PublishLockout(l)
+	})
+
+	// The layers used by the embedding application
+	s.local, _ = prepareObserverLayer(s.sdb, manage.NewTrustedSession("", manage.AdminPermission))
+	s.driver = prepareTranslationLayer(s.local)
+
+	return s, nil
+}
+
+// configFromOptions returns the configuration equivalent to the options
+func configFromOptions(opts Options) config.Config {
+	cfg := config.Default()
+	cfg.DataDir = opts.Dir
+	cfg.DefaultTTL = config.Duration(opts.DefaultExpiry)
+	cfg.JanitorInterval = config.Duration(opts.JanitorInterval)
+	cfg.PersistInterval = config.Duration(opts.PersistInterval)
+	cfg.Fsync = opts.FsyncPolicy.String()
+	cfg.MaxClients = opts.MaxClients
+	cfg.MaxRequestSizeMB = opts.MaxRequestSizeMB
+	cfg.AuthMaxFailures = opts.Lockout.MaxFailures
+	cfg.AuthLockout = config.Duration(opts.Lockout.Lockout)
+	cfg.AuthMaxLockout = config.Duration(opts.Lockout.MaxLockout)
+	if cfg.AuthMaxFailures < 0 {
+		cfg.AuthMaxFailures = 0
+	}
+
+	if opts.Username != "" {
+		cfg.Admin = config.User{Username: opts.Username, Password: opts.Password, Access: uint(manage.AdminAccess)}
+	}
+
+	return cfg
+}
+
+// Set stores the value against the key. The value is removed once
+// expireIn has elapsed, store.NeverExpire uses the default expiry
+func (s *RapidoDB) Set(key string, value interface{}, expireIn time.Duration) error {
+	return s.local.Set(key, value, expireIn)
+}
+
+// Get returns the value stored against the key. The returned bool
+// is false if the key doesn't exist or has expired
+func (s *RapidoDB) Get(key string) (interface{}, bool, error) {
+	return s.local.Get(key)
+}
+
+// Delete deletes the key and returns the deleted value. The returned
+// bool is false if the key didn't exist
+func (s *RapidoDB) Delete(key string) (interface{}, bool, error) {
+	return s.local.Delete(key)
+}
+
+// Incr atomically adds delta to the integer stored against the key
+// and returns the result. A key which doesn't exist counts from 0
+func (s *RapidoDB) Incr(key string, delta int64) (int64, error) {
+	return s.local.Incr(key, delta)
+}
+
+// IncrFloat atomically adds delta to the number stored against the
+// key and returns the result. A key which doesn't exist counts from 0
+func (s *RapidoDB) IncrFloat(key string, delta float64) (float64, error) {
+	return s.local.IncrFloat(key, delta)
+}
+
+// Wipe deletes every key in the database
+func (s *RapidoDB) Wipe() error {
+	return s.local.Wipe()
+}
+
+// Exec executes the RQL query and returns its response
+func (s *RapidoDB) Exec(query string) (string, error) {
+	return s.driver.Operate(query)
+}
+
+// DefaultExpiry returns the expiry used for the
+// items stored without an explicit one
+func (s *RapidoDB) DefaultExpiry() time.Duration {
+	return s.store.DefaultExpiry()
+}
+
+// Serve accepts the connections on the listener and serves them
+// until the database is shut down, at which point it returns
+// ErrServerClosed. The temporary errors of the listener are retried
+// with a growing delay while any other error is returned. Serve can
+// be called for several listeners
+//
+// The connections are encrypted if the database was opened with
+// TLS, otherwise the listener may be a TLS listener itself
+func (s *RapidoDB) Serve(l net.Listener) error {
+	l = s.tlsListener(l)
+	if !s.addListener(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+
+	return s.setupTCPClientHandler(l, s.clientHandler)
+}
+
+// ServeRESP is like Serve except that the clients speak the Redis
+// protocol, so that the Redis clients and tools can talk to RapidoDB
+func (s *RapidoDB) ServeRESP(l net.Listener) error {
+	l = s.tlsListener(l)
+	if !s.addListener(l) {
+		l.Close()
+		return ErrServerClosed
+	}
+
+	return s.setupTCPClientHandler(l, s.respClientHandler)
+}
+
+// Close shuts down the database without any deadline
+func (s *RapidoDB) Close() error {
+	return s.Shutdown(context.Background())
+}
+
+// addListener registers the listener so that it can be closed on
+// shutdown, it returns false if the database is already shut down
+func (s *RapidoDB) addListener(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+
+	s.listeners = append(s.listeners, l)
+	return true
+}
+
+// backupPath returns the path of the file in the directory, or
+// an empty string which disables persistence if dir is empty
+func backupPath(dir, file string) string {
+	if dir == "" {
+		return ""
+	}
+
+	return filepath.Join(dir, file)
+}
@@ -0,0 +1,557 @@
+package db
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/manage"
+	"github.com/utkarsh-pro/RapidoDB/rql"
+	"github.com/utkarsh-pro/RapidoDB/store"
+)
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rdb, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rdb.Set("k1", 1234, store.NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rdb.Exec(`SET k2 "Hello World";`); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := rdb.Exec(`GET k1 k2;`); err != nil || res != "[1234 Hello World]" {
+		t.Errorf("Exec() = %v, %v, want [1234 Hello World]", res, err)
+	}
+
+	want := "Success\n[3600]\n[true]\n[-1]"
+	if res, err := rdb.Exec(`SET k3 v3 EXPIREIN 1h; TTL k3; PERSIST k3; TTL k3;`); err != nil || res != want {
+		t.Errorf("Exec() = %q, %v, want %q", res, err, want)
+	}
+
+	if _, err := rdb.Exec(`SET k4 {"n": [1, -2.5, null]}; SET k5 true;`); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := rdb.Incr("k6", 2); err != nil || n != 2 {
+		t.Errorf("Incr() = %v, %v, want 2", n, err)
+	}
+	if res, err := rdb.Exec(`INCRBY k6 40; INCR k2;`); err == nil || res != "[42]" {
+		t.Errorf("Exec() = %q, %v, want [42] and the error of k2", res, err)
+	}
+
+	version, err := rdb.Exec(`CAS k7 0 v7;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The data should survive reopening the database
+	rdb, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	if v, ok, err := rdb.Get("k1"); err != nil || !ok || v != 1234 {
+		t.Errorf("Get(k1) = %v, %v, %v, want 1234", v, ok, err)
+	}
+
+	// The typed values keep their types
+	for key, want := range map[string]interface{}{
+		"k4": map[string]interface{}{"n": []interface{}{int64(1), -2.5, nil}},
+		"k5": true,
+		"k6": int64(42),
+	} {
+		if v, _, err := rdb.Get(key); err != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("Get(%s) = %#v, %v, want %#v", key, v, err, want)
+		}
+	}
+
+	// The versions survive as well, a stale version isn't accepted
+	want = "[v7 " + strings.Trim(version, "[]") + "]\nCondition not met"
+	if res, err := rdb.Exec(`GET k7 WITH VERSION; CAS k7 0 v8;`); err != nil || res != want {
+		t.Errorf("Exec() = %q, %v, want %q", res, err, want)
+	}
+}
+
+func TestOpenDefaultExpiry(t *testing.T) {
+	rdb, err := Open(Options{DefaultExpiry: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	if err := rdb.Set("k1", "v1", store.NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Set("k2", "v2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := rdb.Exec(`TTL k1; TTL k2;`); err != nil || res != "[3600]\n[60]" {
+		t.Errorf("Exec() = %q, %v, want [3600] and [60]", res, err)
+	}
+}
+
+func TestServe(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error)
+	go func() { served <- rdb.Serve(l) }()
+
+	if err := rdb.Set("k1", "embedded", store.NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	r.ReadString('\n') // Welcome message
+
+	conn.Write([]byte("AUTH admin pass;\n"))
+	r.ReadString('\n') // Authentication response
+
+	conn.Write([]byte("GET k1;\n"))
+	if res, _ := r.ReadString('\n'); strings.TrimSpace(res) != "[embedded]" {
+		t.Errorf("GET k1 = %q, want [embedded]", res)
+	}
+
+	// The results are typed once the client switches to JSON
+	conn.Write([]byte("PROTOCOL json\n"))
+	if res, _ := r.ReadString('\n'); strings.TrimSpace(res) != `{"message":"Protocol json"}` {
+		t.Errorf("PROTOCOL json = %q, want the confirmation", res)
+	}
+
+	conn.Write([]byte("GET k1 k2;\n"))
+	want := `{"results":[{"statement":"GET","ok":true,"values":["embedded",null],"found":[true,false]}]}`
+	if res, _ := r.ReadString('\n'); strings.TrimSpace(res) != want {
+		t.Errorf("GET k1 k2 = %q, want %s", res, want)
+	}
+
+	rdb.Close()
+
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestServeScopedEvents(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass", Users: []User{{"bob", "pass", 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	if _, err := rdb.Exec(`CREATEROLE sub read subscribe; ASSIGN sub TO bob; ACL ADD bob tenantA:* read;`); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	r.ReadString('\n') // Welcome message
+
+	conn.Write([]byte("AUTH bob pass;\n"))
+	r.ReadString('\n') // Authentication response
+
+	conn.Write([]byte("PING ON SET;\n"))
+	r.ReadString('\n') // Subscription response
+
+	// The user only receives the events of the keys it can read, the
+	// events aren't ordered so every event sent in time is collected
+	rdb.Set("tenantB:x", "secret", store.NeverExpire)
+	rdb.Set("tenantA:x", "visible", store.NeverExpire)
+
+	var events []string
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		events = append(events, strings.TrimSpace(line))
+	}
+
+	if got := strings.Join(events, "\n"); !strings.Contains(got, "tenantA:x") || strings.Contains(got, "tenantB:x") {
+		t.Errorf("events = %q, want only the set of tenantA:x", got)
+	}
+}
+
+func TestServeLockoutEvents(t *testing.T) {
+	lockout := manage.LockoutPolicy{MaxFailures: 1, Lockout: time.Minute, MaxLockout: time.Hour}
+	rdb, err := Open(Options{Username: "admin", Password: "pass", Lockout: lockout})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := bufio.NewReader(conn)
+		r.ReadString('\n') // Welcome message
+		return conn, r
+	}
+
+	conn, r := dial()
+	defer conn.Close()
+
+	conn.Write([]byte("AUTH admin pass;\n"))
+	r.ReadString('\n') // Authentication response
+
+	conn.Write([]byte("PING ON lockout;\n"))
+	if res, _ := r.ReadString('\n'); strings.TrimSpace(res) != "Subscribed to lockout" {
+		t.Fatalf("PING ON lockout = %q, want the subscription", res)
+	}
+
+	other, or := dial()
+	defer other.Close()
+
+	other.Write([]byte("AUTH mallory wrong;\n"))
+	or.ReadString('\n') // Authentication failure
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("no lockout event received: %v", err)
+		}
+		if strings.Contains(line, "user:mallory") {
+			break
+		}
+	}
+}
+
+func TestServeBinary(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass", MaxRequestSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	r.ReadString('\n') // Welcome message
+
+	// request encodes a request frame, the size of the body is
+	// overridden by size if it isn't zero
+	request := func(id uint32, size int, query string, args ...string) []byte {
+		putUint32 := func(b []byte, n int) []byte {
+			buf := make([]byte, 4)
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			return append(b, buf...)
+		}
+
+		body := append(putUint32(nil, len(query)), query...)
+		body = putUint32(body, len(args))
+		for _, arg := range args {
+			body = append(putUint32(body, len(arg)), arg...)
+		}
+		if size == 0 {
+			size = len(body)
+		}
+
+		b := putUint32(putUint32(nil, int(id)), size)
+		return append(b, body...)
+	}
+
+	// response reads a response frame
+	response := func() (uint32, byte, []byte) {
+		header := make([]byte, 9)
+		if _, err := io.ReadFull(r, header); err != nil {
+			t.Fatal(err)
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[5:]))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatal(err)
+		}
+		return binary.BigEndian.Uint32(header), header[4], payload
+	}
+
+	// The confirmation is the first frame, the commands sent along
+	// with the switch are read as frames
+	conn.Write(append([]byte("PROTOCOL binary\n"), request(1, 0, "AUTH admin ?;", "pass")...))
+	if id, typ, msg := response(); id != 0 || typ != 1 || string(msg) != "Protocol binary" {
+		t.Fatalf("PROTOCOL binary = %d %d %q, want the confirmation", id, typ, msg)
+	}
+	if id, _, _ := response(); id != 1 {
+		t.Fatalf("AUTH = request %d, want 1", id)
+	}
+
+	// The requests are pipelined and the values are binary safe
+	key, value := "k\n1", "\x00\xffline 1\r\nline 2;"
+	conn.Write(append(request(2, 0, "SET ? ?;", key, value), request(3, 0, "GET ? k2;", key)...))
+
+	for _, want := range [][]rql.Result{
+		{{Statement: "SET", OK: true, Message: "Success"}},
+		{{Statement: "GET", OK: true, Values: []interface{}{value, nil}, Found: []bool{true, false}}},
+	} {
+		id, typ, payload := response()
+		got, err := rql.DecodeBinary(payload)
+		if err != nil || typ != 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("request %d = %d %+v %v, want %+v", id, typ, got, err, want)
+		}
+	}
+
+	if v, _, _ := rdb.Get(key); v != value {
+		t.Errorf("Get(%q) = %q, want %q", key, v, value)
+	}
+
+	// A client sending a request larger than the maximum is disconnected
+	conn.Write(request(4, 2<<20, "SET k2 v2;"))
+	if id, typ, _ := response(); id != 4 || typ != 2 {
+		t.Errorf("large request = %d %d, want an error of the request 4", id, typ)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("large request read error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestServeLargeLine(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass", MaxRequestSizeMB: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	r.ReadString('\n') // Welcome message
+
+	// A client sending a line larger than the maximum is disconnected
+	// before the end of the line, if there is any, is received
+	go conn.Write([]byte("SET k1 " + strings.Repeat("v", 2<<20)))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Errorf("large line read error = %v, want the connection to be closed", err)
+		}
+	}
+}
+
+// tempErr is a temporary error of the listener, like EMFILE
+type tempErr struct{}
+
+func (tempErr) Error() string   { return "too many open files" }
+func (tempErr) Timeout() bool   { return false }
+func (tempErr) Temporary() bool { return true }
+
+// errListener is a listener whose Accept returns the errors in order
+type errListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *errListener) Accept() (net.Conn, error) {
+	err := l.errs[0]
+	l.errs = l.errs[1:]
+	return nil, err
+}
+
+func TestServeAcceptErrors(t *testing.T) {
+	rdb, err := Open(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The temporary errors are retried after 5ms, 10ms and 20ms
+	// and the permanent error stops serving
+	permanent := errors.New("listener broken")
+	el := &errListener{l, []error{tempErr{}, tempErr{}, tempErr{}, permanent}}
+
+	start := time.Now()
+	if err := rdb.Serve(el); err != permanent {
+		t.Errorf("Serve() error = %v, want %v", err, permanent)
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Serve() returned after %v, want the temporary errors to be retried with a delay", elapsed)
+	}
+}
+
+func TestServeRESP(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(chan error)
+	go func() { served <- rdb.ServeRESP(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	readLine := func() string {
+		line, _ := r.ReadString('\n')
+		return strings.TrimSpace(line)
+	}
+
+	conn.Write([]byte("*3\r\n$4\r\nAUTH\r\n$5\r\nadmin\r\n$4\r\npass\r\n"))
+	if res := readLine(); res != "+OK" {
+		t.Fatalf("AUTH = %q, want +OK", res)
+	}
+
+	conn.Write([]byte("SUBSCRIBE set\r\n"))
+	for i := 0; i < 6; i++ {
+		readLine() // Subscription confirmation
+	}
+
+	// The changes made by the embedding application are pushed
+	if err := rdb.Set("k1", "embedded", store.NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+
+	var msg []string
+	for i := 0; i < 7; i++ {
+		msg = append(msg, readLine())
+	}
+	if got := strings.Join(msg, " "); got != "*3 $7 message $3 set $2 k1" {
+		t.Errorf("message = %q, want the set of k1", got)
+	}
+
+	conn.Write([]byte("GET k1\r\n"))
+	if res := readLine() + " " + readLine(); res != "$8 embedded" {
+		t.Errorf("GET k1 = %q, want embedded", res)
+	}
+
+	rdb.Close()
+
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("ServeRESP() = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestRolesPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rdb, err := Open(Options{Dir: dir, Users: []User{{"bob", "pass", 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rdb.Exec(`CREATEROLE ops read wipe; ASSIGN ops TO bob;`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bootstrapping the user again keeps its roles
+	rdb, err = Open(Options{Dir: dir, Users: []User{{"bob", "pass", 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	if err := rdb.sdb.Wipe(manage.NewSession("")); err == nil {
+		t.Errorf("Wipe() without authentication should fail")
+	}
+
+	bob, err := rdb.sdb.Authenticate(manage.NewSession(""), "bob", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.sdb.Wipe(bob); err != nil {
+		t.Errorf("Wipe() with the assigned role error = %v", err)
+	}
+
+	if res, err := rdb.Exec(`ROLES;`); err != nil || res != "[admin=admin ops=read,wipe reader=read writer=read,write,delete]" {
+		t.Errorf("Exec() = %v, %v", res, err)
+	}
+}
@@ -3,31 +3,49 @@ package db
 import (
 	"log"
 	"net"
+	"time"
 
 	"github.com/utkarsh-pro/RapidoDB/eventbus"
 	"github.com/utkarsh-pro/RapidoDB/manage"
 	"github.com/utkarsh-pro/RapidoDB/observer"
+	"github.com/utkarsh-pro/RapidoDB/resp"
 	"github.com/utkarsh-pro/RapidoDB/rql"
 	"github.com/utkarsh-pro/RapidoDB/store"
 	"github.com/utkarsh-pro/RapidoDB/transport"
 	"github.com/utkarsh-pro/RapidoDB/transportext"
 )
 
-// prepareStorageLayer prepares the storage layer
-func prepareStorageLayer(log *log.Logger, backup string) *store.Store {
-	return store.New(store.NeverExpire, log, backup)
+func init() {
+	// Users are persisted along with their concrete type
+	store.RegisterType(manage.DBUser{})
+	store.RegisterType(manage.Role{})
 }
 
-// prepareClientManagerLayer takes in a store and a userdb which it uses
-// to prepare the client manager layer which also adds security to the database
-func prepareClientManagerLayer(store *store.Store, userdb *store.Store) *manage.SecureDB {
-	return manage.New(store, userdb)
+// prepareStorageLayer prepares the storage layer persisted at the
+// backup path with the settings provided in the options
+func prepareStorageLayer(opts Options, backup string, defaultExpiry time.Duration) *store.Store {
+	return store.NewWithOptions(store.Options{
+		DefaultExpiry:     defaultExpiry,
+		JanitorInterval:   opts.JanitorInterval,
+		PersistorInterval: opts.PersistInterval,
+		FsyncPolicy:       opts.FsyncPolicy,
+		Backup:            backup,
+		Log:               opts.Log,
+	})
 }
 
-// prepareObserverLayer takes in a securedb and adds a thin layer of observer
-// on that database which can publish events to the event bus
-func prepareObserverLayer(sdb *manage.SecureDB) (*observer.ObservedDB, *eventbus.EventBus) {
-	return observer.New(sdb)
+// prepareClientManagerLayer takes in a store, a userdb and the runtime settings
+// which it uses to prepare the client manager layer which also adds security
+// to the database
+func prepareClientManagerLayer(store *store.Store, userdb *store.Store, settings manage.Settings) *manage.SecureDB {
+	return manage.New(store, userdb, settings)
+}
+
+// prepareObserverLayer takes in a securedb and the session of a client and adds
+// a thin layer of observer on that database which acts on behalf of the client
+// and can publish events to the event bus
+func prepareObserverLayer(sdb *manage.SecureDB, session *manage.Session) (*observer.ObservedDB, *eventbus.EventBus) {
+	return observer.New(sdb, session)
 }
 
 // prepareTranslationLayer takes in a securedb and creates a translation
@@ -48,3 +66,15 @@ func prepareTransportLayer(c net.Conn, l *log.Logger, d *rql.Driver) *transport.
 func prepareTransportExt(c *transport.Client, eb *eventbus.EventBus) {
 	transportext.PingClient(c, eb, "verified_event")
 }
+
+// prepareRESPLayer takes in the connection parameter, logger and the observed
+// database to create a transport layer which speaks the Redis protocol
+func prepareRESPLayer(c net.Conn, l *log.Logger, db *observer.ObservedDB) *resp.Client {
+	return resp.New(c, l, db)
+}
+
+// prepareRESPExt prepares an extension to the RESP transport layer
+// which pushes the subscribed events to the client
+func prepareRESPExt(c *resp.Client, eb *eventbus.EventBus) {
+	transportext.PushEvents(c, eb, "verified_event")
+}
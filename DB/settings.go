@@ -0,0 +1,134 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/config"
+	"github.com/utkarsh-pro/RapidoDB/manage"
+)
+
+// runtimeSettings exposes the configuration of a running database
+// to the admins. It implements the manage.Settings interface
+type runtimeSettings struct {
+	mu sync.Mutex
+
+	// db is the database configured by the settings
+	db *RapidoDB
+
+	// cfg is the effective configuration of the database
+	cfg config.Config
+
+	// path is the configuration file written by Rewrite
+	// it is empty if the database wasn't configured by a file
+	path string
+}
+
+// newRuntimeSettings returns the settings of the database
+func newRuntimeSettings(db *RapidoDB, cfg config.Config, path string) *runtimeSettings {
+	return &runtimeSettings{db: db, cfg: cfg, path: path}
+}
+
+// Get returns the settings whose names match the glob pattern
+func (rs *runtimeSettings) Get(pattern string) map[string]string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	values := make(map[string]string)
+	for name, value := range rs.cfg.Values() {
+		if ok, _ := path.Match(pattern, name); ok {
+			values[name] = value
+		}
+	}
+
+	return values
+}
+
+// Set validates the value of the setting and applies it to the
+// running database. Settings which are only read on startup
+// cannot be changed
+func (rs *runtimeSettings) Set(name, value string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	switch name {
+	case "listen", "resp_listen", "data_dir", "log_level", "audit_file", "audit_max_size_mb", "audit_max_backups",
+		"tls_cert", "tls_key", "tls_client_ca", "tls_client_auth":
+		return fmt.Errorf("%s cannot be changed at runtime", name)
+	}
+
+	cfg := rs.cfg
+	if err := cfg.Set(name, value); err != nil {
+		return err
+	}
+
+	if err := rs.apply(name, cfg); err != nil {
+		return err
+	}
+
+	rs.cfg = cfg
+	return nil
+}
+
+// apply applies the setting of the configuration to the database
+func (rs *runtimeSettings) apply(name string, cfg config.Config) error {
+	s := rs.db
+
+	switch name {
+	case "janitor_interval":
+		interval := time.Duration(cfg.JanitorInterval)
+		return firstErr(s.store.SetJanitorInterval(interval), s.usersStore.SetJanitorInterval(interval))
+	case "persist_interval":
+		interval := time.Duration(cfg.PersistInterval)
+		return firstErr(s.store.SetPersistorInterval(interval), s.usersStore.SetPersistorInterval(interval))
+	case "default_ttl":
+		s.store.SetDefaultExpiry(time.Duration(cfg.DefaultTTL))
+	case "fsync":
+		s.store.SetFsyncPolicy(cfg.FsyncPolicy())
+		s.usersStore.SetFsyncPolicy(cfg.FsyncPolicy())
+	case "max_clients":
+		s.mu.Lock()
+		s.maxClients = cfg.MaxClients
+		s.mu.Unlock()
+	case "max_request_size_mb":
+		s.mu.Lock()
+		s.maxRequestSize = cfg.MaxRequestSizeMB << 20
+		for c := range s.clients {
+			c.SetMaxRequestSize(s.maxRequestSize)
+		}
+		s.mu.Unlock()
+	case "auth_max_failures", "auth_lockout", "auth_max_lockout":
+		s.sdb.SetLockoutPolicy(lockoutPolicy(cfg))
+	case "audit_reads":
+		if s.auditLog != nil {
+			s.auditLog.SetReads(cfg.AuditReads)
+		}
+	}
+
+	return nil
+}
+
+// lockoutPolicy returns the lockout policy of the configuration
+func lockoutPolicy(cfg config.Config) manage.LockoutPolicy {
+	return manage.LockoutPolicy{
+		MaxFailures: cfg.AuthMaxFailures,
+		Lockout:     time.Duration(cfg.AuthLockout),
+		MaxLockout:  time.Duration(cfg.AuthMaxLockout),
+	}
+}
+
+// Rewrite writes the effective configuration back to the
+// configuration file the database was started with
+func (rs *runtimeSettings) Rewrite() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.path == "" {
+		return errors.New("Database wasn't started with a configuration file")
+	}
+
+	return rs.cfg.Write(rs.path)
+}
@@ -0,0 +1,186 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/config"
+	"github.com/utkarsh-pro/RapidoDB/manage"
+)
+
+func TestRuntimeSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rdb, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	path := filepath.Join(dir, "rapido.json")
+	rdb.settings.path = path
+
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"SET MAX CLIENTS", `CONFIG SET max_clients 5;`, "Success", false},
+		{"SET DEFAULT TTL", `CONFIG SET default_ttl 1m;`, "Success", false},
+		{"SET WITH THE DEFAULT TTL", `SET k1 v1; TTL k1; SET k2 v2 EXPIREIN 1h; TTL k2;`, "Success\n[60]\nSuccess\n[3600]", false},
+		{"SET JANITOR INTERVAL", `CONFIG SET janitor_interval 2s;`, "Success", false},
+		{"SET INVALID FSYNC POLICY", `CONFIG SET fsync sometimes;`, "", true},
+		{"SET LISTEN", `CONFIG SET listen ":2311";`, "", true},
+		{"GET SETTINGS", `CONFIG GET max_clients; CONFIG GET "*_interval";`, "[max_clients=5]\n[janitor_interval=2s persist_interval=1m0s]", false},
+		{"REWRITE", `CONFIG REWRITE;`, "Success", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rdb.Exec(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Exec() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if rdb.maxClients != 5 || rdb.DefaultExpiry() != time.Minute || rdb.store.JanitorInterval() != 2*time.Second {
+		t.Errorf("Settings were not applied, got max_clients = %d, default_ttl = %v, janitor_interval = %v",
+			rdb.maxClients, rdb.DefaultExpiry(), rdb.store.JanitorInterval())
+	}
+
+	cfg, _, err := config.Load(config.Default(), []string{"-config", path}, func(string) string { return "" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MaxClients != 5 || cfg.DataDir != dir {
+		t.Errorf("Rewritten configuration = %+v", cfg)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.DefaultTTL = config.Duration(time.Hour)
+
+	rdb, err := NewFromConfig(log.New(ioutil.Discard, "", 0), cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	want := "Success\n[3600]\nSuccess\n[60]"
+	if res, err := rdb.Exec(`SET k1 v1; TTL k1; SET k2 v2 EXPIREIN 1m; TTL k2;`); err != nil || res != want {
+		t.Errorf("Exec() = %q, %v, want %q", res, err, want)
+	}
+}
+
+func TestMaxRequestSizeOfConnectedClients(t *testing.T) {
+	rdb, err := Open(Options{Username: "admin", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	r.ReadString('\n') // Welcome message
+
+	conn.Write([]byte("AUTH admin pass;\n"))
+	r.ReadString('\n') // Authentication response
+
+	if _, err := rdb.Exec(`CONFIG SET max_request_size_mb 1;`); err != nil {
+		t.Fatal(err)
+	}
+
+	// The client connected before the change is bounded by the new size
+	go conn.Write([]byte("SET k1 " + strings.Repeat("v", 2<<20)))
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			t.Errorf("large line read error = %v, want the connection to be closed", err)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.Default()
+	cfg.AuditFile = filepath.Join(dir, "audit.log")
+
+	rdb, err := NewFromConfig(log.New(ioutil.Discard, "", 0), cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rdb.Exec(`SET k1 1; GET k1; CONFIG SET audit_reads true; GET k1; WIPE;`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rdb.Exec(`CONFIG SET audit_file "other.log";`); err == nil {
+		t.Errorf("audit_file should not be changeable at runtime")
+	}
+
+	if err := rdb.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(cfg.AuditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var r manage.AuditRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid record %q: %s", line, err)
+		}
+		commands = append(commands, r.Command)
+	}
+
+	want := []string{"SET", "CONFIG SET", "GET", "WIPE", "CONFIG SET"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("audited commands = %v, want %v", commands, want)
+	}
+}
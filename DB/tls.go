@@ -0,0 +1,200 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/config"
+	"github.com/utkarsh-pro/RapidoDB/manage"
+)
+
+// handshakeTimeout is the time given to a client to complete the TLS handshake
+const handshakeTimeout = 10 * time.Second
+
+// TLSOptions configures the TLS of the connections accepted by Serve
+type TLSOptions struct {
+	// CertFile and KeyFile are the PEM encoded
+	// certificate and private key of the server
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile holds the PEM encoded certificates of the
+	// authorities which issue the client certificates
+	ClientCAFile string
+
+	// ClientAuth is the policy for the client certificates
+	ClientAuth tls.ClientAuthType
+
+	// Users maps the subjects of the verified client certificates to
+	// the users, like "CN=backup,O=Acme" to "reader". Without a mapping
+	// a client is authenticated as the user named by the common name of
+	// its certificate, with one the unmapped certificates are rejected
+	Users map[string]string
+}
+
+// tlsOptions returns the TLS options of the configuration
+// or nil if TLS isn't enabled by the configuration
+func tlsOptions(cfg config.Config) *TLSOptions {
+	if cfg.TLSCert == "" {
+		return nil
+	}
+
+	opts := &TLSOptions{
+		CertFile:     cfg.TLSCert,
+		KeyFile:      cfg.TLSKey,
+		ClientCAFile: cfg.TLSClientCA,
+		Users:        cfg.TLSUsers,
+	}
+
+	switch cfg.TLSClientAuth {
+	case config.TLSClientAuthOptional:
+		opts.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.TLSClientAuthRequire:
+		opts.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return opts
+}
+
+// tlsConfig holds the TLS configuration loaded from the files of
+// the options. The files can be loaded again while the server is
+// running, the new configuration is used from the next handshake
+type tlsConfig struct {
+	opts TLSOptions
+
+	mu  sync.RWMutex
+	cfg *tls.Config
+}
+
+// loadTLS loads the TLS configuration from the files of the options
+func loadTLS(opts TLSOptions) (*tlsConfig, error) {
+	t := &tlsConfig{opts: opts}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// reload loads the files again, the current configuration
+// is kept if any of the files is invalid
+func (t *tlsConfig) reload() error {
+	cert, err := tls.LoadX509KeyPair(t.opts.CertFile, t.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("Failed to load the certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   t.opts.ClientAuth,
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if t.opts.ClientCAFile != "" {
+		b, err := ioutil.ReadFile(t.opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("Failed to load the client authorities: %w", err)
+		}
+
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(b) {
+			return fmt.Errorf("No certificates found in %s", t.opts.ClientCAFile)
+		}
+	}
+
+	t.mu.Lock()
+	t.cfg = cfg
+	t.mu.Unlock()
+
+	return nil
+}
+
+// listenerConfig returns the configuration of the listener which
+// uses the latest loaded configuration on every handshake
+func (t *tlsConfig) listenerConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			t.mu.RLock()
+			defer t.mu.RUnlock()
+
+			return t.cfg, nil
+		},
+	}
+}
+
+// user returns the user of the verified client certificate of the
+// connection. The subject of the certificate is looked up in the users
+// if there are any, the common name is only used otherwise. The returned
+// bool is false if there is no such certificate or it isn't mapped
+func (t *tlsConfig) user(state tls.ConnectionState) (string, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	subject := state.VerifiedChains[0][0].Subject
+	if t != nil && len(t.opts.Users) > 0 {
+		username, ok := t.opts.Users[subject.String()]
+		return username, ok
+	}
+
+	return subject.CommonName, subject.CommonName != ""
+}
+
+// ReloadTLS loads the certificates of the TLS listener from their files
+// again. The connections which are already established are not affected
+func (s *RapidoDB) ReloadTLS() error {
+	if s.tls == nil {
+		return errors.New("TLS is not enabled")
+	}
+
+	if err := s.tls.reload(); err != nil {
+		return err
+	}
+
+	s.log.Println("Reloaded the TLS certificates")
+	return nil
+}
+
+// handshake completes the TLS handshake of the connection and authenticates
+// the session as the user of the client certificate, if there is one. It
+// returns false if the handshake failed
+func (s *RapidoDB) handshake(c *tls.Conn, session *manage.Session) (*manage.Session, bool) {
+	c.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := c.Handshake(); err != nil {
+		s.log.Printf("TLS handshake with %s failed: %s", c.RemoteAddr(), err)
+		return session, false
+	}
+	c.SetDeadline(time.Time{})
+
+	state := c.ConnectionState()
+	username, ok := s.tls.user(state)
+	if !ok {
+		if len(state.VerifiedChains) > 0 {
+			s.log.Printf("Client certificate of %s not mapped to a user", c.RemoteAddr())
+		}
+		return session, true
+	}
+
+	authenticated, err := s.sdb.AuthenticateCertificate(session, username)
+	if err != nil {
+		s.log.Printf("Client certificate of %s not accepted: %s", c.RemoteAddr(), err)
+		return session, true
+	}
+
+	return authenticated, true
+}
+
+// tlsListener wraps the listener with TLS if it is enabled
+func (s *RapidoDB) tlsListener(l net.Listener) net.Listener {
+	if s.tls == nil {
+		return l
+	}
+
+	return tls.NewListener(l, s.tls.listenerConfig())
+}
@@ -0,0 +1,210 @@
+package db
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, dir, "ca", 1, nil)
+	newTestCert(t, dir, "server", 2, ca)
+	bob := newTestCert(t, dir, "bob", 3, ca)
+	backup := newTestCert(t, dir, "backup", 4, ca)
+	stranger := newTestCert(t, dir, "bob", 5, nil)
+
+	rdb, err := Open(Options{
+		TLS: &TLSOptions{
+			CertFile:     filepath.Join(dir, "server.crt"),
+			KeyFile:      filepath.Join(dir, "server.key"),
+			ClientCAFile: filepath.Join(dir, "ca.crt"),
+			ClientAuth:   tls.VerifyClientCertIfGiven,
+			Users:        map[string]string{"CN=backup": "reader"},
+		},
+		Users: []User{{"bob", "pass", 1}, {"reader", "pass", 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	// dial connects with the client certificate, if any, and returns
+	// the serial number of the server certificate and the responses
+	dial := func(client *tls.Certificate) (int64, []string) {
+		cfg := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if client != nil {
+			cfg.Certificates = []tls.Certificate{*client}
+		}
+
+		conn, err := tls.Dial("tcp", l.Addr().String(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		r.ReadString('\n') // Welcome message
+
+		conn.Write([]byte("WHOAMI;\n"))
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, strings.TrimSpace(line))
+			if strings.HasPrefix(line, "[") || strings.HasPrefix(line, "ERR") {
+				break
+			}
+		}
+
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64(), lines
+	}
+
+	tests := []struct {
+		name   string
+		client *tls.Certificate
+		want   string
+	}{
+		{"WITHOUT CLIENT CERTIFICATE", nil, "[anonymous="},
+		{"UNMAPPED SUBJECT OF THE CERTIFICATE", bob, "[anonymous="},
+		{"MAPPED SUBJECT OF THE CERTIFICATE", backup, "[reader="},
+		{"CERTIFICATE OF AN UNKNOWN AUTHORITY", stranger, "[anonymous="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lines := dial(tt.client)
+			if got := lines[len(lines)-1]; !strings.Contains(got, tt.want) {
+				t.Errorf("WHOAMI = %v, want %s", lines, tt.want)
+			}
+		})
+	}
+
+	// The reloaded certificate is used by the new connections
+	newTestCert(t, dir, "server", 6, ca)
+	if err := rdb.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	if serial, _ := dial(nil); serial != 6 {
+		t.Errorf("serial number after reload = %d, want 6", serial)
+	}
+}
+
+func TestTLSConfig_user(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, dir, "ca", 1, nil)
+	admin := newTestCert(t, dir, "admin", 2, ca)
+	backup := newTestCert(t, dir, "backup", 3, ca)
+
+	verified := func(cert *tls.Certificate) tls.ConnectionState {
+		return tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert.Leaf, ca.Leaf}}}
+	}
+	mapped := &tlsConfig{opts: TLSOptions{Users: map[string]string{"CN=backup": "reader"}}}
+
+	tests := []struct {
+		name   string
+		tls    *tlsConfig
+		state  tls.ConnectionState
+		want   string
+		wantOk bool
+	}{
+		{"NO CERTIFICATE", &tlsConfig{}, tls.ConnectionState{}, "", false},
+		{"COMMON NAME WITHOUT MAPPING", &tlsConfig{}, verified(admin), "admin", true},
+		{"MAPPED SUBJECT", mapped, verified(backup), "reader", true},
+		{"UNMAPPED SUBJECT", mapped, verified(admin), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.tls.user(tt.state)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("tlsConfig.user() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+// newTestCert writes the certificate and the key named by the common name
+// to the directory. The certificate is signed by the parent, it is a self
+// signed authority if the parent is nil
+func newTestCert(t *testing.T, dir, name string, serial int64, parent *tls.Certificate) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+
+	return &cert
+}
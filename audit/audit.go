@@ -0,0 +1,185 @@
+/*
+   audit package writes the audit log of the database.
+
+   Every operation performed through the manage.SecureDB is recorded
+   as a JSON document on a line of its own, like
+
+   {"time":"2021-03-02T10:04:05Z","remote_addr":"10.0.0.7:51234","username":"bob","command":"WIPE","outcome":"ok"}
+
+   The file is only ever appended to. Once it grows beyond the maximum
+   size it is renamed to <path>.1, the older files are shifted to
+   <path>.2 and so on and the oldest one is removed
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/utkarsh-pro/RapidoDB/manage"
+)
+
+// Options configures the audit log
+type Options struct {
+	// Path is the file the records are written to
+	Path string
+
+	// MaxSize is the size in bytes after which the file is
+	// rotated. Zero means that the file is never rotated
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files which are kept
+	MaxBackups int
+
+	// Reads enables recording the operations which don't
+	// change anything, like GET
+	Reads bool
+
+	// Log is used to report the failures to write the
+	// records. Nothing is logged if it is left nil
+	Log *log.Logger
+}
+
+// Logger writes the audit records to a rotating file. It implements
+// the manage.Auditor interface and is safe for concurrent use
+type Logger struct {
+	mu   sync.Mutex
+	opts Options
+
+	file *os.File
+	size int64
+}
+
+// Open opens the audit log, the records are appended to the
+// file if it already exists
+func Open(opts Options) (*Logger, error) {
+	if opts.Log == nil {
+		opts.Log = log.New(ioutil.Discard, "", 0)
+	}
+
+	l := &Logger{opts: opts}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Audit writes the record to the file. Reads are skipped unless enabled
+func (l *Logger) Audit(r manage.AuditRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil || (r.Read && !l.opts.Reads) {
+		return
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		l.opts.Log.Println("Failed to encode the audit record:", err)
+		return
+	}
+	b = append(b, '\n')
+
+	if l.opts.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.opts.MaxSize {
+		if err := l.rotate(); err != nil {
+			l.opts.Log.Println("Failed to rotate the audit log:", err)
+		}
+
+		// Keep writing to the current file if a new one couldn't be started
+		if l.file == nil {
+			if err := l.open(); err != nil {
+				l.opts.Log.Println("Failed to reopen the audit log:", err)
+				return
+			}
+		}
+	}
+
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		l.opts.Log.Println("Failed to write the audit record:", err)
+	}
+}
+
+// SetReads enables or disables recording the reads
+func (l *Logger) SetReads(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.opts.Reads = on
+}
+
+// Close flushes the file onto the disk and closes it. Nothing
+// is recorded once the audit log is closed
+func (l *Logger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Sync()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+
+	l.file = nil
+	return err
+}
+
+// open opens the file for appending
+func (l *Logger) open() error {
+	f, err := os.OpenFile(l.opts.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// rotate moves the current file out of the way, shifts the
+// older files and starts a new file
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+	l.file = nil
+
+	// The oldest file is dropped, or the current one
+	// if no rotated files are to be kept
+	if err := os.Remove(l.backup(l.opts.MaxBackups)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i := l.opts.MaxBackups - 1; i >= 0; i-- {
+		if err := os.Rename(l.backup(i), l.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return l.open()
+}
+
+// backup returns the path of the nth rotated file, the
+// 0th one being the current file
+func (l *Logger) backup(n int) string {
+	if n == 0 {
+		return l.opts.Path
+	}
+
+	return fmt.Sprintf("%s.%d", l.opts.Path, n)
+}
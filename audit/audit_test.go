@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/manage"
+)
+
+func TestLogger_Audit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+
+	l, err := Open(Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Audit(manage.AuditRecord{Time: time.Now(), Username: "bob", Command: "GET", Targets: []string{"k1"}, Outcome: manage.AuditOK, Read: true})
+	l.Audit(manage.AuditRecord{Time: time.Now(), Username: "bob", Command: "WIPE", Outcome: manage.AuditDenied, Error: "Access denied"})
+	l.SetReads(true)
+	l.Audit(manage.AuditRecord{Time: time.Now(), Username: "bob", Command: "GET", Targets: []string{"k2"}, Outcome: manage.AuditOK, Read: true})
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records written after closing are dropped
+	l.Audit(manage.AuditRecord{Command: "SET"})
+
+	// Records are appended to the existing file
+	l, err = Open(Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Audit(manage.AuditRecord{Time: time.Now(), Command: "AUTH", Targets: []string{"bob"}, Outcome: manage.AuditFailed})
+	l.Close()
+
+	records := readRecords(t, path)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	tests := []struct {
+		name    string
+		got     manage.AuditRecord
+		command string
+		target  string
+		outcome string
+	}{
+		{"FIRST RECORD IS THE WIPE", records[0], "WIPE", "", manage.AuditDenied},
+		{"READS ARE RECORDED ONCE ENABLED", records[1], "GET", "k2", manage.AuditOK},
+		{"REOPENED LOG IS APPENDED TO", records[2], "AUTH", "bob", manage.AuditFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := ""
+			if len(tt.got.Targets) > 0 {
+				target = tt.got.Targets[0]
+			}
+
+			if tt.got.Command != tt.command || target != tt.target || tt.got.Outcome != tt.outcome {
+				t.Errorf("record = %+v, want %s %s %s", tt.got, tt.command, tt.target, tt.outcome)
+			}
+		})
+	}
+}
+
+func TestLogger_rotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+
+	// Every record is bigger than half the maximum size
+	// so that every record starts a new file
+	l, err := Open(Options{Path: path, MaxSize: 100, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []string{"u1", "u2", "u3", "u4"} {
+		l.Audit(manage.AuditRecord{Time: time.Now(), Username: user, Command: "WIPE", Outcome: manage.AuditOK})
+	}
+	l.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"CURRENT FILE", path, "u4"},
+		{"FIRST BACKUP", path + ".1", "u3"},
+		{"SECOND BACKUP", path + ".2", "u2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := readRecords(t, tt.path)
+			if len(records) != 1 || records[0].Username != tt.want {
+				t.Errorf("records = %+v, want a single record of %s", records, tt.want)
+			}
+		})
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("oldest backup should have been removed, stat error = %v", err)
+	}
+}
+
+// readRecords reads the records written to the file
+func readRecords(t *testing.T, path string) []manage.AuditRecord {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []manage.AuditRecord
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r manage.AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("invalid record %q: %s", sc.Text(), err)
+		}
+		records = append(records, r)
+	}
+
+	return records
+}
@@ -0,0 +1,321 @@
+/*
+   client package is the Go client of RapidoDB.
+
+   The client speaks the binary protocol of the server, so the keys and
+   the values can hold any bytes. It keeps a pool of connections which
+   are authenticated automatically and established again with a backoff
+   once they break. Several goroutines can use the client concurrently,
+   their requests are pipelined over the connections of the pool.
+
+     c, err := client.Dial(ctx, client.Options{
+     	Addr:     "localhost:2310",
+     	Username: "admin",
+     	Password: "pass",
+     })
+     if err != nil {
+     	return err
+     }
+     defer c.Close()
+
+     if err := c.Set(ctx, "greeting", "Hello\nWorld", time.Minute); err != nil {
+     	return err
+     }
+     v, ok, err := c.Get(ctx, "greeting")
+
+   The errors returned by the server are *Error values carrying the code
+   of the error like "DENIED", "AUTH", "LOCKED" or "PARSE".
+*/
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/rql"
+)
+
+const (
+	// DefaultPoolSize is the default number of connections of the pool
+	DefaultPoolSize = 4
+
+	// DefaultDialTimeout is the default timeout of establishing
+	// a connection, including the authentication
+	DefaultDialTimeout = 5 * time.Second
+
+	// DefaultMinBackoff and DefaultMaxBackoff are the default delays
+	// before the first and the last attempts to connect again
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+
+	// DefaultMaxRetries is the default number of attempts
+	// to connect again after a failed attempt
+	DefaultMaxRetries = 5
+)
+
+var (
+	// ErrClosed is returned by the calls made after the client was closed
+	ErrClosed = errors.New("Client is closed")
+
+	// ErrConnBroken wraps the errors of the connections which broke
+	// while the requests were in flight. Such requests may have been
+	// executed by the server, they are not retried
+	ErrConnBroken = errors.New("Connection broken")
+)
+
+// Error is an error returned by the server
+type Error struct {
+	// Statement is the statement which failed like "SET"
+	Statement string
+
+	// Code identifies the error like "DENIED" or "PARSE"
+	Code string
+
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Options configure the client
+type Options struct {
+	// Addr is the address of the server like "localhost:2310"
+	Addr string
+
+	// Username and Password authenticate the connections, they
+	// are not authenticated if the Username is empty
+	Username string
+	Password string
+
+	// TLSConfig encrypts the connections, they are not
+	// encrypted if it is nil
+	TLSConfig *tls.Config
+
+	// PoolSize is the number of connections used to execute the
+	// requests. Defaults to DefaultPoolSize
+	PoolSize int
+
+	// DialTimeout bounds the time taken to establish a connection
+	// when the context has no deadline. Defaults to DefaultDialTimeout
+	DialTimeout time.Duration
+
+	// MinBackoff is the delay before the first attempt to connect again,
+	// it doubles with every attempt up to MaxBackoff. They default to
+	// DefaultMinBackoff and DefaultMaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// MaxRetries is the number of attempts to connect again after a
+	// failed attempt. Defaults to DefaultMaxRetries, a negative
+	// number disables them
+	MaxRetries int
+}
+
+// slot holds a connection of the pool,
+// it is established when first used
+type slot struct {
+	mu sync.Mutex
+	c  *conn
+}
+
+// Client is a pool of connections to a RapidoDB server
+type Client struct {
+	opts  Options
+	slots []*slot
+
+	mu     sync.Mutex
+	next   int
+	closed bool
+}
+
+// Dial returns a client of the server, it establishes
+// the first connection to verify the options
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultPoolSize
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = DefaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+
+	c := &Client{opts: opts, slots: make([]*slot, opts.PoolSize)}
+	for i := range c.slots {
+		c.slots[i] = &slot{}
+	}
+
+	if _, err := c.conn(ctx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Exec executes the RQL query, the "?" placeholders of the query
+// are replaced by the args. The error of the first statement which
+// failed is returned as an *Error along with the results of the
+// statements executed until then
+func (c *Client) Exec(ctx context.Context, query string, args ...string) ([]rql.Result, error) {
+	cn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return cn.exec(ctx, query, args...)
+}
+
+// Get returns the value of the key and true if the key exists
+func (c *Client) Get(ctx context.Context, key string) (interface{}, bool, error) {
+	return c.found(ctx, "GET ?;", key)
+}
+
+// Set stores the value of the key, it expires after expireIn.
+// The default expiry of the server is used if expireIn is 0
+func (c *Client) Set(ctx context.Context, key, value string, expireIn time.Duration) error {
+	query := "SET ? ?;"
+	if expireIn > 0 {
+		query = "SET ? ? " + strconv.FormatInt(int64(expireIn/time.Millisecond), 10) + ";"
+	}
+
+	_, err := c.Exec(ctx, query, key, value)
+	return err
+}
+
+// Delete deletes the key, it returns the deleted
+// value and true if the key existed
+func (c *Client) Delete(ctx context.Context, key string) (interface{}, bool, error) {
+	return c.found(ctx, "DEL ?;", key)
+}
+
+// Wipe deletes every key of the database
+func (c *Client) Wipe(ctx context.Context) error {
+	_, err := c.Exec(ctx, "WIPE;")
+	return err
+}
+
+// found returns the value of the key queried by the GET or DEL
+// query and true if the server found the key
+func (c *Client) found(ctx context.Context, query, key string) (interface{}, bool, error) {
+	results, err := c.Exec(ctx, query, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(results) != 1 || len(results[0].Values) != 1 || len(results[0].Found) != 1 {
+		return nil, false, errNoResult
+	}
+
+	return results[0].Values[0], results[0].Found[0], nil
+}
+
+// Close closes the connections of the pool, the requests
+// in flight fail. The subscriptions are not closed
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	var err error
+	for _, s := range c.slots {
+		s.mu.Lock()
+		if s.c != nil {
+			if cerr := s.c.close(); err == nil {
+				err = cerr
+			}
+			s.c = nil
+		}
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// conn returns the next connection of the pool, which
+// is established again if it isn't usable anymore
+func (c *Client) conn(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	s := c.slots[c.next]
+	c.next = (c.next + 1) % len(c.slots)
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.c != nil && !s.c.broken() {
+		return s.c, nil
+	}
+
+	cn, err := dial(ctx, c.opts, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The client may have been closed in the meantime
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		cn.close()
+		return nil, ErrClosed
+	}
+
+	s.c = cn
+	return cn, nil
+}
+
+// dial connects to the server, the failed attempts are retried with an
+// exponential backoff. The errors returned by the server, like invalid
+// credentials, are not retried
+func dial(ctx context.Context, opts Options, push chan<- Message, quit <-chan struct{}) (*conn, error) {
+	backoff := opts.MinBackoff
+
+	for attempt := 0; ; attempt++ {
+		cn, err := connect(ctx, opts, push, quit)
+		if err == nil {
+			return cn, nil
+		}
+
+		var serr *Error
+		if errors.As(err, &serr) || attempt >= opts.MaxRetries {
+			return nil, err
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-quit:
+			t.Stop()
+			return nil, ErrClosed
+		}
+
+		if backoff *= 2; backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
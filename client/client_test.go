@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/utkarsh-pro/RapidoDB/DB"
+	"github.com/utkarsh-pro/RapidoDB/store"
+)
+
+// serve serves a new in memory database on the
+// address and returns it along with its address
+func serve(t *testing.T, addr string) (*db.RapidoDB, string) {
+	rdb, err := db.Open(db.Options{Username: "admin", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	return rdb, l.Addr().String()
+}
+
+func TestClient(t *testing.T) {
+	rdb, addr := serve(t, "127.0.0.1:0")
+	defer func() { rdb.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := Options{Addr: addr, Username: "admin", Password: "wrong", PoolSize: 2, MinBackoff: 10 * time.Millisecond}
+
+	var serr *Error
+	if _, err := Dial(ctx, opts); !errors.As(err, &serr) || serr.Code != "AUTH" {
+		t.Fatalf("Dial() with an invalid password error = %v, want an AUTH error", err)
+	}
+
+	opts.Password = "pass"
+	c, err := Dial(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The keys and the values are binary safe
+	key, value := "k\n1", "\x00\xffHello\r\nWorld;"
+	if err := c.Set(ctx, key, value, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := c.Get(ctx, key); v != value || !ok || err != nil {
+		t.Errorf("Get() = %q, %v, %v, want %q", v, ok, err, value)
+	}
+	if v, ok, err := c.Get(ctx, "k2"); v != nil || ok || err != nil {
+		t.Errorf("Get() of a missing key = %v, %v, %v, want nothing", v, ok, err)
+	}
+
+	if _, err := c.Exec(ctx, "SET k2;"); !errors.As(err, &serr) || serr.Code != "PARSE" {
+		t.Errorf("Exec() of an invalid query error = %v, want a PARSE error", err)
+	}
+
+	// The requests of concurrent goroutines are pipelined
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			k := fmt.Sprintf("key%d", i)
+			if err := c.Set(ctx, k, k, 0); err != nil {
+				t.Error(err)
+			}
+			if v, _, err := c.Get(ctx, k); v != k || err != nil {
+				t.Errorf("Get(%s) = %v, %v, want %s", k, v, err, k)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if v, ok, err := c.Delete(ctx, key); v != value || !ok || err != nil {
+		t.Errorf("Delete() = %q, %v, %v, want %q", v, ok, err, value)
+	}
+	if v, ok, err := c.Delete(ctx, key); v != nil || ok || err != nil {
+		t.Errorf("Delete() of a missing key = %v, %v, %v, want nothing", v, ok, err)
+	}
+
+	// A key holding null exists
+	if _, err := c.Exec(ctx, "SET knull null;"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := c.Get(ctx, "knull"); v != nil || !ok || err != nil {
+		t.Errorf("Get() of a null value = %v, %v, %v, want nil, true", v, ok, err)
+	}
+	if v, ok, err := c.Delete(ctx, "knull"); v != nil || !ok || err != nil {
+		t.Errorf("Delete() of a null value = %v, %v, %v, want nil, true", v, ok, err)
+	}
+
+	// The events are sent to the channel of the subscription
+	sub, err := c.Subscribe(ctx, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.Set("k3", "embedded", store.NeverExpire); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-sub.C:
+		if m.Event != "set" || m.Key != "k3" || m.Value != "embedded" {
+			t.Errorf("message = %+v, want the set of k3", m)
+		}
+	case <-ctx.Done():
+		t.Fatal("no message received")
+	}
+	sub.Close()
+
+	if _, ok := <-sub.C; ok {
+		t.Error("channel of the closed subscription is open")
+	}
+
+	// A request whose context is done isn't waited for
+	done, cancelDone := context.WithCancel(ctx)
+	cancelDone()
+	if _, _, err := c.Get(done, "k3"); err != context.Canceled {
+		t.Errorf("Get() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+
+	// The connections are established again once they break
+	rdb.Close()
+	rdb, _ = serve(t, addr)
+
+	for i := 0; ; i++ {
+		err := c.Set(ctx, "k4", "v4", 0)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, ErrConnBroken) || i > len(c.slots) {
+			t.Fatalf("Set() after a restart error = %v, want to reconnect", err)
+		}
+	}
+
+	c.Close()
+	if _, _, err := c.Get(ctx, "k4"); err != ErrClosed {
+		t.Errorf("Get() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
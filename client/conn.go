@@ -0,0 +1,322 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/rql"
+)
+
+// Types of the response frames of the binary protocol
+const (
+	frameResults byte = iota
+	frameMessage
+	frameError
+)
+
+// frame is a response frame of the binary protocol
+type frame struct {
+	typ     byte
+	payload []byte
+}
+
+// conn is a connection speaking the binary protocol. Several requests can
+// be in flight at the same time, the responses are dispatched to their
+// requests by the read loop using the request ids
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+
+	// writeMu serializes the writes of the requests
+	writeMu sync.Mutex
+
+	// mu guards the fields below
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan frame
+	err     error
+
+	// push receives the messages pushed by the server, they
+	// are dropped if it is nil. quit stops a blocked push
+	push chan<- Message
+	quit <-chan struct{}
+
+	// done is closed once the read loop exited
+	done chan struct{}
+}
+
+// connect dials the server, switches the connection to the binary
+// protocol and authenticates it if the options carry credentials
+func connect(ctx context.Context, opts Options, push chan<- Message, quit <-chan struct{}) (*conn, error) {
+	d := net.Dialer{Timeout: opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake must finish before the deadline of the context
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	} else {
+		nc.SetDeadline(time.Now().Add(opts.DialTimeout))
+	}
+
+	if opts.TLSConfig != nil {
+		cfg := opts.TLSConfig.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName, _, _ = net.SplitHostPort(opts.Addr)
+		}
+
+		tc := tls.Client(nc, cfg)
+		if err := tc.Handshake(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+
+	c := &conn{
+		nc:      nc,
+		r:       bufio.NewReader(nc),
+		pending: make(map[uint32]chan frame),
+		push:    push,
+		quit:    quit,
+		done:    make(chan struct{}),
+	}
+
+	if err := c.handshake(); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	go c.readLoop()
+
+	if opts.Username != "" {
+		if _, err := c.exec(ctx, "AUTH ? ?;", opts.Username, opts.Password); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// handshake switches the connection to the binary protocol. The
+// greetings of the server are text lines, which never start with
+// a zero byte unlike the confirmation frame of the request id 0
+func (c *conn) handshake() error {
+	if _, err := c.nc.Write([]byte("PROTOCOL binary\n")); err != nil {
+		return err
+	}
+
+	var last string
+	for {
+		b, err := c.r.Peek(1)
+		if err != nil {
+			if err == io.EOF && last != "" {
+				return fmt.Errorf("Connection closed by the server: %s", last)
+			}
+			return err
+		}
+		if b[0] == 0 {
+			break
+		}
+
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		last = strings.TrimSpace(line)
+	}
+
+	_, f, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	if f.typ != frameMessage {
+		return fmt.Errorf("Failed to switch to the binary protocol: %s", f.payload)
+	}
+
+	return nil
+}
+
+// readFrame reads the next response frame
+func (c *conn) readFrame() (uint32, frame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, frame{}, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[5:]))
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, frame{}, err
+	}
+
+	return binary.BigEndian.Uint32(header[:4]), frame{header[4], payload}, nil
+}
+
+// readLoop dispatches the responses to the pending requests and the
+// messages pushed by the server to the push channel until the
+// connection breaks, the pending requests fail with its error
+func (c *conn) readLoop() {
+	defer close(c.done)
+
+	for {
+		id, f, err := c.readFrame()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		if id == 0 {
+			if c.push == nil {
+				continue
+			}
+
+			select {
+			case c.push <- parseMessage(string(f.payload)):
+			case <-c.quit:
+			}
+			continue
+		}
+
+		c.mu.Lock()
+		ch, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+
+		// The request may have been abandoned
+		if ok {
+			ch <- f
+		}
+	}
+}
+
+// fail marks the connection as broken by the error
+func (c *conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = fmt.Errorf("%w: %v", ErrConnBroken, err)
+	}
+}
+
+// broken returns true if the connection cannot be used anymore
+func (c *conn) broken() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection and waits for the read loop to exit
+func (c *conn) close() error {
+	err := c.nc.Close()
+	<-c.done
+	return err
+}
+
+// exec sends the query along with its args and waits for the results
+// or for the context to be done. The error of the first statement
+// which failed is returned as an *Error along with the results
+func (c *conn) exec(ctx context.Context, query string, args ...string) ([]rql.Result, error) {
+	ch := make(chan frame, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return nil, c.err
+	}
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID++
+	}
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	_, err := c.nc.Write(encodeRequest(id, query, args))
+	c.writeMu.Unlock()
+	if err != nil {
+		c.nc.Close()
+		<-c.done
+		return nil, c.err
+	}
+
+	select {
+	case f := <-ch:
+		return decodeResponse(f)
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// encodeRequest returns the request frame of the query and its args
+func encodeRequest(id uint32, query string, args []string) []byte {
+	size := 8 + len(query)
+	for _, arg := range args {
+		size += 4 + len(arg)
+	}
+
+	b := make([]byte, 0, 8+size)
+	b = appendUint32(b, id)
+	b = appendUint32(b, uint32(size))
+	b = appendUint32(b, uint32(len(query)))
+	b = append(b, query...)
+	b = appendUint32(b, uint32(len(args)))
+	for _, arg := range args {
+		b = appendUint32(b, uint32(len(arg)))
+		b = append(b, arg...)
+	}
+
+	return b
+}
+
+func appendUint32(b []byte, n uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], n)
+	return append(b, buf[:]...)
+}
+
+// decodeResponse returns the results carried by the frame
+func decodeResponse(f frame) ([]rql.Result, error) {
+	if f.typ != frameResults {
+		return nil, &Error{Code: rql.CodeUnknown, Message: string(f.payload)}
+	}
+
+	results, err := rql.DecodeBinary(f.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range results {
+		if r.Error != nil {
+			return results, &Error{Statement: r.Statement, Code: r.Error.Code, Message: r.Error.Message}
+		}
+	}
+
+	return results, nil
+}
+
+// errNoResult is returned if the server didn't return the result
+// of the statement sent by one of the typed calls
+var errNoResult = errors.New("Missing result")
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a message pushed by the server to a subscription
+type Message struct {
+	// Event is the event like "set" or "del", it is
+	// empty for the notices of the server
+	Event string
+	Key   string
+	Value string
+
+	// Text is the message as it was sent by the server
+	Text string
+}
+
+// parseMessage parses the messages formatted like
+// "Event: op_set Key: k1 Value: v1" by the server
+func parseMessage(text string) Message {
+	m := Message{Text: text}
+
+	rest := strings.TrimPrefix(text, "Event: ")
+	if rest == text {
+		return m
+	}
+
+	i := strings.Index(rest, " Key: ")
+	if i < 0 {
+		return m
+	}
+	j := strings.Index(rest[i:], " Value: ")
+	if j < 0 {
+		return m
+	}
+
+	m.Event = strings.TrimPrefix(rest[:i], "op_")
+	m.Key = rest[i+len(" Key: ") : i+j]
+	m.Value = rest[i+j+len(" Value: "):]
+
+	return m
+}
+
+// Subscription receives the messages of the events subscribed to. It
+// uses a connection of its own which is established again, and
+// subscribed again, once it breaks
+type Subscription struct {
+	// C receives the messages, it is closed once
+	// the subscription is closed or failed
+	C <-chan Message
+
+	c      chan Message
+	opts   Options
+	events []string
+
+	// err is the reason why the subscription failed,
+	// it is set before C is closed
+	err error
+
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Subscribe subscribes to the events like "set", "get", "del" or "wipe"
+// of the keys the user has access to. The messages are sent to the C
+// channel of the subscription, separately from the responses of the
+// requests, and must be received for the subscription to keep up
+func (c *Client) Subscribe(ctx context.Context, events ...string) (*Subscription, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("No events to subscribe to")
+	}
+	for _, event := range events {
+		if !isWord(event) {
+			return nil, fmt.Errorf("Invalid event %q", event)
+		}
+	}
+
+	ch := make(chan Message)
+	s := &Subscription{
+		C:      ch,
+		c:      ch,
+		opts:   c.opts,
+		events: events,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	cn, err := s.subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.run(cn)
+
+	return s, nil
+}
+
+// Err returns the reason why the subscription failed once C is closed,
+// it is nil if the subscription was closed by Close
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close closes the subscription and its connection
+func (s *Subscription) Close() error {
+	s.closeOnce.Do(func() { close(s.quit) })
+	<-s.done
+	return nil
+}
+
+// subscribe connects to the server and subscribes to the events
+func (s *Subscription) subscribe(ctx context.Context) (*conn, error) {
+	cn, err := dial(ctx, s.opts, s.c, s.quit)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range s.events {
+		if _, err := cn.exec(ctx, "PING ON "+event+";"); err != nil {
+			cn.close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+// run establishes the connection again once it breaks until the
+// subscription is closed or the server refuses the subscription
+func (s *Subscription) run(cn *conn) {
+	defer close(s.done)
+	defer close(s.c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-s.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-cn.done:
+		case <-s.quit:
+			cn.close()
+			return
+		}
+
+		for {
+			var err error
+			if cn, err = s.subscribe(ctx); err == nil {
+				break
+			}
+
+			var serr *Error
+			if errors.As(err, &serr) {
+				s.err = err
+				return
+			}
+
+			t := time.NewTimer(s.opts.MaxBackoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}
+}
+
+// isWord returns true if the string is made of letters only
+func isWord(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return s != ""
+}
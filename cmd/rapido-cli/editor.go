@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// errInterrupted is returned by ReadLine when Ctrl-C is pressed
+var errInterrupted = errors.New("Interrupted")
+
+// Keys read in raw mode
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// editor reads the lines typed in the terminal. In raw mode it supports
+// the history, the completion of the keywords and the usual editing
+// keys, otherwise it reads the lines as they come
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// raw puts the terminal in raw mode and returns the function
+	// restoring it, the lines are read as they come if it is nil
+	raw func() (func(), error)
+
+	// complete returns the completions of the word
+	complete func(word string) []string
+
+	// mu guards the fields below and the output, so that the
+	// messages printed while a line is read don't garble it
+	mu      sync.Mutex
+	reading bool
+	prompt  string
+	line    []rune
+	pos     int
+
+	history []string
+}
+
+// Printf prints the message. If a line is being read the message is
+// printed above it and the line is drawn again
+func (e *editor) Printf(format string, args ...interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	msg := fmt.Sprintf(format, args...)
+	if !e.reading || e.raw == nil {
+		fmt.Fprint(e.out, msg)
+		return
+	}
+
+	fmt.Fprint(e.out, "\r\x1b[K"+strings.Replace(msg, "\n", "\r\n", -1))
+	e.refresh()
+}
+
+// AddHistory appends the entry to the history unless
+// it is empty or the same as the last entry
+func (e *editor) AddHistory(entry string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if entry == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == entry) {
+		return
+	}
+	e.history = append(e.history, entry)
+}
+
+// ReadLine reads the next line, it returns io.EOF once the input
+// ends or Ctrl-D is pressed on an empty line and errInterrupted
+// if Ctrl-C is pressed
+func (e *editor) ReadLine(prompt string) (string, error) {
+	if e.raw == nil {
+		return e.readCooked(prompt)
+	}
+
+	restore, err := e.raw()
+	if err != nil {
+		return e.readCooked(prompt)
+	}
+	defer restore()
+
+	e.mu.Lock()
+	e.reading, e.prompt, e.line, e.pos = true, prompt, nil, 0
+	e.refresh()
+	e.mu.Unlock()
+
+	defer func() {
+		e.mu.Lock()
+		e.reading = false
+		e.mu.Unlock()
+	}()
+
+	// hpos is the position in the history, draft is
+	// the line typed before browsing the history
+	hpos, draft := len(e.history), ""
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		e.mu.Lock()
+		switch r {
+		case keyEnter, '\n':
+			line := string(e.line)
+			fmt.Fprint(e.out, "\r\n")
+			e.mu.Unlock()
+			return line, nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			e.mu.Unlock()
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				e.mu.Unlock()
+				return "", io.EOF
+			}
+			e.deleteAt(e.pos)
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.pos--
+				e.deleteAt(e.pos)
+			}
+		case keyTab:
+			e.completeWord()
+		case keyCtrlA:
+			e.pos = 0
+		case keyCtrlE:
+			e.pos = len(e.line)
+		case keyCtrlK:
+			e.line = e.line[:e.pos]
+		case keyCtrlU:
+			e.line, e.pos = e.line[e.pos:], 0
+		case keyCtrlW:
+			start := e.wordStart(func(r rune) bool { return !unicode.IsSpace(r) }, true)
+			e.line = append(e.line[:start], e.line[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyEscape:
+			switch e.escapeSequence() {
+			case "[A", "OA":
+				if hpos > 0 {
+					if hpos == len(e.history) {
+						draft = string(e.line)
+					}
+					hpos--
+					e.setLine(e.history[hpos])
+				}
+			case "[B", "OB":
+				if hpos < len(e.history) {
+					hpos++
+					if hpos == len(e.history) {
+						e.setLine(draft)
+					} else {
+						e.setLine(e.history[hpos])
+					}
+				}
+			case "[C", "OC":
+				if e.pos < len(e.line) {
+					e.pos++
+				}
+			case "[D", "OD":
+				if e.pos > 0 {
+					e.pos--
+				}
+			case "[H", "OH", "[1~":
+				e.pos = 0
+			case "[F", "OF", "[4~":
+				e.pos = len(e.line)
+			case "[3~":
+				e.deleteAt(e.pos)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.line = append(e.line[:e.pos], append([]rune{r}, e.line[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.refresh()
+		e.mu.Unlock()
+	}
+}
+
+// readCooked prints the prompt and reads the next line as it comes
+func (e *editor) readCooked(prompt string) (string, error) {
+	e.Printf("%s", prompt)
+
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// escapeSequence reads the rest of an escape sequence
+// like "[A" which follows the escape key
+func (e *editor) escapeSequence() string {
+	var seq []rune
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return string(seq)
+		}
+		seq = append(seq, r)
+
+		// The sequences end with a letter or a tilde
+		if len(seq) > 1 && (unicode.IsLetter(r) || r == '~') {
+			return string(seq)
+		}
+		if len(seq) > 4 || (seq[0] != '[' && seq[0] != 'O') {
+			return string(seq)
+		}
+	}
+}
+
+// completeWord completes the word in front of the cursor. A single
+// completion is inserted, otherwise the common prefix is inserted and
+// the completions are listed if there is no common prefix to insert
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+
+	start := e.wordStart(unicode.IsLetter, false)
+	word := string(e.line[start:e.pos])
+	if word == "" {
+		return
+	}
+
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return
+	}
+
+	insert := candidates[0]
+	if len(candidates) == 1 {
+		insert += " "
+	} else {
+		for _, c := range candidates[1:] {
+			insert = commonPrefix(insert, c)
+		}
+	}
+
+	if len([]rune(insert)) == len([]rune(word)) {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		return
+	}
+
+	rest := []rune(insert)[len([]rune(word)):]
+	e.line = append(e.line[:e.pos], append(rest, e.line[e.pos:]...)...)
+	e.pos += len(rest)
+}
+
+// wordStart returns the start of the word in front of the cursor, made
+// of the runes accepted by inWord. The spaces between the word and the
+// cursor are skipped if skipSpaces is set
+func (e *editor) wordStart(inWord func(rune) bool, skipSpaces bool) int {
+	start := e.pos
+	for skipSpaces && start > 0 && unicode.IsSpace(e.line[start-1]) {
+		start--
+	}
+	for start > 0 && inWord(e.line[start-1]) {
+		start--
+	}
+	return start
+}
+
+func (e *editor) deleteAt(pos int) {
+	if pos < len(e.line) {
+		e.line = append(e.line[:pos], e.line[pos+1:]...)
+	}
+}
+
+func (e *editor) setLine(line string) {
+	e.line = []rune(line)
+	e.pos = len(e.line)
+}
+
+// refresh draws the prompt and the line and places the cursor
+func (e *editor) refresh() {
+	fmt.Fprint(e.out, "\r"+e.prompt+string(e.line)+"\x1b[K")
+	if n := len(e.line) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+// commonPrefix returns the prefix shared by the strings
+func commonPrefix(a, b string) string {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return string(ra[:n])
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestEditor_ReadLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		want    string
+		wantErr error
+	}{
+		{"TYPING", "GET k1\r", "GET k1", nil},
+		{"BACKSPACE", "GET k12\x7f\r", "GET k1", nil},
+		{"HOME AND END", "ET k\x1b[HG\x1b[F1\r", "GET k1", nil},
+		{"ARROWS", "GT k1\x1b[D\x1b[D\x1b[D\x1b[DE\r", "GET k1", nil},
+		{"PREVIOUS ENTRIES", "\x1b[A\x1b[A\r", "SET k1 1;", nil},
+		{"BACK TO THE DRAFT", "GE\x1b[A\x1b[BT\r", "GET", nil},
+		{"KILL WORD", "GET k1 k2\x17\r", "GET k1 ", nil},
+		{"SINGLE COMPLETION", "lis\t\r", "listusers ", nil},
+		{"COMMON PREFIX", "CREATE\t\r", "CREATEROLE ", nil},
+		{"AMBIGUOUS COMPLETION", "DEL\t\r", "DEL", nil},
+		{"INTERRUPT", "GET\x03", "", errInterrupted},
+		{"END OF INPUT", "\x04", "", io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &editor{
+				in:       bufio.NewReader(strings.NewReader(tt.keys)),
+				out:      ioutil.Discard,
+				raw:      func() (func(), error) { return func() {}, nil },
+				complete: completeKeyword,
+				history:  []string{"SET k1 1;", "GET k1;"},
+			}
+
+			got, err := e.ReadLine(prompt)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("editor.ReadLine() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+/*
+   rapido-cli is the interactive client of RapidoDB.
+
+   It connects to the server using the binary protocol and reads the RQL
+   statements typed in the terminal, which end with ';' and can span
+   several lines. The history is kept in ~/.rapido_history and Tab
+   completes the keywords. The events subscribed to with PING ON are
+   printed as they arrive.
+
+   The statements passed by -e, or read from the standard input when it
+   isn't a terminal, are executed one after the other until one fails:
+
+     rapido-cli -user admin -pass pass -e 'SET greeting "Hello World"; GET greeting;'
+     rapido-cli -user admin -pass pass < statements.rql
+
+   The connection is configured by the following flags and env variables:
+
+     -addr        RAPIDO_ADDR    address of the server, localhost:2310 by default
+     -user        RAPIDO_USER    user to authenticate as
+     -pass        RAPIDO_PASS    password of the user
+     -tls                        encrypt the connection
+     -tls-ca                     certificate authority of the server certificate
+     -tls-cert                   client certificate
+     -tls-key                    key of the client certificate
+     -timeout                    timeout of the statements, 10s by default
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/client"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run runs the client and returns its exit code
+func run() int {
+	addr := flag.String("addr", getEnv("RAPIDO_ADDR", "localhost:2310"), "address of the server")
+	user := flag.String("user", os.Getenv("RAPIDO_USER"), "user to authenticate as")
+	pass := flag.String("pass", os.Getenv("RAPIDO_PASS"), "password of the user")
+	useTLS := flag.Bool("tls", false, "encrypt the connection")
+	tlsCA := flag.String("tls-ca", "", "certificate authority of the server certificate")
+	tlsCert := flag.String("tls-cert", "", "client certificate")
+	tlsKey := flag.String("tls-key", "", "key of the client certificate")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of the statements")
+	query := flag.String("e", "", "statements to execute")
+	history := flag.String("history", historyPath(), "history file, empty disables the history")
+	flag.Parse()
+
+	opts := client.Options{Addr: *addr, Username: *user, Password: *pass, PoolSize: 1}
+	if *useTLS || *tlsCA != "" || *tlsCert != "" {
+		cfg, err := tlsConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.TLSConfig = cfg
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	c, err := client.Dial(ctx, opts)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, formatError(err))
+		return 1
+	}
+
+	interactive := *query == "" && isTerminal(os.Stdin)
+
+	s := &session{
+		ed:      &editor{in: bufio.NewReader(os.Stdin), out: os.Stdout, complete: completeKeyword},
+		opts:    opts,
+		timeout: *timeout,
+		c:       c,
+	}
+	defer s.close()
+
+	if !interactive {
+		src := *query
+		if src == "" {
+			b, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			src = string(b)
+		}
+
+		if s.script(src); s.failed {
+			return 1
+		}
+		return 0
+	}
+
+	fd := int(os.Stdin.Fd())
+	s.ed.raw = func() (func(), error) { return makeRaw(fd) }
+
+	if *history != "" {
+		s.ed.history = loadHistory(*history)
+	}
+
+	s.ed.Printf("Connected to %s, type \\help for help\n", *addr)
+	s.repl()
+
+	if *history != "" {
+		if err := saveHistory(*history, s.ed.history); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to save the history:", err)
+		}
+	}
+
+	return 0
+}
+
+// tlsConfig returns the configuration of the TLS connection
+func tlsConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", caFile)
+		}
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("-tls-cert and -tls-key must be set together")
+		}
+
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// historyPath returns the path of the history file in the home directory
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".rapido_history")
+}
+
+// getEnv returns the value of the env variable or the default value if it is not set
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
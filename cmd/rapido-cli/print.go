@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/utkarsh-pro/RapidoDB/client"
+	"github.com/utkarsh-pro/RapidoDB/rql"
+)
+
+// formatResult formats the result of a statement for the terminal. The
+// values are numbered if there are several of them, the strings are
+// quoted and the other values are prefixed by their type
+func formatResult(r rql.Result) string {
+	switch {
+	case r.Error != nil:
+		return formatError(&client.Error{Code: r.Error.Code, Message: r.Error.Message})
+	case r.Values != nil:
+		switch len(r.Values) {
+		case 0:
+			return "(empty)"
+		case 1:
+			return formatValue(r.Values[0])
+		}
+
+		lines := make([]string, len(r.Values))
+		for i, v := range r.Values {
+			lines[i] = fmt.Sprintf("%d) %s", i+1, formatValue(v))
+		}
+		return strings.Join(lines, "\n")
+	case r.Pairs != nil:
+		if len(r.Pairs) == 0 {
+			return "(empty)"
+		}
+
+		names := make([]string, 0, len(r.Pairs))
+		for name := range r.Pairs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		lines := make([]string, len(names))
+		for i, name := range names {
+			lines[i] = name + ": " + strings.Join(r.Pairs[name], ", ")
+		}
+		return strings.Join(lines, "\n")
+	default:
+		return r.Message
+	}
+}
+
+// formatValue formats a value returned by the server
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "(nil)"
+	case string:
+		return strconv.Quote(v)
+	case int64:
+		return "(integer) " + strconv.FormatInt(v, 10)
+	case float64:
+		return "(float) " + strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return "(bool) " + strconv.FormatBool(v)
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return "(json) " + string(b)
+		}
+		return fmt.Sprintf("%v", v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// formatError formats an error, the errors of the
+// server are prefixed by their code
+func formatError(err error) string {
+	if e, ok := err.(*client.Error); ok {
+		return "(error) " + e.Code + ": " + e.Message
+	}
+	return "(error) " + err.Error()
+}
+
+// formatMessage formats a message pushed to a subscription
+func formatMessage(m client.Message) string {
+	if m.Event == "" {
+		return "[notice] " + m.Text
+	}
+	return "[" + m.Event + "] " + m.Key + " = " + m.Value
+}
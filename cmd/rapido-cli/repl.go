@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/client"
+	"github.com/utkarsh-pro/RapidoDB/rql"
+)
+
+const (
+	prompt             = "rapido> "
+	continuationPrompt = "   ...> "
+
+	// maxHistory is the number of entries kept in the history file
+	maxHistory = 1000
+)
+
+const helpMsg = `Statements end with ';' and can span several lines.
+
+  \help      print this help
+  \history   print the history
+  \quit      quit, as do Ctrl-D, quit and exit
+
+PING ON <event>; prints the events as they arrive, PING OFF <event>;
+stops printing them. Tab completes the keywords.
+`
+
+// session executes the statements typed by the user or read from a script
+type session struct {
+	ed      *editor
+	opts    client.Options
+	timeout time.Duration
+
+	// c is replaced when the user authenticates again
+	c *client.Client
+
+	// events are the events subscribed to by PING ON,
+	// their messages are received by sub
+	events []string
+	sub    *client.Subscription
+
+	// failed is set once a statement failed
+	failed bool
+}
+
+// splitStatements splits the source into its statements, which end with
+// the ';' delimiter. The source after the last delimiter is returned as
+// the rest. The delimiters inside the strings don't end the statements
+func splitStatements(src string) ([]string, string) {
+	var stmts []string
+	start, inString := 0, false
+
+	for i := 0; i < len(src); i++ {
+		switch c := src[i]; {
+		case inString && c == '"':
+			// A delimiter followed by a backslash is escaped
+			if i+1 < len(src) && src[i+1] == '\\' {
+				i++
+				continue
+			}
+			inString = false
+		case c == '"':
+			inString = true
+		case !inString && c == ';':
+			stmts = append(stmts, strings.TrimSpace(src[start:i+1]))
+			start = i + 1
+		}
+	}
+
+	return stmts, src[start:]
+}
+
+// completeKeyword returns the keywords starting with the word,
+// in lower case if the word is typed in lower case
+func completeKeyword(word string) []string {
+	var res []string
+	for _, k := range rql.Keywords() {
+		if !strings.HasPrefix(k, strings.ToUpper(word)) {
+			continue
+		}
+		if word == strings.ToLower(word) {
+			k = strings.ToLower(k)
+		}
+		res = append(res, k)
+	}
+
+	return res
+}
+
+// repl reads the statements typed by the user and executes
+// them until the input ends or the user quits
+func (s *session) repl() {
+	var buf string
+	for {
+		p := prompt
+		if buf != "" {
+			p = continuationPrompt
+		}
+
+		line, err := s.ed.ReadLine(p)
+		if err == errInterrupted {
+			buf = ""
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		if buf == "" {
+			switch strings.TrimSpace(line) {
+			case "":
+				continue
+			case `\q`, `\quit`, "quit", "exit":
+				return
+			case `\help`:
+				s.ed.Printf("%s", helpMsg)
+				continue
+			case `\history`:
+				for i, entry := range s.ed.history {
+					s.ed.Printf("%4d  %s\n", i+1, entry)
+				}
+				continue
+			}
+		}
+
+		stmts, rest := splitStatements(buf + line + "\n")
+		for _, stmt := range stmts {
+			s.ed.AddHistory(strings.Replace(stmt, "\n", " ", -1))
+			s.exec(stmt)
+		}
+
+		buf = rest
+		if strings.TrimSpace(buf) == "" {
+			buf = ""
+		}
+	}
+}
+
+// script executes the statements of the script one after the other
+// until one fails, the last statement may omit the delimiter. The
+// events subscribed to are printed until the user interrupts
+func (s *session) script(src string) {
+	stmts, rest := splitStatements(src)
+	if strings.TrimSpace(rest) != "" {
+		stmts = append(stmts, strings.TrimSpace(rest)+";")
+	}
+
+	for _, stmt := range stmts {
+		if !s.exec(stmt) {
+			return
+		}
+	}
+
+	if len(s.events) == 0 {
+		return
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	<-interrupt
+}
+
+// exec executes the statement and prints its result, it returns false if
+// the statement failed. PING and AUTH are handled by the session so that
+// the subscriptions and the credentials survive the reconnections
+func (s *session) exec(stmt string) bool {
+	fields := strings.Fields(strings.TrimSuffix(stmt, ";"))
+	if len(fields) == 3 && !strings.Contains(stmt, `"`) {
+		switch {
+		case strings.EqualFold(fields[0], "PING") && strings.EqualFold(fields[1], "ON"):
+			return s.ping(strings.ToLower(fields[2]), true)
+		case strings.EqualFold(fields[0], "PING") && strings.EqualFold(fields[1], "OFF"):
+			return s.ping(strings.ToLower(fields[2]), false)
+		case strings.EqualFold(fields[0], "AUTH"):
+			return s.auth(fields[1], fields[2])
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	results, err := s.c.Exec(ctx, stmt)
+	for _, r := range results {
+		s.ed.Printf("%s\n", formatResult(r))
+	}
+
+	if err != nil {
+		if _, ok := err.(*client.Error); !ok || len(results) == 0 {
+			s.ed.Printf("%s\n", formatError(err))
+		}
+		s.failed = true
+		return false
+	}
+
+	return true
+}
+
+// auth connects again with the credentials, the
+// subscriptions are established again as well
+func (s *session) auth(username, password string) bool {
+	opts := s.opts
+	opts.Username, opts.Password = username, password
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	c, err := client.Dial(ctx, opts)
+	if err != nil {
+		s.ed.Printf("%s\n", formatError(err))
+		s.failed = true
+		return false
+	}
+
+	s.c.Close()
+	s.c, s.opts = c, opts
+
+	s.ed.Printf("Successfully Authenticated\n")
+	if len(s.events) > 0 {
+		return s.subscribe(s.events)
+	}
+	return true
+}
+
+// ping adds the event to the events subscribed to or removes it
+func (s *session) ping(event string, on bool) bool {
+	var events []string
+	for _, e := range s.events {
+		if e != event {
+			events = append(events, e)
+		}
+	}
+	if on {
+		events = append(events, event)
+	}
+
+	if !s.subscribe(events) {
+		return false
+	}
+
+	if on {
+		s.ed.Printf("Subscribed to %s\n", event)
+	} else {
+		s.ed.Printf("Unsubscribed from %s\n", event)
+	}
+	return true
+}
+
+// subscribe replaces the subscription by one to the events,
+// their messages are printed as they arrive
+func (s *session) subscribe(events []string) bool {
+	if len(events) == 0 {
+		s.closeSubscription()
+		s.events = nil
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	sub, err := s.c.Subscribe(ctx, events...)
+	if err != nil {
+		s.ed.Printf("%s\n", formatError(err))
+		s.failed = true
+		return false
+	}
+
+	s.closeSubscription()
+	s.events, s.sub = events, sub
+
+	go func() {
+		for m := range sub.C {
+			s.ed.Printf("%s\n", formatMessage(m))
+		}
+		if err := sub.Err(); err != nil {
+			s.ed.Printf("Subscription failed: %s\n", formatError(err))
+		}
+	}()
+
+	return true
+}
+
+// close closes the client and the subscription
+func (s *session) close() {
+	s.closeSubscription()
+	s.c.Close()
+}
+
+func (s *session) closeSubscription() {
+	if s.sub != nil {
+		s.sub.Close()
+		s.sub = nil
+	}
+}
+
+// loadHistory reads the history file, a missing file is an empty history
+func loadHistory(path string) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var history []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+
+	return history
+}
+
+// saveHistory writes the last entries of the history to the file
+func saveHistory(path string, history []string) error {
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	var b strings.Builder
+	for _, entry := range history {
+		b.WriteString(entry + "\n")
+	}
+
+	return ioutil.WriteFile(path, []byte(b.String()), 0600)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/utkarsh-pro/RapidoDB/DB"
+	"github.com/utkarsh-pro/RapidoDB/client"
+)
+
+func Test_splitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+		rest string
+	}{
+		{"SINGLE STATEMENT", "GET k1;", []string{"GET k1;"}, ""},
+		{"MULTI LINE STATEMENT", "SET k1\n  12;\nGET", []string{"SET k1\n  12;"}, "\nGET"},
+		{"DELIMITER IN A STRING", `SET k1 "a;b"; GET k1;`, []string{`SET k1 "a;b";`, "GET k1;"}, ""},
+		{"ESCAPED QUOTE", `SET k1 "a"\;b";`, []string{`SET k1 "a"\;b";`}, ""},
+		{"UNTERMINATED STRING", `SET k1 "a;`, nil, `SET k1 "a;`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest := splitStatements(tt.src)
+			if !reflect.DeepEqual(got, tt.want) || rest != tt.rest {
+				t.Errorf("splitStatements() = %q, %q, want %q, %q", got, rest, tt.want, tt.rest)
+			}
+		})
+	}
+}
+
+func TestSession_script(t *testing.T) {
+	rdb, err := db.Open(db.Options{Username: "admin", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rdb.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go rdb.Serve(l)
+
+	opts := client.Options{Addr: l.Addr().String(), Username: "admin", Password: "pass", PoolSize: 1}
+	c, err := client.Dial(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		src    string
+		want   string
+		failed bool
+	}{
+		{
+			"STATEMENTS",
+			"SET k1 \"a;b\";\nGET k1 k2; GET k1",
+			"Success\n1) \"a;b\"\n2) (nil)\n\"a;b\"\n",
+			false,
+		},
+		{
+			"EXECUTION STOPS AT THE FAILED STATEMENT",
+			"SET k1;\nSET k3 1;",
+			"(error) PARSE: ",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := &session{
+				ed:      &editor{in: bufio.NewReader(strings.NewReader("")), out: &out},
+				opts:    opts,
+				timeout: 5 * time.Second,
+				c:       c,
+			}
+
+			s.script(tt.src)
+			if got := out.String(); !strings.HasPrefix(got, tt.want) || (tt.failed && strings.Contains(got, "Success")) {
+				t.Errorf("session.script() printed %q, want %q", got, tt.want)
+			}
+			if s.failed != tt.failed {
+				t.Errorf("session.failed = %v, want %v", s.failed, tt.failed)
+			}
+		})
+	}
+
+	c.Close()
+}
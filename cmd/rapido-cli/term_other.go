@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// isTerminal returns true if the file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// makeRaw isn't supported on this platform, the
+// lines are read in the mode of the terminal
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("Raw mode is not supported")
+}
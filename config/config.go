@@ -0,0 +1,701 @@
+/*
+   config package holds the configuration of the RapidoDB server.
+
+   The configuration is assembled from the following sources, each
+   source overriding the values set by the sources before it:
+
+   1. Defaults
+   2. Configuration file (JSON), passed by -config flag or RAPIDO_CONFIG env
+   3. Environment variables
+   4. Command line flags
+
+   The configuration file is a JSON document, every key is optional:
+
+   {
+     "listen": ":2310",                 // RAPIDO_LISTEN, -listen
+     "resp_listen": ":6379",            // RAPIDO_RESP_LISTEN, -resp-listen (empty disables RESP)
+     "data_dir": "/var/lib/rapido",     // RAPIDO_DATA_DIR, -data-dir
+     "janitor_interval": "10s",         // RAPIDO_JANITOR_INTERVAL, -janitor-interval
+     "persist_interval": "1m",          // RAPIDO_PERSIST_INTERVAL, -persist-interval
+     "fsync": "everysec",               // RAPIDO_FSYNC, -fsync (always, everysec, never)
+     "default_ttl": "0s",               // RAPIDO_DEFAULT_TTL, -default-ttl (0s never expires)
+     "max_clients": 0,                  // RAPIDO_MAX_CLIENTS, -max-clients (0 is unlimited)
+     "max_request_size_mb": 64,         // RAPIDO_MAX_REQUEST_SIZE_MB, -max-request-size-mb
+     "log_level": "info",               // RAPIDO_LOG_LEVEL, -log-level (debug, info, silent)
+     "auth_max_failures": 5,            // RAPIDO_AUTH_MAX_FAILURES, -auth-max-failures (0 disables lockouts)
+     "auth_lockout": "1s",              // RAPIDO_AUTH_LOCKOUT, -auth-lockout
+     "auth_max_lockout": "5m0s",        // RAPIDO_AUTH_MAX_LOCKOUT, -auth-max-lockout
+     "audit_file": "audit.log",         // RAPIDO_AUDIT_FILE, -audit-file (empty disables auditing)
+     "audit_max_size_mb": 100,          // RAPIDO_AUDIT_MAX_SIZE_MB, -audit-max-size-mb
+     "audit_max_backups": 5,            // RAPIDO_AUDIT_MAX_BACKUPS, -audit-max-backups
+     "audit_reads": false,              // RAPIDO_AUDIT_READS, -audit-reads
+     "tls_cert": "server.crt",          // RAPIDO_TLS_CERT, -tls-cert (empty disables TLS)
+     "tls_key": "server.key",           // RAPIDO_TLS_KEY, -tls-key
+     "tls_client_ca": "ca.crt",         // RAPIDO_TLS_CLIENT_CA, -tls-client-ca
+     "tls_client_auth": "none",         // RAPIDO_TLS_CLIENT_AUTH, -tls-client-auth (none, optional, require)
+     "tls_users": {                     // users of the client certificates
+       "CN=backup": "reader"            // by subject, the common name is the username without a mapping
+     },
+     "admin": {                         // the bootstrap admin user
+       "username": "admin",             // RAPIDO_USER, -user
+       "password": "pass"               // RAPIDO_PASS, -pass
+     },
+     "users": [                         // additional bootstrap users
+       {"username": "reader", "password": "secret", "access": 1}
+     ]
+   }
+
+   For backwards compatibility RAPIDO_PORT sets the port the server
+   listens on when RAPIDO_LISTEN is not set.
+
+   Durations are written in the format understood by time.ParseDuration
+*/
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/utkarsh-pro/RapidoDB/store"
+)
+
+const (
+	// DefaultListen is the default address of the TCP server
+	DefaultListen = ":2310"
+
+	// DefaultUser is the default username of the bootstrap admin
+	DefaultUser = "admin"
+
+	// DefaultPass is the default password of the bootstrap admin
+	DefaultPass = "pass"
+
+	// maxAccess is the highest access level a user can have
+	maxAccess = 5
+
+	// DefaultAuthMaxFailures is the default number of failed
+	// authentication attempts after which a client is locked out
+	DefaultAuthMaxFailures = 5
+
+	// DefaultAuthLockout and DefaultAuthMaxLockout are the default
+	// durations of the first and the longest lockouts
+	DefaultAuthLockout    = time.Second
+	DefaultAuthMaxLockout = 5 * time.Minute
+
+	// DefaultMaxRequestSizeMB is the default maximum
+	// size in megabytes of a request
+	DefaultMaxRequestSizeMB = 64
+
+	// DefaultAuditMaxSizeMB is the default size in megabytes
+	// after which the audit log is rotated
+	DefaultAuditMaxSizeMB = 100
+
+	// DefaultAuditMaxBackups is the default number of
+	// rotated audit logs which are kept
+	DefaultAuditMaxBackups = 5
+)
+
+// Client certificate policies of the TLS listener
+const (
+	TLSClientAuthNone     = "none"
+	TLSClientAuthOptional = "optional"
+	TLSClientAuthRequire  = "require"
+)
+
+// Log levels supported by the server
+const (
+	LogDebug  = "debug"
+	LogInfo   = "info"
+	LogSilent = "silent"
+)
+
+// Config is the configuration of the RapidoDB server
+type Config struct {
+	// Listen is the address on which the TCP server listens
+	Listen string `json:"listen"`
+
+	// RESPListen is the address on which the server speaks
+	// the Redis protocol, it is disabled if empty
+	RESPListen string `json:"resp_listen"`
+
+	// DataDir is the directory where the data is persisted
+	// nothing is persisted if it is empty
+	DataDir string `json:"data_dir"`
+
+	// JanitorInterval is the interval at which expired items are removed
+	JanitorInterval Duration `json:"janitor_interval"`
+
+	// PersistInterval is the interval at which snapshots are written
+	PersistInterval Duration `json:"persist_interval"`
+
+	// Fsync is the fsync policy of the write-ahead log
+	Fsync string `json:"fsync"`
+
+	// DefaultTTL is the expiry of items stored without an explicit one
+	DefaultTTL Duration `json:"default_ttl"`
+
+	// MaxClients is the maximum number of concurrently
+	// connected clients, zero means unlimited
+	MaxClients int `json:"max_clients"`
+
+	// MaxRequestSizeMB is the maximum size in megabytes of a request
+	// of any protocol, the clients sending larger requests are
+	// disconnected
+	MaxRequestSizeMB int `json:"max_request_size_mb"`
+
+	// LogLevel is one of debug, info or silent
+	LogLevel string `json:"log_level"`
+
+	// AuthMaxFailures is the number of failed authentication attempts
+	// of a user or an address after which it is locked out, zero
+	// disables the lockouts
+	AuthMaxFailures int `json:"auth_max_failures"`
+
+	// AuthLockout is the duration of the first lockout, every further
+	// failure doubles it up to AuthMaxLockout
+	AuthLockout    Duration `json:"auth_lockout"`
+	AuthMaxLockout Duration `json:"auth_max_lockout"`
+
+	// AuditFile is the file the audit log is written to
+	// nothing is audited if it is empty
+	AuditFile string `json:"audit_file"`
+
+	// AuditMaxSizeMB is the size in megabytes after which the audit
+	// log is rotated, AuditMaxBackups rotated logs are kept
+	AuditMaxSizeMB  int `json:"audit_max_size_mb"`
+	AuditMaxBackups int `json:"audit_max_backups"`
+
+	// AuditReads enables auditing the reads like GET
+	AuditReads bool `json:"audit_reads"`
+
+	// TLSCert and TLSKey are the PEM encoded certificate and private
+	// key of the server, the connections are not encrypted if empty
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
+
+	// TLSClientCA holds the PEM encoded certificates of the
+	// authorities which issue the client certificates
+	TLSClientCA string `json:"tls_client_ca"`
+
+	// TLSClientAuth is one of none, optional or require
+	TLSClientAuth string `json:"tls_client_auth"`
+
+	// TLSUsers maps the subjects of the client certificates to the
+	// users. Without it a client is authenticated as the user named by
+	// the common name of its certificate, with it the certificates which
+	// aren't mapped are rejected
+	TLSUsers map[string]string `json:"tls_users,omitempty"`
+
+	// Admin is the bootstrap admin user
+	Admin User `json:"admin"`
+
+	// Users are the additional users created on startup
+	Users []User `json:"users"`
+}
+
+// User is a user created on startup
+type User struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	Access   uint   `json:"access"`
+}
+
+// Duration is a time.Duration which is written
+// as a string like "10s" in the configuration file
+type Duration time.Duration
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decodes the duration from a string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\"")
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// Default returns the default configuration
+func Default() Config {
+	return Config{
+		Listen:           DefaultListen,
+		JanitorInterval:  Duration(store.DefaultJanitorInterval),
+		PersistInterval:  Duration(store.DefaultPersistorInterval),
+		Fsync:            store.FsyncEverySec.String(),
+		DefaultTTL:       Duration(store.NeverExpire),
+		MaxRequestSizeMB: DefaultMaxRequestSizeMB,
+		LogLevel:         LogInfo,
+		AuthMaxFailures:  DefaultAuthMaxFailures,
+		AuthLockout:      Duration(DefaultAuthLockout),
+		AuthMaxLockout:   Duration(DefaultAuthMaxLockout),
+		AuditMaxSizeMB:   DefaultAuditMaxSizeMB,
+		AuditMaxBackups:  DefaultAuditMaxBackups,
+		TLSClientAuth:    TLSClientAuthNone,
+		Admin:            User{DefaultUser, DefaultPass, maxAccess},
+	}
+}
+
+// Load assembles the configuration on top of the passed defaults from
+// the configuration file, the environment and the command line args and
+// validates it. getenv is used to read the environment variables
+//
+// It returns the path of the configuration file that was used, which
+// is empty if no configuration file was used
+func Load(defaults Config, args []string, getenv func(string) string) (Config, string, error) {
+	cfg := defaults
+
+	fs := flag.NewFlagSet("rapido", flag.ContinueOnError)
+	path := fs.String("config", getenv("RAPIDO_CONFIG"), "path of the configuration file")
+	fs.String("listen", "", "address on which the server listens")
+	fs.String("resp-listen", "", "address on which the server speaks the Redis protocol, empty disables it")
+	fs.String("data-dir", "", "directory where the data is persisted")
+	fs.String("janitor-interval", "", "interval at which expired items are removed")
+	fs.String("persist-interval", "", "interval at which snapshots are written")
+	fs.String("fsync", "", "fsync policy of the write-ahead log: always, everysec or never")
+	fs.String("default-ttl", "", "expiry of the items stored without an explicit one")
+	fs.String("max-clients", "", "maximum number of connected clients, 0 is unlimited")
+	fs.String("max-request-size-mb", "", "maximum size in megabytes of a request")
+	fs.String("log-level", "", "log level: debug, info or silent")
+	fs.String("auth-max-failures", "", "failed authentications after which a client is locked out, 0 disables lockouts")
+	fs.String("auth-lockout", "", "duration of the first lockout")
+	fs.String("auth-max-lockout", "", "duration of the longest lockout")
+	fs.String("audit-file", "", "file the audit log is written to, empty disables auditing")
+	fs.String("audit-max-size-mb", "", "size in megabytes after which the audit log is rotated")
+	fs.String("audit-max-backups", "", "number of rotated audit logs which are kept")
+	fs.String("audit-reads", "", "audit the reads as well: true or false")
+	fs.String("tls-cert", "", "certificate file of the server, empty disables TLS")
+	fs.String("tls-key", "", "private key file of the server")
+	fs.String("tls-client-ca", "", "file of the authorities which issue the client certificates")
+	fs.String("tls-client-auth", "", "client certificate policy: none, optional or require")
+	fs.String("user", "", "username of the bootstrap admin")
+	fs.String("pass", "", "password of the bootstrap admin")
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, "", err
+	}
+
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return cfg, *path, err
+		}
+	}
+
+	var errs []string
+
+	// Environment variables
+	if port := getenv("RAPIDO_PORT"); port != "" {
+		cfg.Listen = ":" + port
+	}
+	for _, o := range options {
+		if v := getenv(o.env); v != "" {
+			if err := o.set(&cfg, v); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", o.env, err))
+			}
+		}
+	}
+
+	// Command line flags, only the ones which were passed
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.flag == f.Name {
+				if err := o.set(&cfg, f.Value.String()); err != nil {
+					errs = append(errs, fmt.Sprintf("-%s: %s", o.flag, err))
+				}
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return cfg, *path, errors.New(strings.Join(errs, "\n"))
+	}
+
+	return cfg, *path, cfg.Validate()
+}
+
+// loadFile reads the configuration file on top of the configuration
+func (cfg *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// Validate reports every invalid value in the configuration
+func (cfg Config) Validate() error {
+	var errs []string
+	add := func(format string, a ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, a...))
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+		add("listen: invalid address %q: %s", cfg.Listen, err)
+	}
+	if cfg.RESPListen != "" {
+		if _, _, err := net.SplitHostPort(cfg.RESPListen); err != nil {
+			add("resp_listen: invalid address %q: %s", cfg.RESPListen, err)
+		} else if cfg.RESPListen == cfg.Listen {
+			add("resp_listen: must differ from listen")
+		}
+	}
+	if cfg.JanitorInterval <= 0 {
+		add("janitor_interval: must be greater than 0")
+	}
+	if cfg.PersistInterval <= 0 {
+		add("persist_interval: must be greater than 0")
+	}
+	if _, err := store.ParseFsyncPolicy(cfg.Fsync); err != nil {
+		add("fsync: %s", err)
+	}
+	if cfg.DefaultTTL < 0 {
+		add("default_ttl: must not be negative")
+	}
+	if cfg.MaxClients < 0 {
+		add("max_clients: must not be negative")
+	}
+	if cfg.MaxRequestSizeMB <= 0 {
+		add("max_request_size_mb: must be greater than 0")
+	}
+	if cfg.AuthMaxFailures < 0 {
+		add("auth_max_failures: must not be negative")
+	}
+	if cfg.AuthLockout <= 0 {
+		add("auth_lockout: must be greater than 0")
+	}
+	if cfg.AuthMaxLockout < cfg.AuthLockout {
+		add("auth_max_lockout: must not be less than auth_lockout")
+	}
+	if cfg.AuditMaxSizeMB < 0 {
+		add("audit_max_size_mb: must not be negative")
+	}
+	if cfg.AuditMaxBackups < 0 {
+		add("audit_max_backups: must not be negative")
+	}
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		add("tls_cert: tls_cert and tls_key must be set together")
+	}
+	switch cfg.TLSClientAuth {
+	case TLSClientAuthNone:
+	case TLSClientAuthOptional, TLSClientAuthRequire:
+		if cfg.TLSClientCA == "" || cfg.TLSCert == "" {
+			add("tls_client_auth: %s needs tls_cert and tls_client_ca", cfg.TLSClientAuth)
+		}
+	default:
+		add("tls_client_auth: invalid policy %q, valid policies are none, optional and require", cfg.TLSClientAuth)
+	}
+	switch cfg.LogLevel {
+	case LogDebug, LogInfo, LogSilent:
+	default:
+		add("log_level: invalid level %q, valid levels are debug, info and silent", cfg.LogLevel)
+	}
+
+	seen := map[string]bool{}
+	for i, u := range append([]User{cfg.Admin}, cfg.Users...) {
+		field := "admin"
+		if i > 0 {
+			field = fmt.Sprintf("users[%d]", i-1)
+		}
+
+		if u.Username == "" || u.Password == "" {
+			add("%s: username and password are required", field)
+		}
+		if u.Access > maxAccess {
+			add("%s: access must be between 0 and %d", field, maxAccess)
+		}
+		if seen[u.Username] {
+			add("%s: duplicate username %q", field, u.Username)
+		}
+		seen[u.Username] = true
+	}
+
+	if len(errs) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+// FsyncPolicy returns the parsed fsync policy
+func (cfg Config) FsyncPolicy() store.FsyncPolicy {
+	p, _ := store.ParseFsyncPolicy(cfg.Fsync)
+	return p
+}
+
+// Values returns the settings of the configuration as strings keyed
+// by their names in the configuration file. Users are not included
+func (cfg Config) Values() map[string]string {
+	values := make(map[string]string)
+	for _, o := range options {
+		if o.get != nil {
+			values[o.name] = o.get(cfg)
+		}
+	}
+
+	return values
+}
+
+// Set changes the setting with the passed name, which is the name of the
+// setting in the configuration file, and validates the configuration. The
+// configuration is left untouched if the value is invalid
+func (cfg *Config) Set(name, value string) error {
+	for _, o := range options {
+		if o.name != name || o.get == nil {
+			continue
+		}
+
+		updated := *cfg
+		if err := o.set(&updated, value); err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+
+		if err := updated.Validate(); err != nil {
+			return err
+		}
+
+		*cfg = updated
+		return nil
+	}
+
+	return fmt.Errorf("Unknown setting %s", name)
+}
+
+// Write writes the configuration as a JSON document to the path. The
+// document is written to a temporary file first which is then renamed
+// so that a failed write never leaves a truncated configuration behind
+//
+// The passwords are the ones of the file being replaced, if any, so that
+// the passwords passed through the environment or the command line are
+// never written in plain text
+func (cfg Config) Write(path string) error {
+	var stored struct {
+		Admin User   `json:"admin"`
+		Users []User `json:"users"`
+	}
+	if b, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(b, &stored)
+	}
+
+	passwords := make(map[string]string, len(stored.Users))
+	for _, u := range stored.Users {
+		passwords[u.Username] = u.Password
+	}
+
+	cfg.Admin.Password = stored.Admin.Password
+	cfg.Users = append([]User(nil), cfg.Users...)
+	for i := range cfg.Users {
+		cfg.Users[i].Password = passwords[cfg.Users[i].Username]
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// option describes a setting which can be overridden by
+// an environment variable and a command line flag
+type option struct {
+	// name of the setting in the configuration file, settings
+	// without a getter are not exposed by Values and Set
+	name string
+	env  string
+	flag string
+	get  func(cfg Config) string
+	set  func(cfg *Config, v string) error
+}
+
+// options are the settings which can be overridden
+var options = []option{
+	{"listen", "RAPIDO_LISTEN", "listen",
+		func(cfg Config) string { return cfg.Listen },
+		func(cfg *Config, v string) error {
+			cfg.Listen = v
+			return nil
+		}},
+	{"resp_listen", "RAPIDO_RESP_LISTEN", "resp-listen",
+		func(cfg Config) string { return cfg.RESPListen },
+		func(cfg *Config, v string) error {
+			cfg.RESPListen = v
+			return nil
+		}},
+	{"data_dir", "RAPIDO_DATA_DIR", "data-dir",
+		func(cfg Config) string { return cfg.DataDir },
+		func(cfg *Config, v string) error {
+			cfg.DataDir = v
+			return nil
+		}},
+	{"janitor_interval", "RAPIDO_JANITOR_INTERVAL", "janitor-interval",
+		func(cfg Config) string { return time.Duration(cfg.JanitorInterval).String() },
+		func(cfg *Config, v string) error {
+			return setDuration(&cfg.JanitorInterval, v)
+		}},
+	{"persist_interval", "RAPIDO_PERSIST_INTERVAL", "persist-interval",
+		func(cfg Config) string { return time.Duration(cfg.PersistInterval).String() },
+		func(cfg *Config, v string) error {
+			return setDuration(&cfg.PersistInterval, v)
+		}},
+	{"fsync", "RAPIDO_FSYNC", "fsync",
+		func(cfg Config) string { return cfg.Fsync },
+		func(cfg *Config, v string) error {
+			cfg.Fsync = strings.ToLower(v)
+			return nil
+		}},
+	{"default_ttl", "RAPIDO_DEFAULT_TTL", "default-ttl",
+		func(cfg Config) string { return time.Duration(cfg.DefaultTTL).String() },
+		func(cfg *Config, v string) error {
+			return setDuration(&cfg.DefaultTTL, v)
+		}},
+	{"max_clients", "RAPIDO_MAX_CLIENTS", "max-clients",
+		func(cfg Config) string { return strconv.Itoa(cfg.MaxClients) },
+		func(cfg *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", v)
+			}
+			cfg.MaxClients = n
+			return nil
+		}},
+	{"max_request_size_mb", "RAPIDO_MAX_REQUEST_SIZE_MB", "max-request-size-mb",
+		func(cfg Config) string { return strconv.Itoa(cfg.MaxRequestSizeMB) },
+		func(cfg *Config, v string) error {
+			return setInt(&cfg.MaxRequestSizeMB, v)
+		}},
+	{"log_level", "RAPIDO_LOG_LEVEL", "log-level",
+		func(cfg Config) string { return cfg.LogLevel },
+		func(cfg *Config, v string) error {
+			cfg.LogLevel = strings.ToLower(v)
+			return nil
+		}},
+	{"auth_max_failures", "RAPIDO_AUTH_MAX_FAILURES", "auth-max-failures",
+		func(cfg Config) string { return strconv.Itoa(cfg.AuthMaxFailures) },
+		func(cfg *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", v)
+			}
+			cfg.AuthMaxFailures = n
+			return nil
+		}},
+	{"auth_lockout", "RAPIDO_AUTH_LOCKOUT", "auth-lockout",
+		func(cfg Config) string { return time.Duration(cfg.AuthLockout).String() },
+		func(cfg *Config, v string) error {
+			return setDuration(&cfg.AuthLockout, v)
+		}},
+	{"auth_max_lockout", "RAPIDO_AUTH_MAX_LOCKOUT", "auth-max-lockout",
+		func(cfg Config) string { return time.Duration(cfg.AuthMaxLockout).String() },
+		func(cfg *Config, v string) error {
+			return setDuration(&cfg.AuthMaxLockout, v)
+		}},
+	{"audit_file", "RAPIDO_AUDIT_FILE", "audit-file",
+		func(cfg Config) string { return cfg.AuditFile },
+		func(cfg *Config, v string) error {
+			cfg.AuditFile = v
+			return nil
+		}},
+	{"audit_max_size_mb", "RAPIDO_AUDIT_MAX_SIZE_MB", "audit-max-size-mb",
+		func(cfg Config) string { return strconv.Itoa(cfg.AuditMaxSizeMB) },
+		func(cfg *Config, v string) error {
+			return setInt(&cfg.AuditMaxSizeMB, v)
+		}},
+	{"audit_max_backups", "RAPIDO_AUDIT_MAX_BACKUPS", "audit-max-backups",
+		func(cfg Config) string { return strconv.Itoa(cfg.AuditMaxBackups) },
+		func(cfg *Config, v string) error {
+			return setInt(&cfg.AuditMaxBackups, v)
+		}},
+	{"audit_reads", "RAPIDO_AUDIT_READS", "audit-reads",
+		func(cfg Config) string { return strconv.FormatBool(cfg.AuditReads) },
+		func(cfg *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			cfg.AuditReads = b
+			return nil
+		}},
+	{"tls_cert", "RAPIDO_TLS_CERT", "tls-cert",
+		func(cfg Config) string { return cfg.TLSCert },
+		func(cfg *Config, v string) error {
+			cfg.TLSCert = v
+			return nil
+		}},
+	{"tls_key", "RAPIDO_TLS_KEY", "tls-key",
+		func(cfg Config) string { return cfg.TLSKey },
+		func(cfg *Config, v string) error {
+			cfg.TLSKey = v
+			return nil
+		}},
+	{"tls_client_ca", "RAPIDO_TLS_CLIENT_CA", "tls-client-ca",
+		func(cfg Config) string { return cfg.TLSClientCA },
+		func(cfg *Config, v string) error {
+			cfg.TLSClientCA = v
+			return nil
+		}},
+	{"tls_client_auth", "RAPIDO_TLS_CLIENT_AUTH", "tls-client-auth",
+		func(cfg Config) string { return cfg.TLSClientAuth },
+		func(cfg *Config, v string) error {
+			cfg.TLSClientAuth = strings.ToLower(v)
+			return nil
+		}},
+	{"admin.username", "RAPIDO_USER", "user", nil,
+		func(cfg *Config, v string) error {
+			cfg.Admin.Username = v
+			return nil
+		}},
+	{"admin.password", "RAPIDO_PASS", "pass", nil,
+		func(cfg *Config, v string) error {
+			cfg.Admin.Password = v
+			return nil
+		}},
+}
+
+// setInt parses the number into n
+func setInt(n *int, v string) error {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+
+	*n = i
+	return nil
+}
+
+// setDuration parses the duration into d
+func setDuration(d *Duration, v string) error {
+	p, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(p)
+	return nil
+}
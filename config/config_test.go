@@ -0,0 +1,172 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rapido.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"listen": ":3000",
+		"data_dir": "/from/file",
+		"janitor_interval": "5s",
+		"max_clients": 10,
+		"users": [{"username": "reader", "password": "secret", "access": 1}]
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"RAPIDO_CONFIG":   path,
+		"RAPIDO_DATA_DIR": "/from/env",
+		"RAPIDO_FSYNC":    "always",
+		"RAPIDO_PORT":     "4000",
+	}
+
+	cfg, used, err := Load(Default(), []string{"-listen", ":5000", "-max-clients", "20"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if used != path {
+		t.Errorf("Load() used config %q, want %q", used, path)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"FLAG OVERRIDES ENV AND FILE", cfg.Listen, ":5000"},
+		{"FLAG OVERRIDES FILE", cfg.MaxClients, 20},
+		{"ENV OVERRIDES FILE", cfg.DataDir, "/from/env"},
+		{"ENV OVERRIDES DEFAULT", cfg.Fsync, "always"},
+		{"FILE OVERRIDES DEFAULT", cfg.JanitorInterval, Duration(5 * time.Second)},
+		{"DEFAULT IS KEPT", cfg.PersistInterval, Duration(time.Minute)},
+		{"DEFAULT ADMIN IS KEPT", cfg.Admin.Username, DefaultUser},
+		{"USERS FROM FILE", len(cfg.Users), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Default()
+
+	invalid := Default()
+	invalid.Listen = "2310"
+	invalid.Fsync = "sometimes"
+	invalid.LogLevel = "loud"
+	invalid.MaxClients = -1
+	invalid.Users = []User{{DefaultUser, "pass", 9}}
+
+	lockout := Default()
+	lockout.AuthMaxLockout = Duration(DefaultAuthLockout / 2)
+
+	tls := Default()
+	tls.TLSCert, tls.TLSKey, tls.TLSClientCA = "server.crt", "server.key", "ca.crt"
+	tls.TLSClientAuth = TLSClientAuthRequire
+
+	missingKey := tls
+	missingKey.TLSKey = ""
+
+	missingCA := tls
+	missingCA.TLSClientCA = ""
+
+	resp := Default()
+	resp.RESPListen = DefaultListen
+
+	request := Default()
+	request.MaxRequestSizeMB = 0
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"DEFAULT CONFIGURATION", valid, false},
+		{"INVALID CONFIGURATION", invalid, true},
+		{"MAX LOCKOUT SHORTER THAN LOCKOUT", lockout, true},
+		{"TLS WITH CLIENT CERTIFICATES", tls, false},
+		{"TLS CERTIFICATE WITHOUT KEY", missingKey, true},
+		{"CLIENT CERTIFICATES WITHOUT AUTHORITY", missingCA, true},
+		{"RESP ON THE RQL ADDRESS", resp, true},
+		{"NO MAXIMUM REQUEST SIZE", request, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rapido.json")
+	err = ioutil.WriteFile(path, []byte(`{"users": [{"username": "reader", "password": "secret", "access": 1}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"RAPIDO_CONFIG": path, "RAPIDO_PASS": "from-env"}
+	cfg, _, err := Load(Default(), []string{"-pass", "from-flag"}, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cfg.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"PASSWORD OF THE FILE IS KEPT", "secret", true},
+		{"PASSWORD OF THE ENVIRONMENT IS LEFT OUT", "from-env", false},
+		{"PASSWORD OF THE FLAGS IS LEFT OUT", "from-flag", false},
+		{"DEFAULT PASSWORD IS LEFT OUT", `"` + DefaultPass + `"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Contains(string(b), tt.password); got != tt.want {
+				t.Errorf("written configuration holds %s = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+
+	// The rewritten configuration is still valid
+	if _, _, err := Load(Default(), []string{"-config", path}, func(string) string { return "" }); err != nil {
+		t.Errorf("Load() of the written configuration error = %v", err)
+	}
+}
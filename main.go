@@ -1,35 +1,112 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	db "github.com/utkarsh-pro/RapidoDB/DB"
+	"github.com/utkarsh-pro/RapidoDB/config"
 )
 
 const (
-	// Default port for the TCP server
-	defaultPort = "2310"
-	// Default password for the server
-	defaultPass = "pass"
-	// Default username for the server
-	defaultUser = "admin"
+	// Time given to the server to shut down gracefully
+	shutdownTimeout = 30 * time.Second
 )
 
 func main() {
-	// Get the port from the environment
-	PORT := getEnv("RAPIDO_PORT", defaultPort)
-	PASS := getEnv("RAPIDO_PASS", defaultPass)
-	USER := getEnv("RAPIDO_USER", defaultUser)
-	BACKUP := getEnv("HOME", "")
+	// The data is persisted in the home directory unless configured otherwise
+	defaults := config.Default()
+	defaults.DataDir = getEnv("HOME", "")
+
+	cfg, path, err := config.Load(defaults, os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
 	// Print the RapidoDB logo
 	fmt.Printf(db.RapidoMSG)
 
-	database := db.New(log.New(os.Stdout, "[RAPIDO DB]: ", log.LstdFlags), PORT, USER, PASS, BACKUP)
+	logger := newLogger(cfg.LogLevel)
+	if path != "" {
+		logger.Println("Loaded configuration from", path)
+	}
+
+	database, err := db.NewFromConfig(logger, cfg, path)
+	if err != nil {
+		logger.Fatalf("Failed to start the database: %s", err)
+	}
+
+	// Shutdown the database gracefully on SIGINT and SIGTERM
+	done := handleSignals(logger, database)
+
+	// Reload the TLS certificates on SIGHUP
+	if cfg.TLSCert != "" {
+		handleReload(logger, database)
+	}
 
 	database.Run()
+
+	// Wait for the shutdown to complete
+	<-done
+}
+
+// newLogger returns the logger for the passed log level
+func newLogger(level string) *log.Logger {
+	switch level {
+	case config.LogSilent:
+		return log.New(ioutil.Discard, "", 0)
+	case config.LogDebug:
+		return log.New(os.Stdout, "[RAPIDO DB]: ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	default:
+		return log.New(os.Stdout, "[RAPIDO DB]: ", log.LstdFlags)
+	}
+}
+
+// handleSignals shuts down the database once SIGINT or SIGTERM
+// is received. The returned channel is closed once the database
+// has been shut down
+func handleSignals(logger *log.Logger, database *db.RapidoDB) <-chan struct{} {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		sig := <-sigs
+		logger.Println("Received", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := database.Shutdown(ctx); err != nil {
+			logger.Println("Shutdown failed:", err)
+		}
+
+		close(done)
+	}()
+
+	return done
+}
+
+// handleReload reloads the TLS certificates of the
+// database every time SIGHUP is received
+func handleReload(logger *log.Logger, database *db.RapidoDB) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	go func() {
+		for range sigs {
+			if err := database.ReloadTLS(); err != nil {
+				logger.Println("Failed to reload the TLS certificates:", err)
+			}
+		}
+	}()
 }
 
 // getEnv is a thin wrapper over os.GetEnv. It replaces read
@@ -3,6 +3,11 @@ package manage
 import "fmt"
 
 // Access type indicates the available access types
+//
+// Access levels are ordered, each level includes the permissions of
+// the levels below it. They are kept for compatibility, permissions
+// are granted through roles otherwise. The permissions of a level are
+// returned by the Permissions method
 type Access uint
 
 const (
@@ -43,3 +48,21 @@ func ConvertUintToAccess(access uint) (Access, error) {
 
 	return Access(access), nil
 }
+
+// Permissions returns the permissions included in the access level
+func (a Access) Permissions() Permission {
+	switch {
+	case a >= AdminAccess:
+		return AdminPermission
+	case a >= WipeAccess:
+		return ReadPermission | WritePermission | DeletePermission | ManageUsersPermission | WipePermission
+	case a >= ModifyUserAccess:
+		return ReadPermission | WritePermission | DeletePermission | ManageUsersPermission
+	case a >= WriteAccess:
+		return ReadPermission | WritePermission | DeletePermission
+	case a >= ReadAccess:
+		return ReadPermission
+	default:
+		return NoPermission
+	}
+}
@@ -0,0 +1,66 @@
+package manage
+
+// Rule scopes the permissions of a user to the keys matching
+// the pattern. A user without any rules can access every key
+// whereas a user with rules can only access the keys matched
+// by a rule granting the required permission
+//
+// Patterns are globs where "*" matches any sequence of characters
+// and "?" matches any single character, e.g. "tenantA:*"
+type Rule struct {
+	Pattern     string
+	Permissions Permission
+}
+
+// Matches returns true if the key matches the pattern of the rule
+func (r Rule) Matches(key string) bool {
+	return matchKey(r.Pattern, key)
+}
+
+// allowsKey returns true if the rules allow performing
+// the operation requiring req on the key
+func allowsKey(rules []Rule, req Permission, key string) bool {
+	if len(rules) == 0 {
+		return true
+	}
+
+	for _, r := range rules {
+		if r.Matches(key) && r.Permissions.Has(req) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchKey reports whether the key matches the glob pattern. Unlike
+// path.Match a "*" matches the separators too as keys have no structure
+func matchKey(pattern, key string) bool {
+	// Position to resume from when a "*" has to match more characters
+	star, resume := -1, 0
+
+	p, k := 0, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, k
+			p++
+		case star >= 0:
+			// Let the last "*" match one more character
+			resume++
+			p, k = star+1, resume
+		default:
+			return false
+		}
+	}
+
+	// Trailing stars match the empty string
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
@@ -0,0 +1,108 @@
+package manage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_matchKey(t *testing.T) {
+	type args struct {
+		pattern string
+		key     string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"PREFIX PATTERN", args{"tenantA:*", "tenantA:users/1"}, true},
+		{"PREFIX PATTERN WITH OTHER PREFIX", args{"tenantA:*", "tenantB:users"}, false},
+		{"PREFIX PATTERN WITH PREFIX ITSELF", args{"tenantA:*", "tenantA:"}, true},
+		{"EXACT PATTERN", args{"config", "config"}, true},
+		{"EXACT PATTERN WITH LONGER KEY", args{"config", "configs"}, false},
+		{"SINGLE CHARACTER", args{"k?", "k1"}, true},
+		{"SINGLE CHARACTER WITH EMPTY", args{"k?", "k"}, false},
+		{"INNER STAR", args{"a*:b*", "a1:2:b3"}, true},
+		{"INNER STAR WITHOUT MATCH", args{"a*:b", "a1:c"}, false},
+		{"STAR ONLY", args{"*", ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchKey(tt.args.pattern, tt.args.key); got != tt.want {
+				t.Errorf("matchKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureDB_Rules(t *testing.T) {
+	db := &MockDB{make(map[string]interface{})}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: db, userdb: udb}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	manager := NewTrustedSession("manager", ManageUsersPermission|ReadPermission)
+
+	if err := udb.New("team", "pass", WipeAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	team, err := sdb.Authenticate(NewSession(""), "team", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := udb.New("root", "pass", AdminAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := udb.New("viewer", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"WRITE WITHOUT RULES", func() error { return sdb.Set(team, "other", 1, 0) }, false},
+		{"ADD READ WRITE RULE", func() error { return sdb.AddRule(admin, "team", "tenantA:*", []string{"read", "write"}) }, false},
+		{"ADD READ ONLY RULE", func() error { return sdb.AddRule(admin, "team", "config:*", []string{"read"}) }, false},
+		{"ADD RULE FOR HIGHER RANKED USER", func() error { return sdb.AddRule(manager, "root", "x:*", []string{"read"}) }, true},
+		{"DELETE RULE OF HIGHER RANKED USER", func() error { return sdb.DeleteRule(manager, "team", "config:*") }, true},
+		{"ADD RULE FOR LOWER RANKED USER", func() error { return sdb.AddRule(manager, "viewer", "x:*", []string{"read"}) }, false},
+		{"DELETE RULE OF LOWER RANKED USER", func() error { return sdb.DeleteRule(manager, "viewer", "x:*") }, false},
+		{"ADD RULE WITH PERMISSIONS NOT HELD", func() error { return sdb.AddRule(manager, "team", "x:*", []string{"write"}) }, true},
+		{"ADD RULE FOR UNKNOWN USER", func() error { return sdb.AddRule(admin, "nobody", "x:*", []string{"read"}) }, true},
+		{"ADD RULE WITHOUT MANAGE USERS", func() error { return sdb.AddRule(team, "team", "x:*", []string{"read"}) }, true},
+		{"WRITE IN SCOPE", func() error { return sdb.Set(team, "tenantA:k1", 1, 0) }, false},
+		{"WRITE OUT OF SCOPE", func() error { return sdb.Set(team, "tenantB:k1", 1, 0) }, true},
+		{"WRITE IN READ ONLY SCOPE", func() error { return sdb.Set(team, "config:k1", 1, 0) }, true},
+		{"READ IN READ ONLY SCOPE", func() error { _, _, err := sdb.Get(team, "config:k1"); return err }, false},
+		{"READ OUT OF SCOPE", func() error { _, _, err := sdb.Get(team, "other"); return err }, true},
+		{"DELETE WITHOUT DELETE RULE", func() error { _, _, err := sdb.Delete(team, "tenantA:k1"); return err }, true},
+		{"WIPE WITH RULES", func() error { return sdb.Wipe(team) }, true},
+		{"REPLACE RULE", func() error { return sdb.AddRule(admin, "team", "tenantA:*", []string{"delete"}) }, false},
+		{"DELETE WITH REPLACED RULE", func() error { _, _, err := sdb.Delete(team, "tenantA:k1"); return err }, false},
+		{"DELETE RULE", func() error { return sdb.DeleteRule(admin, "team", "config:*") }, false},
+		{"DELETE UNKNOWN RULE", func() error { return sdb.DeleteRule(admin, "team", "config:*") }, true},
+	}
+	for _, st := range steps {
+		if err := st.op(); (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+	}
+
+	rules, err := sdb.Rules(manager, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Rule{{"tenantA:*", DeletePermission}}; !reflect.DeepEqual(rules, want) {
+		t.Errorf("SecureDB.Rules() = %v, want %v", rules, want)
+	}
+
+	// The user can access every key again once the last rule is removed
+	if err := sdb.DeleteRule(admin, "team", "tenantA:*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sdb.Wipe(team); err != nil {
+		t.Errorf("SecureDB.Wipe() without rules error = %v", err)
+	}
+}
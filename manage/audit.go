@@ -0,0 +1,82 @@
+package manage
+
+import "time"
+
+// Outcomes of the audited operations
+const (
+	AuditOK     = "ok"
+	AuditDenied = "denied"
+	AuditFailed = "failed"
+)
+
+// AuditRecord describes an operation performed on the SecureDB
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Username   string    `json:"username,omitempty"`
+
+	// Command is the RQL command of the operation like "SET" or "ACL ADD"
+	Command string `json:"command"`
+
+	// Targets are the keys, the users, the roles or the
+	// settings the operation was performed on
+	Targets []string `json:"targets,omitempty"`
+
+	// Outcome is one of AuditOK, AuditDenied and AuditFailed
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+
+	// Read is true if the operation didn't change anything
+	Read bool `json:"-"`
+}
+
+// Auditor is the interface of the audit log which records
+// the operations performed on the SecureDB
+type Auditor interface {
+	// Audit should record the operation, it must not block
+	// for long as it is called on every operation
+	Audit(r AuditRecord)
+}
+
+// SetAuditor sets the audit log which records every operation
+// performed on the SecureDB. It should be set before the SecureDB
+// is used, nothing is recorded if it is nil
+func (sdb *SecureDB) SetAuditor(a Auditor) {
+	sdb.auditor = a
+}
+
+// audit records a change made on behalf of the session
+func (sdb *SecureDB) audit(s *Session, command string, targets []string, err error) {
+	sdb.record(s, command, targets, err, false)
+}
+
+// auditRead records a read made on behalf of the session
+func (sdb *SecureDB) auditRead(s *Session, command string, targets []string, err error) {
+	sdb.record(s, command, targets, err, true)
+}
+
+// record passes the record of the operation to the auditor
+func (sdb *SecureDB) record(s *Session, command string, targets []string, err error, read bool) {
+	if sdb.auditor == nil {
+		return
+	}
+
+	r := AuditRecord{
+		Time:       time.Now(),
+		RemoteAddr: s.RemoteAddr(),
+		Username:   s.Username(),
+		Command:    command,
+		Targets:    targets,
+		Outcome:    AuditOK,
+		Read:       read,
+	}
+
+	if err != nil {
+		r.Outcome, r.Error = AuditFailed, err.Error()
+		if err == errAccessDenied {
+			r.Outcome = AuditDenied
+		}
+	}
+
+	sdb.auditor.Audit(r)
+}
@@ -0,0 +1,55 @@
+package manage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecureDB_Audit(t *testing.T) {
+	db := &MockDB{make(map[string]interface{})}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: db, userdb: udb}
+	auditor := &MockAuditor{}
+	sdb.SetAuditor(auditor)
+	sdb.SetLockoutPolicy(LockoutPolicy{MaxFailures: 1, Lockout: DefaultLockoutPolicy.Lockout, MaxLockout: DefaultLockoutPolicy.MaxLockout})
+
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := sdb.Authenticate(NewSession("10.0.0.1:5000"), "bob", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sdb.Get(bob, "k1")
+	sdb.Wipe(bob)
+	sdb.Authenticate(NewSession("10.0.0.2:5000"), "alice", "wrong")
+
+	type record struct {
+		remote, username, command string
+		targets                   []string
+		outcome                   string
+		read                      bool
+	}
+
+	want := []record{
+		{"10.0.0.1:5000", "", "AUTH", []string{"bob"}, AuditOK, false},
+		{"10.0.0.1:5000", "bob", "GET", []string{"k1"}, AuditOK, true},
+		{"10.0.0.1:5000", "bob", "WIPE", nil, AuditDenied, false},
+		{"10.0.0.2:5000", "", "LOCKOUT", []string{"user:alice"}, AuditOK, false},
+		{"10.0.0.2:5000", "", "LOCKOUT", []string{"addr:10.0.0.2"}, AuditOK, false},
+		{"10.0.0.2:5000", "", "AUTH", []string{"alice"}, AuditFailed, false},
+	}
+
+	var got []record
+	for _, r := range auditor.records {
+		if r.Time.IsZero() {
+			t.Errorf("record %s has no time", r.Command)
+		}
+		got = append(got, record{r.RemoteAddr, r.Username, r.Command, r.Targets, r.Outcome, r.Read})
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("audit records = %+v, want %+v", got, want)
+	}
+}
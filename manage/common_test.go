@@ -1,6 +1,9 @@
 package manage
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Mock database
 type MockDB struct {
@@ -12,6 +15,16 @@ func (db *MockDB) Set(key string, data interface{}, _ time.Duration) {
 	db.db[key] = data
 }
 
+// Mock SetIf
+func (db *MockDB) SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) bool {
+	if !cond(db.Get) {
+		return false
+	}
+
+	db.Set(key, data, expireIn)
+	return true
+}
+
 // Mock Get
 func (db *MockDB) Get(key string) (interface{}, bool) {
 	item, ok := db.db[key]
@@ -22,6 +35,36 @@ func (db *MockDB) Get(key string) (interface{}, bool) {
 	return item, true
 }
 
+// Mock GetVersion, the version of every item is 1
+func (db *MockDB) GetVersion(key string) (interface{}, uint64, bool) {
+	item, ok := db.Get(key)
+	if !ok {
+		return nil, 0, false
+	}
+
+	return item, 1, true
+}
+
+// Mock SetIfVersion, see GetVersion
+func (db *MockDB) SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool) {
+	if _, v, _ := db.GetVersion(key); v != version {
+		return 0, false
+	}
+
+	db.Set(key, data, expireIn)
+	return 1, true
+}
+
+// Mock DeleteIfVersion, see GetVersion
+func (db *MockDB) DeleteIfVersion(key string, version uint64) (interface{}, bool) {
+	if _, v, _ := db.GetVersion(key); v != version {
+		return nil, false
+	}
+
+	item, _ := db.Delete(key)
+	return item, true
+}
+
 // Mock Delete
 func (db *MockDB) Delete(key string) (interface{}, bool) {
 	item, ok := db.db[key]
@@ -33,6 +76,46 @@ func (db *MockDB) Delete(key string) (interface{}, bool) {
 	return item, true
 }
 
+// Mock DeleteIf
+func (db *MockDB) DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool) {
+	if !cond(db.Get) {
+		return nil, false
+	}
+
+	deleted := make([]interface{}, len(keys))
+	for i, key := range keys {
+		deleted[i], _ = db.Delete(key)
+	}
+
+	return deleted, true
+}
+
+// Mock ExpireAt, the items never expire
+func (db *MockDB) ExpireAt(key string) (time.Time, bool) {
+	_, ok := db.db[key]
+	return time.Time{}, ok
+}
+
+// Mock SetExpireAt
+func (db *MockDB) SetExpireAt(key string, expireAt time.Time) bool {
+	_, ok := db.db[key]
+	return ok
+}
+
+// Mock Incr, the values are expected to be int64
+func (db *MockDB) Incr(key string, delta int64) (int64, error) {
+	n, _ := db.db[key].(int64)
+	db.db[key] = n + delta
+	return n + delta, nil
+}
+
+// Mock IncrFloat, the values are expected to be float64
+func (db *MockDB) IncrFloat(key string, delta float64) (float64, error) {
+	f, _ := db.db[key].(float64)
+	db.db[key] = f + delta
+	return f + delta, nil
+}
+
 // Mock Wipe
 func (db *MockDB) Wipe() {
 	db.db = make(map[string]interface{})
@@ -42,3 +125,71 @@ func (db *MockDB) Wipe() {
 func (db *MockDB) DefaultExpiry() time.Duration {
 	return 0
 }
+
+// Mock Keys
+func (db *MockDB) Keys() []string {
+	keys := make([]string, 0, len(db.db))
+	for k := range db.db {
+		keys = append(keys, k)
+	}
+
+	return keys
+}
+
+// Mock database calling the hook once an item has been read
+type HookDB struct {
+	*MockDB
+	onGet func(key string)
+}
+
+// Mock Get
+func (db *HookDB) Get(key string) (interface{}, bool) {
+	item, ok := db.MockDB.Get(key)
+	if db.onGet != nil {
+		db.onGet(key)
+	}
+
+	return item, ok
+}
+
+// Mock settings
+type MockSettings struct {
+	values map[string]string
+}
+
+// Mock Get
+func (s *MockSettings) Get(pattern string) map[string]string {
+	values := make(map[string]string)
+	for k, v := range s.values {
+		if pattern == "*" || pattern == k {
+			values[k] = v
+		}
+	}
+
+	return values
+}
+
+// Mock Set
+func (s *MockSettings) Set(name, value string) error {
+	if _, ok := s.values[name]; !ok {
+		return fmt.Errorf("Unknown setting %s", name)
+	}
+
+	s.values[name] = value
+	return nil
+}
+
+// Mock Rewrite
+func (s *MockSettings) Rewrite() error {
+	return nil
+}
+
+// Mock auditor
+type MockAuditor struct {
+	records []AuditRecord
+}
+
+// Mock Audit
+func (a *MockAuditor) Audit(r AuditRecord) {
+	a.records = append(a.records, r)
+}
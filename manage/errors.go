@@ -0,0 +1,44 @@
+package manage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Codes of the errors returned by the SecureDB. Unlike the
+// messages of the errors, the clients can rely upon them
+const (
+	CodeDenied = "DENIED"
+	CodeAuth   = "AUTH"
+	CodeLocked = "LOCKED"
+)
+
+// Error is an error of the SecureDB which carries a code
+type Error struct {
+	code string
+	msg  string
+}
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Code returns the code of the error
+func (e *Error) Code() string {
+	return e.code
+}
+
+var (
+	// errAccessDenied is returned when a session lacks the permissions
+	errAccessDenied = &Error{CodeDenied, "Access denied"}
+
+	// errInvalidCredentials is returned when the password is wrong
+	errInvalidCredentials = &Error{CodeAuth, "Invalid Credentials"}
+)
+
+// lockedErr returns the error of a locked out client
+// which should retry after the duration
+func lockedErr(retry time.Duration) error {
+	return &Error{CodeLocked, fmt.Sprintf("Too many failed attempts, try again in %s", retry)}
+}
@@ -0,0 +1,39 @@
+package manage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestError_Code(t *testing.T) {
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: &MockDB{make(map[string]interface{})}, userdb: udb}
+	sdb.SetLockoutPolicy(LockoutPolicy{MaxFailures: 1, Lockout: time.Minute, MaxLockout: time.Minute})
+
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	auth := func() error {
+		_, err := sdb.Authenticate(NewSession("10.0.0.1:5000"), "bob", "wrong")
+		return err
+	}
+
+	tests := []struct {
+		name string
+		op   func() error
+		want string
+	}{
+		{"ACCESS DENIED", func() error { return sdb.Wipe(NewSession("")) }, CodeDenied},
+		{"INVALID CREDENTIALS", auth, CodeAuth},
+		{"LOCKED OUT", auth, CodeLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, ok := tt.op().(*Error)
+			if !ok || err.Code() != tt.want {
+				t.Errorf("error = %v, want code %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -29,6 +29,10 @@ const (
 	// WIPE event indicates a WIPE
 	// operation on the database
 	WIPE
+
+	// LOCKOUT event indicates that a user or an address
+	// got locked out after failing to authenticate
+	LOCKOUT
 )
 
 // ConvertStringToEvent takes an event as a string and returns
@@ -44,11 +48,19 @@ func ConvertStringToEvent(event string) (Event, error) {
 		return DEL, nil
 	case "wipe":
 		return WIPE, nil
+	case "lockout":
+		return LOCKOUT, nil
 	default:
 		return NULL, fmt.Errorf("Invalid event")
 	}
 }
 
+// carriesData returns true if the event carries the
+// key and the value which have been read or written
+func (e Event) carriesData() bool {
+	return e == GET || e == SET || e == DEL
+}
+
 // Events is the slice of event
 type Events []Event
 
@@ -86,14 +98,20 @@ func (e Events) Exists(event Event) (int, bool) {
 // convertInterfaceSliceToEvents will attempt to convert an
 // array of interfaces to Events object. It panics if the the
 // type assertion fails
+//
+// Events decoded from json are float64 hence those are
+// accepted as well
 func convertInterfaceSliceToEvents(ui []interface{}) Events {
 	var ev Events
 	for _, v := range ui {
-		d, ok := v.(uint)
-		if !ok {
+		switch d := v.(type) {
+		case uint:
+			ev = ev.Set(Event(d))
+		case float64:
+			ev = ev.Set(Event(d))
+		default:
 			panic("Cannot convert interface to Event")
 		}
-		ev = ev.Set(Event(d))
 	}
 
 	return ev
@@ -126,6 +126,11 @@ func Test_convertInterfaceSliceToEvents(t *testing.T) {
 			args{[]interface{}{uint(1), uint(2), uint(3)}},
 			Events{1, 2, 3},
 		},
+		{
+			"CONVERT A JSON DECODED INTERFACE SLICE TO EVENTS TYPE",
+			args{[]interface{}{float64(1), float64(4)}},
+			Events{1, 4},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
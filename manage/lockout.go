@@ -0,0 +1,246 @@
+package manage
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LockoutPolicy describes how the failed authentication attempts are
+// throttled. Once a user or an address has failed MaxFailures times in a
+// row it is locked out for Lockout, every further failure doubles the
+// duration of the lockout up to MaxLockout
+//
+// The failures are forgotten once MaxLockout has elapsed since the last
+// one. A MaxFailures of zero or less disables the lockout
+type LockoutPolicy struct {
+	MaxFailures int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+}
+
+// DefaultLockoutPolicy is the lockout policy used unless configured otherwise
+var DefaultLockoutPolicy = LockoutPolicy{
+	MaxFailures: 5,
+	Lockout:     time.Second,
+	MaxLockout:  5 * time.Minute,
+}
+
+// duration returns the duration of the lockout after the failures
+func (p LockoutPolicy) duration(failures int) time.Duration {
+	if p.MaxFailures <= 0 || failures < p.MaxFailures {
+		return 0
+	}
+
+	d := p.Lockout
+	for i := p.MaxFailures; i < failures && d < p.MaxLockout; i++ {
+		d *= 2
+	}
+
+	if d > p.MaxLockout {
+		d = p.MaxLockout
+	}
+
+	return d
+}
+
+// Lockout is the state of the failed authentication attempts of
+// a target, which is either "user:<username>" or "addr:<host>"
+type Lockout struct {
+	Target   string
+	Failures int
+
+	// Until is the time till which the target is locked out
+	// it is zero if the target isn't locked out
+	Until time.Time
+
+	// last is the time of the last failure
+	last time.Time
+}
+
+// Locked returns true if the target is locked out at the time
+func (l Lockout) Locked(now time.Time) bool {
+	return now.Before(l.Until)
+}
+
+// lockoutErr returns the error returned to the
+// clients which are locked out
+func lockoutErr(l Lockout, now time.Time) error {
+	retry := l.Until.Sub(now).Round(time.Second)
+	if retry < time.Second {
+		retry = time.Second
+	}
+
+	return lockedErr(retry)
+}
+
+// lockouts tracks the failed authentication attempts of the targets
+// The zero value is ready to use and has the lockout disabled
+type lockouts struct {
+	mu      sync.Mutex
+	policy  LockoutPolicy
+	entries map[string]*Lockout
+
+	// notify is called with every lockout which is triggered
+	notify func(Lockout)
+
+	// clock returns the current time, it is replaced by the tests
+	clock func() time.Time
+}
+
+// now returns the current time
+func (lo *lockouts) now() time.Time {
+	if lo.clock == nil {
+		return time.Now()
+	}
+
+	return lo.clock()
+}
+
+// authTargets returns the targets which are throttled when the client
+// of the session authenticates as the user. Addresses are tracked without
+// their port as every connection is made from a new port
+func authTargets(s *Session, username string) []string {
+	targets := []string{"user:" + username}
+
+	if addr := s.RemoteAddr(); addr != "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		targets = append(targets, "addr:"+addr)
+	}
+
+	return targets
+}
+
+// check returns an error if any of the targets is locked out
+func (lo *lockouts) check(targets []string) error {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	now := lo.now()
+	for _, t := range targets {
+		if l, ok := lo.entries[t]; ok && l.Locked(now) {
+			return lockoutErr(*l, now)
+		}
+	}
+
+	return nil
+}
+
+// fail records a failed attempt for every target and locks out the
+// targets which have failed too many times. It returns the lockouts
+// which were triggered
+func (lo *lockouts) fail(targets []string) []Lockout {
+	lo.mu.Lock()
+
+	now := lo.now()
+	lo.prune(now)
+
+	var triggered []Lockout
+	for _, t := range targets {
+		l, ok := lo.entries[t]
+		if !ok {
+			if lo.entries == nil {
+				lo.entries = make(map[string]*Lockout)
+			}
+
+			l = &Lockout{Target: t}
+			lo.entries[t] = l
+		}
+
+		l.Failures++
+		l.last = now
+
+		if d := lo.policy.duration(l.Failures); d > 0 {
+			l.Until = now.Add(d)
+			triggered = append(triggered, *l)
+		}
+	}
+
+	notify := lo.notify
+	lo.mu.Unlock()
+
+	if notify != nil {
+		for _, l := range triggered {
+			notify(l)
+		}
+	}
+
+	return triggered
+}
+
+// reset forgets the failed attempts of the targets
+func (lo *lockouts) reset(targets []string) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	for _, t := range targets {
+		delete(lo.entries, t)
+	}
+}
+
+// prune forgets the failures which are older than the maximum lockout
+func (lo *lockouts) prune(now time.Time) {
+	for t, l := range lo.entries {
+		if !l.Locked(now) && now.Sub(l.last) >= lo.policy.MaxLockout {
+			delete(lo.entries, t)
+		}
+	}
+}
+
+// locked returns the targets which are locked out sorted by the targets
+func (lo *lockouts) locked() []Lockout {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	now := lo.now()
+	res := []Lockout{}
+	for _, l := range lo.entries {
+		if l.Locked(now) {
+			res = append(res, *l)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Target < res[j].Target })
+	return res
+}
+
+// unlock forgets the failures of the targets matching the glob
+// pattern and returns the number of targets which were locked out
+func (lo *lockouts) unlock(pattern string) int {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	now := lo.now()
+	unlocked := 0
+	for t, l := range lo.entries {
+		if !matchKey(pattern, t) {
+			continue
+		}
+
+		if l.Locked(now) {
+			unlocked++
+		}
+		delete(lo.entries, t)
+	}
+
+	return unlocked
+}
+
+// setPolicy replaces the policy, the current lockouts are kept
+func (lo *lockouts) setPolicy(p LockoutPolicy) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	lo.policy = p
+}
+
+// setNotify sets the function called with every triggered lockout
+func (lo *lockouts) setNotify(fn func(Lockout)) {
+	lo.mu.Lock()
+	defer lo.mu.Unlock()
+
+	lo.notify = fn
+}
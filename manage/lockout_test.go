@@ -0,0 +1,146 @@
+package manage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicy_duration(t *testing.T) {
+	policy := LockoutPolicy{MaxFailures: 3, Lockout: time.Second, MaxLockout: 5 * time.Second}
+
+	tests := []struct {
+		name     string
+		policy   LockoutPolicy
+		failures int
+		want     time.Duration
+	}{
+		{"BELOW THE MAXIMUM FAILURES", policy, 2, 0},
+		{"AT THE MAXIMUM FAILURES", policy, 3, time.Second},
+		{"ONE FAILURE PAST THE MAXIMUM", policy, 4, 2 * time.Second},
+		{"TWO FAILURES PAST THE MAXIMUM", policy, 5, 4 * time.Second},
+		{"CAPPED AT THE MAXIMUM LOCKOUT", policy, 50, 5 * time.Second},
+		{"DISABLED", LockoutPolicy{}, 50, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.duration(tt.failures); got != tt.want {
+				t.Errorf("LockoutPolicy.duration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureDB_Lockout(t *testing.T) {
+	db := &MockDB{make(map[string]interface{})}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: db, userdb: udb}
+
+	now := time.Unix(0, 0)
+	sdb.lockouts.clock = func() time.Time { return now }
+	sdb.SetLockoutPolicy(LockoutPolicy{MaxFailures: 2, Lockout: time.Minute, MaxLockout: time.Hour})
+
+	var triggered []string
+	sdb.OnLockout(func(l Lockout) { triggered = append(triggered, l.Target) })
+
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	auth := func(addr, username, password string) func() error {
+		return func() error {
+			_, err := sdb.Authenticate(NewSession(addr), username, password)
+			return err
+		}
+	}
+	wait := func(d time.Duration) func() error {
+		return func() error {
+			now = now.Add(d)
+			return nil
+		}
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"FIRST FAILURE", auth("10.0.0.1:5000", "bob", "wrong"), true},
+		{"SUCCESS RESETS THE USER", auth("10.0.0.1:5001", "bob", "pass"), false},
+		{"FAILURE AFTER RESET", auth("10.0.0.2:5000", "bob", "wrong"), true},
+		{"SECOND FAILURE LOCKS OUT", auth("10.0.0.3:5000", "bob", "wrong"), true},
+		{"CORRECT PASSWORD WHILE LOCKED OUT", auth("10.0.0.4:5000", "bob", "pass"), true},
+		{"LOCKOUT EXPIRES", wait(time.Minute), false},
+		{"CORRECT PASSWORD AFTER LOCKOUT", auth("10.0.0.4:5000", "bob", "pass"), false},
+		{"ADDRESS IS LOCKED OUT FOR EVERY USER", auth("10.0.0.1:6000", "alice", "wrong"), true},
+		{"LOCKED OUT ADDRESS", auth("10.0.0.1:6001", "bob", "pass"), true},
+		{"UNLOCK WITHOUT MANAGE USERS", func() error { _, err := sdb.Unlock(NewSession(""), "*"); return err }, true},
+		{"UNLOCK ADDRESS", func() error { _, err := sdb.Unlock(admin, "addr:10.0.0.1"); return err }, false},
+		{"UNLOCKED ADDRESS", auth("10.0.0.1:6002", "bob", "pass"), false},
+	}
+	for _, st := range steps {
+		if err := st.op(); (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+	}
+
+	// The second failure of alice locks out alice
+	auth("10.0.0.5:5000", "alice", "wrong")()
+
+	if want := []string{"user:bob", "addr:10.0.0.1", "user:alice"}; !reflect.DeepEqual(triggered, want) {
+		t.Errorf("triggered lockouts = %v, want %v", triggered, want)
+	}
+
+	locks, err := sdb.Locks(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0].Target != "user:alice" || locks[0].Failures != 2 {
+		t.Errorf("SecureDB.Locks() = %+v, want alice with 2 failures", locks)
+	}
+
+	// The failures are forgotten after the maximum lockout
+	now = now.Add(2 * time.Hour)
+	auth("10.0.0.5:5000", "alice", "wrong")()
+	if locks, _ := sdb.Locks(admin); len(locks) != 0 {
+		t.Errorf("SecureDB.Locks() = %+v, want no lockouts", locks)
+	}
+}
+
+func TestSecureDB_ChangePasswordLockout(t *testing.T) {
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: &MockDB{make(map[string]interface{})}, userdb: udb}
+
+	now := time.Unix(0, 0)
+	sdb.lockouts.clock = func() time.Time { return now }
+	sdb.SetLockoutPolicy(LockoutPolicy{MaxFailures: 2, Lockout: time.Minute, MaxLockout: time.Hour})
+
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	bob, _ := udb.FindUserByUsername("bob")
+	session := NewSession("10.0.0.1:5000").authenticated(bob)
+
+	passwd := func(old string) func() error {
+		return func() error { return sdb.ChangePassword(session, old, "new") }
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"FIRST WRONG PASSWORD", passwd("wrong"), true},
+		{"SECOND WRONG PASSWORD LOCKS OUT", passwd("wrong"), true},
+		{"CORRECT PASSWORD WHILE LOCKED OUT", passwd("pass"), true},
+		{"AUTHENTICATE WHILE LOCKED OUT", func() error { _, err := sdb.Authenticate(NewSession("10.0.0.2:5000"), "bob", "pass"); return err }, true},
+		{"LOCKOUT EXPIRES", func() error { now = now.Add(time.Minute); return nil }, false},
+		{"CORRECT PASSWORD AFTER LOCKOUT", passwd("pass"), false},
+	}
+	for _, st := range steps {
+		if err := st.op(); (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+	}
+}
@@ -8,22 +8,89 @@ type UnsecureStore interface {
 	// Set method should add the passed key into the store with the provided data
 	Set(key string, data interface{}, expireIn time.Duration)
 
+	// SetIf method should set the key like Set only if cond returns true,
+	// the condition must be checked and the key set atomically. get
+	// should return the values of the keys like Get. The returned
+	// value should be true if the key has been set
+	SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) bool
+
 	// Get method should return the value corresponding to the provided key
 	// if the value doesn't exist in the store then the bool should be false
 	Get(key string) (interface{}, bool)
 
+	// GetVersion method should return the value like Get along with the
+	// version of the item, which should be 0 if the key doesn't exist.
+	// The version should change whenever the item is written and the
+	// versions should only ever increase
+	GetVersion(key string) (interface{}, uint64, bool)
+
+	// SetIfVersion method should set the key like Set only if the version
+	// of the item is the passed version, 0 meaning that the key doesn't
+	// exist. The version must be checked and the key set atomically. It
+	// should return the new version and true if the key has been set
+	SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool)
+
 	// Delete method should delete the key value pair for the provided key
 	// it should return the deleted value. The second returned value should
 	// be true if the item was found in the store and was successfully removed
 	// if not found then this value should be false
 	Delete(key string) (interface{}, bool)
 
+	// DeleteIf method should delete the keys like Delete only if cond
+	// returns true, see SetIf. It should return the deleted values, nil
+	// for the keys which weren't found, and true if the keys were deleted
+	DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool)
+
+	// DeleteIfVersion method should delete the key like Delete only if
+	// the version of the item is the passed version, see SetIfVersion.
+	// The bool should be true if the version matched
+	DeleteIfVersion(key string, version uint64) (interface{}, bool)
+
+	// ExpireAt method should return the time when the key expires, the
+	// zero time if it never expires. The bool should be false if the key
+	// doesn't exist in the store
+	ExpireAt(key string) (time.Time, bool)
+
+	// SetExpireAt method should atomically change the time when the key
+	// expires, the zero time should keep it forever and a time in the
+	// past should delete it. It should return false if the key doesn't
+	// exist in the store
+	SetExpireAt(key string, expireAt time.Time) bool
+
+	// Incr method should atomically add delta to the integer stored
+	// against the key, a missing key counting from 0, and return the
+	// result. It should fail if the key holds another value or if
+	// the result overflows
+	Incr(key string, delta int64) (int64, error)
+
+	// IncrFloat method should do the same as Incr for the numbers
+	// stored against the key, the result being a float
+	IncrFloat(key string, delta float64) (float64, error)
+
 	// Wipe method should just wipe the entire underlying database
 	// and should start with a fresh store again
 	Wipe()
 
 	// DefaultExpiry returns the default expiration time for the specified store
 	DefaultExpiry() time.Duration
+
+	// Keys method should return the keys of every item in the store
+	Keys() []string
+}
+
+// Settings is the interface of the runtime configuration of the database
+type Settings interface {
+	// Get should return the values of the settings whose
+	// names match the glob pattern, keyed by their names
+	Get(pattern string) map[string]string
+
+	// Set should validate the value and apply it to the
+	// running database
+	Set(name, value string) error
+
+	// Rewrite should write the effective configuration
+	// back to the configuration file
+	Rewrite() error
 }
 
 // New function returns an instance of an UnsecureDB
@@ -31,8 +98,15 @@ type UnsecureStore interface {
 // UnsecureStore interface. The first store would be used to
 // store the data provided by the users while the second store
 // would be used internally to store the user's info
-func New(unsecureStore UnsecureStore, userdb UnsecureStore) *SecureDB {
-	// Here a new DBClient has no username, password and has no privileges
-	// and are not associated with any events
-	return &SecureDB{unsecureStore, &UserDB{userdb}, newDBClient("", "", NONE, Events{})}
+//
+// settings are exposed to the admins, it can be nil if the
+// database cannot be configured at runtime
+//
+// Failed authentication attempts are throttled according to
+// the DefaultLockoutPolicy until another policy is set
+func New(unsecureStore UnsecureStore, userdb UnsecureStore, settings Settings) *SecureDB {
+	sdb := &SecureDB{ust: unsecureStore, userdb: &UserDB{userdb}, settings: settings}
+	sdb.SetLockoutPolicy(DefaultLockoutPolicy)
+
+	return sdb
 }
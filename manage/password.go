@@ -0,0 +1,170 @@
+package manage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Passwords are stored as PBKDF2-HMAC-SHA256 hashes in the format
+//
+//	$pbkdf2-sha256$v=1$i=<iterations>$<salt>$<hash>
+//
+// where the salt and the hash are base64 encoded. The version and the
+// iterations are stored along with the hash so that the parameters can
+// be raised later on, hashes using older parameters are upgraded once
+// the user authenticates successfully
+const (
+	// hashScheme identifies the hashing scheme of a stored password
+	hashScheme = "pbkdf2-sha256"
+
+	// hashVersion is the version of the hash format
+	hashVersion = 1
+
+	// hashIterations is the number of PBKDF2 iterations
+	// used for the newly hashed passwords
+	hashIterations = 100000
+
+	// hashSaltSize and hashKeySize are the sizes of
+	// the random salt and the derived key in bytes
+	hashSaltSize = 16
+	hashKeySize  = 32
+)
+
+// dummyHash is verified against when a user doesn't exist
+var dummyHash = encodeHash(hashIterations, make([]byte, hashSaltSize), make([]byte, hashKeySize))
+
+// HashPassword returns the salted hash of the password which can be
+// stored in place of the password itself
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return encodeHash(hashIterations, salt, pbkdf2([]byte(password), salt, hashIterations, hashKeySize)), nil
+}
+
+// VerifyPassword compares the password with the stored hash in constant
+// time. The second return value is true if the stored value should be
+// replaced by a new hash, which is the case for the hashes created with
+// older parameters and for the passwords stored in plain text
+func VerifyPassword(stored, password string) (ok bool, rehash bool) {
+	if !IsHashedPassword(stored) {
+		// Users created before hashing was introduced
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1, true
+	}
+
+	version, iterations, salt, hash, err := decodeHash(stored)
+	if err != nil {
+		return false, false
+	}
+
+	got := pbkdf2([]byte(password), salt, iterations, len(hash))
+	if subtle.ConstantTimeCompare(got, hash) != 1 {
+		return false, false
+	}
+
+	return true, version != hashVersion || iterations < hashIterations
+}
+
+// IsHashedPassword returns true if the stored password is a hash
+func IsHashedPassword(stored string) bool {
+	return strings.HasPrefix(stored, "$"+hashScheme+"$")
+}
+
+// encodeHash encodes the parameters, the salt and the hash
+func encodeHash(iterations int, salt, hash []byte) string {
+	return fmt.Sprintf("$%s$v=%d$i=%d$%s$%s",
+		hashScheme,
+		hashVersion,
+		iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	)
+}
+
+// decodeHash decodes a hash encoded by encodeHash
+func decodeHash(stored string) (version, iterations int, salt, hash []byte, err error) {
+	// The leading "$" results in an empty first part
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 || parts[1] != hashScheme {
+		return 0, 0, nil, nil, fmt.Errorf("Invalid password hash")
+	}
+
+	if version, err = parseHashParam(parts[2], "v"); err != nil {
+		return
+	}
+
+	if iterations, err = parseHashParam(parts[3], "i"); err != nil {
+		return
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return
+	}
+
+	if hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return
+	}
+
+	if iterations <= 0 || len(hash) == 0 {
+		err = fmt.Errorf("Invalid password hash")
+	}
+
+	return
+}
+
+// parseHashParam parses a "name=value" parameter of a hash
+func parseHashParam(param, name string) (int, error) {
+	if !strings.HasPrefix(param, name+"=") {
+		return 0, fmt.Errorf("Invalid password hash parameter %s", param)
+	}
+
+	return strconv.Atoi(param[len(name)+1:])
+}
+
+// pbkdf2 derives a key of keyLen bytes from the password and the salt
+// as described in RFC 8018 using HMAC-SHA256 as the pseudorandom function
+func pbkdf2(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	var counter [4]byte
+	key := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+
+	for block := 1; block <= blocks; block++ {
+		// U1 = PRF(password, salt || INT(block))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], uint32(block))
+		prf.Write(counter[:])
+		u = prf.Sum(u[:0])
+
+		t := make([]byte, hashLen)
+		copy(t, u)
+
+		// Un = PRF(password, Un-1), T = U1 ^ U2 ^ ... ^ Un
+		for n := 2; n <= iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+
+			for i := range t {
+				t[i] ^= u[i]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLen]
+}
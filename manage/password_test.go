@@ -0,0 +1,133 @@
+package manage
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func Test_pbkdf2(t *testing.T) {
+	type args struct {
+		password   string
+		salt       string
+		iterations int
+		keyLen     int
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			"RFC 7914 TEST VECTOR",
+			args{"passwd", "salt", 1, 64},
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783",
+		},
+		{
+			"MULTIPLE ITERATIONS",
+			args{"password", "salt", 4096, 32},
+			"c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pbkdf2([]byte(tt.args.password), []byte(tt.args.salt), tt.args.iterations, tt.args.keyLen)
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("pbkdf2() = %x, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	type args struct {
+		stored   string
+		password string
+	}
+
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hash of the same password with fewer iterations
+	weak := encodeHash(10, []byte("salt"), pbkdf2([]byte("secret"), []byte("salt"), 10, hashKeySize))
+
+	tests := []struct {
+		name       string
+		args       args
+		wantOk     bool
+		wantRehash bool
+	}{
+		{
+			"VALID PASSWORD",
+			args{hash, "secret"},
+			true,
+			false,
+		},
+		{
+			"INVALID PASSWORD",
+			args{hash, "secret1"},
+			false,
+			false,
+		},
+		{
+			"VALID PASSWORD WITH OLD PARAMETERS",
+			args{weak, "secret"},
+			true,
+			true,
+		},
+		{
+			"VALID PLAIN TEXT PASSWORD",
+			args{"secret", "secret"},
+			true,
+			true,
+		},
+		{
+			"INVALID PLAIN TEXT PASSWORD",
+			args{"secret", "secret1"},
+			false,
+			true,
+		},
+		{
+			"MALFORMED HASH",
+			args{"$pbkdf2-sha256$v=1$i=x$c2FsdA$c2FsdA", "secret"},
+			false,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOk, gotRehash := VerifyPassword(tt.args.stored, tt.args.password)
+			if gotOk != tt.wantOk || gotRehash != tt.wantRehash {
+				t.Errorf("VerifyPassword() = %v, %v, want %v, %v", gotOk, gotRehash, tt.wantOk, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestUserDB_MigratePasswords(t *testing.T) {
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+
+	udb.Set("plain", NewDBUser("plain", "pass", AdminAccess, Events{GET}), udb.DefaultExpiry())
+	if err := udb.New("hashed", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	hashed, _ := udb.FindUserByUsername("hashed")
+
+	migrated, err := udb.MigratePasswords()
+	if err != nil || migrated != 1 {
+		t.Fatalf("UserDB.MigratePasswords() = %v, %v, want 1", migrated, err)
+	}
+
+	plain, _ := udb.FindUserByUsername("plain")
+	if ok, rehash := VerifyPassword(plain.Password, "pass"); !ok || rehash {
+		t.Errorf("Password was not migrated, got = %v", plain.Password)
+	}
+	if plain.Access != AdminAccess || len(plain.Events) != 1 {
+		t.Errorf("UserDB.MigratePasswords() changed the user, got = %+v", plain)
+	}
+
+	if user, _ := udb.FindUserByUsername("hashed"); user.Password != hashed.Password {
+		t.Errorf("UserDB.MigratePasswords() rehashed a hashed password")
+	}
+}
@@ -0,0 +1,122 @@
+package manage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Permission is a set of independent permissions. Permissions
+// are granted to the users through the roles assigned to them
+type Permission uint
+
+const (
+	// ReadPermission allows reading the data
+	ReadPermission Permission = 1 << iota
+
+	// WritePermission allows storing the data
+	WritePermission
+
+	// DeletePermission allows deleting the data
+	DeletePermission
+
+	// WipePermission allows wiping out the database
+	WipePermission
+
+	// ManageUsersPermission allows creating users and roles
+	// and assigning roles to the users
+	ManageUsersPermission
+
+	// SubscribePermission allows subscribing to the events
+	SubscribePermission
+
+	// ConfigPermission allows changing the settings of the database
+	ConfigPermission
+
+	// AdminPermission implies every other permission
+	AdminPermission
+)
+
+// NoPermission is the empty set of permissions
+const NoPermission Permission = 0
+
+// permissionNames are the names of the permissions in the order of their bits
+var permissionNames = []string{"read", "write", "delete", "wipe", "manage-users", "subscribe", "config", "admin"}
+
+// ParsePermission converts the name of a permission into a Permission.
+// Underscores can be used in place of the hyphens
+func ParsePermission(name string) (Permission, error) {
+	name = strings.Replace(strings.ToLower(name), "_", "-", -1)
+
+	for i, n := range permissionNames {
+		if n == name {
+			return Permission(1 << uint(i)), nil
+		}
+	}
+
+	return NoPermission, fmt.Errorf("Invalid permission %s, valid permissions are %s", name, strings.Join(permissionNames, ", "))
+}
+
+// ParsePermissions converts the names of permissions into a Permission
+func ParsePermissions(names []string) (Permission, error) {
+	perms := NoPermission
+
+	for _, name := range names {
+		p, err := ParsePermission(name)
+		if err != nil {
+			return NoPermission, err
+		}
+
+		perms |= p
+	}
+
+	return perms, nil
+}
+
+// Has returns true if every permission in req is part
+// of the permissions or if the permissions include admin
+func (p Permission) Has(req Permission) bool {
+	return p&AdminPermission != 0 || p&req == req
+}
+
+// Names returns the names of the permissions in the set
+func (p Permission) Names() []string {
+	var names []string
+	for i, n := range permissionNames {
+		if p&Permission(1<<uint(i)) != 0 {
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+// String returns the comma separated names of the permissions
+func (p Permission) String() string {
+	return strings.Join(p.Names(), ",")
+}
+
+// Role is a named set of permissions
+type Role struct {
+	Name        string
+	Permissions Permission
+}
+
+// builtinRoles are available without being created and cannot
+// be changed or dropped
+var builtinRoles = map[string]Permission{
+	"admin":  AdminPermission,
+	"reader": ReadPermission,
+	"writer": ReadPermission | WritePermission | DeletePermission,
+}
+
+// isBuiltinRole returns true if the role is a built-in role
+func isBuiltinRole(name string) bool {
+	_, ok := builtinRoles[name]
+	return ok
+}
+
+// sortRoles sorts the roles by their names
+func sortRoles(roles []Role) {
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+}
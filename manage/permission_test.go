@@ -0,0 +1,156 @@
+package manage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePermissions(t *testing.T) {
+	type args struct {
+		names []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    Permission
+		wantErr bool
+	}{
+		{
+			"VALID PERMISSIONS",
+			args{[]string{"read", "WIPE", "manage-users"}},
+			ReadPermission | WipePermission | ManageUsersPermission,
+			false,
+		},
+		{
+			"PERMISSION WITH UNDERSCORE",
+			args{[]string{"manage_users"}},
+			ManageUsersPermission,
+			false,
+		},
+		{
+			"INVALID PERMISSION",
+			args{[]string{"read", "fly"}},
+			NoPermission,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePermissions(tt.args.names)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePermissions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParsePermissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermission_Has(t *testing.T) {
+	tests := []struct {
+		name  string
+		perms Permission
+		req   Permission
+		want  bool
+	}{
+		{"SUBSET", ReadPermission | WritePermission, ReadPermission, true},
+		{"NOT A SUBSET", ReadPermission | WritePermission, ReadPermission | WipePermission, false},
+		{"ADMIN", AdminPermission, WipePermission | ConfigPermission, true},
+		{"NOTHING REQUESTED", NoPermission, NoPermission, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.perms.Has(tt.req); got != tt.want {
+				t.Errorf("Permission.Has() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccess_Permissions(t *testing.T) {
+	tests := []struct {
+		name   string
+		access Access
+		want   []string
+	}{
+		{"NONE", NONE, nil},
+		{"READ ACCESS", ReadAccess, []string{"read"}},
+		{"WRITE ACCESS", WriteAccess, []string{"read", "write", "delete"}},
+		{"WIPE ACCESS", WipeAccess, []string{"read", "write", "delete", "wipe", "manage-users"}},
+		{"ADMIN ACCESS", AdminAccess, []string{"admin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.access.Permissions().Names(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Access.Permissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureDB_RoleManagement(t *testing.T) {
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: &MockDB{make(map[string]interface{})}, userdb: udb}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	manager := NewTrustedSession("manager", ManageUsersPermission|ReadPermission)
+
+	if err := udb.New("bob", "pass", NONE, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := sdb.Authenticate(NewSession(""), "bob", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := udb.New("root", "pass", AdminAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"CREATE ROLE", func() error { return sdb.CreateRole(admin, "ops", []string{"read", "wipe"}) }, false},
+		{"CREATE ROLE WITH INVALID PERMISSION", func() error { return sdb.CreateRole(admin, "bad", []string{"fly"}) }, true},
+		{"CREATE BUILT-IN ROLE", func() error { return sdb.CreateRole(admin, "reader", []string{"wipe"}) }, true},
+		{"CREATE ROLE WITH PERMISSIONS NOT HELD", func() error { return sdb.CreateRole(manager, "esc", []string{"admin"}) }, true},
+		{"CREATE ROLE WITHOUT MANAGE USERS", func() error { return sdb.CreateRole(bob, "mine", []string{}) }, true},
+		{"ASSIGN ROLE", func() error { return sdb.AssignRole(admin, "ops", "bob") }, false},
+		{"UNASSIGN ROLE FROM HIGHER RANKED USER", func() error { return sdb.UnassignRole(manager, "ops", "bob") }, true},
+		{"UNASSIGN ADMIN ROLE FROM ADMIN", func() error { return sdb.UnassignRole(manager, "admin", "root") }, true},
+		{"ASSIGN ROLE WITH PERMISSIONS NOT HELD", func() error { return sdb.AssignRole(manager, "admin", "bob") }, true},
+		{"ASSIGN ROLE TO UNKNOWN USER", func() error { return sdb.AssignRole(admin, "ops", "alice") }, true},
+		{"WIPE WITH ASSIGNED ROLE", func() error { return sdb.Wipe(bob) }, false},
+		{"WRITE WITHOUT PERMISSION", func() error { return sdb.Set(bob, "k", 1, 0) }, true},
+		{"GRANT PERMISSION", func() error { return sdb.GrantRole(admin, "ops", []string{"write"}) }, false},
+		{"WRITE WITH GRANTED PERMISSION", func() error { return sdb.Set(bob, "k", 1, 0) }, false},
+		{"REVOKE PERMISSION", func() error { return sdb.RevokeRole(admin, "ops", []string{"wipe"}) }, false},
+		{"WIPE WITH REVOKED PERMISSION", func() error { return sdb.Wipe(bob) }, true},
+		{"GRANT UNKNOWN ROLE", func() error { return sdb.GrantRole(admin, "nope", []string{"read"}) }, true},
+		{"DROP ROLE", func() error { return sdb.DropRole(admin, "ops") }, false},
+		{"WRITE WITH DROPPED ROLE", func() error { return sdb.Set(bob, "k", 1, 0) }, true},
+		{"DROP BUILT-IN ROLE", func() error { return sdb.DropRole(admin, "admin") }, true},
+		{"ASSIGN BUILT-IN ROLE", func() error { return sdb.AssignRole(admin, "writer", "bob") }, false},
+		{"WRITE WITH BUILT-IN ROLE", func() error { return sdb.Set(bob, "k", 1, 0) }, false},
+		{"UNASSIGN ROLE", func() error { return sdb.UnassignRole(admin, "writer", "bob") }, false},
+		{"WRITE WITH UNASSIGNED ROLE", func() error { return sdb.Set(bob, "k", 1, 0) }, true},
+	}
+	for _, st := range steps {
+		if err := st.op(); (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+	}
+
+	roles, err := sdb.Roles(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Role{{"admin", AdminPermission}, {"reader", ReadPermission}, {"writer", ReadPermission | WritePermission | DeletePermission}}
+	if !reflect.DeepEqual(roles, want) {
+		t.Errorf("SecureDB.Roles() = %v, want %v", roles, want)
+	}
+}
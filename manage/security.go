@@ -2,6 +2,7 @@ package manage
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -11,6 +12,10 @@ import (
 //
 // This addition of user's info along with the store itself makes this
 // an "SecureDB"
+//
+// SecureDB holds no state of the clients using it, every operation is
+// performed on behalf of the Session passed to it. A single SecureDB
+// is hence shared by all the clients of the database
 type SecureDB struct {
 	// UnsecureStore is used to store the data
 	ust UnsecureStore
@@ -19,17 +24,28 @@ type SecureDB struct {
 	// of the users of the database
 	userdb *UserDB
 
-	// active client holds the information of the currently
-	// active client using the layer
-	activeClient *DBClient
+	// settings is the runtime configuration of the database
+	settings Settings
+
+	// lockouts throttles the failed authentication attempts
+	lockouts lockouts
+
+	// auditor records the operations, it can be nil
+	auditor Auditor
+
+	// users serializes the changes of the users and the roles,
+	// which are read, changed and saved back to the userdb
+	users sync.Mutex
 }
 
 ////////////// DATABASE SPECIFIC COMMANDS //////////////////
 
 // Set method performs set operation on the database after checking
 // the user permissions
-func (sdb *SecureDB) Set(key string, data interface{}, expireIn time.Duration) error {
-	if sdb.Authorize(WriteAccess) {
+func (sdb *SecureDB) Set(s *Session, key string, data interface{}, expireIn time.Duration) (err error) {
+	defer func() { sdb.audit(s, "SET", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, WritePermission, key) {
 		sdb.ust.Set(key, data, expireIn)
 		return nil
 	}
@@ -37,10 +53,32 @@ func (sdb *SecureDB) Set(key string, data interface{}, expireIn time.Duration) e
 	return deniedErr()
 }
 
+// SetIf method performs set operation on the database after checking
+// the user permissions only if the condition holds, see UnsecureStore.
+// The keys read by the condition require the read permission, the
+// condition doesn't hold if one of them cannot be read
+func (sdb *SecureDB) SetIf(s *Session, key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) (_ bool, err error) {
+	defer func() { sdb.audit(s, "SET", []string{key}, err) }()
+
+	if !sdb.authorizeKey(s, WritePermission, key) {
+		return false, deniedErr()
+	}
+
+	cond, denied := sdb.authorizeCond(s, cond)
+	ok := sdb.ust.SetIf(key, data, expireIn, cond)
+	if !ok && *denied {
+		return false, deniedErr()
+	}
+
+	return ok, nil
+}
+
 // Get method performs get operation on the database after checking
 // the user permissions
-func (sdb *SecureDB) Get(key string) (interface{}, bool, error) {
-	if sdb.Authorize(ReadAccess) {
+func (sdb *SecureDB) Get(s *Session, key string) (_ interface{}, _ bool, err error) {
+	defer func() { sdb.auditRead(s, "GET", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, ReadPermission, key) {
 		i, b := sdb.ust.Get(key)
 		return i, b, nil
 	}
@@ -48,10 +86,39 @@ func (sdb *SecureDB) Get(key string) (interface{}, bool, error) {
 	return nil, false, deniedErr()
 }
 
+// GetVersion is like Get except that it also returns the
+// version of the item, which is 0 if the key doesn't exist
+func (sdb *SecureDB) GetVersion(s *Session, key string) (_ interface{}, _ uint64, _ bool, err error) {
+	defer func() { sdb.auditRead(s, "GET", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, ReadPermission, key) {
+		i, v, b := sdb.ust.GetVersion(key)
+		return i, v, b, nil
+	}
+
+	return nil, 0, false, deniedErr()
+}
+
+// SetIfVersion sets the key after checking the write permission only
+// if the version of the item is the passed version, 0 meaning that the
+// key doesn't exist. It returns the new version and true if it was set
+func (sdb *SecureDB) SetIfVersion(s *Session, key string, version uint64, data interface{}, expireIn time.Duration) (_ uint64, _ bool, err error) {
+	defer func() { sdb.audit(s, "CAS", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, WritePermission, key) {
+		v, ok := sdb.ust.SetIfVersion(key, version, data, expireIn)
+		return v, ok, nil
+	}
+
+	return 0, false, deniedErr()
+}
+
 // Delete method performs delete operation on the database after
 // checking the permissions
-func (sdb *SecureDB) Delete(key string) (interface{}, bool, error) {
-	if sdb.Authorize(WriteAccess) {
+func (sdb *SecureDB) Delete(s *Session, key string) (_ interface{}, _ bool, err error) {
+	defer func() { sdb.audit(s, "DEL", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, DeletePermission, key) {
 		i, b := sdb.ust.Delete(key)
 		return i, b, nil
 	}
@@ -59,10 +126,96 @@ func (sdb *SecureDB) Delete(key string) (interface{}, bool, error) {
 	return nil, false, deniedErr()
 }
 
+// DeleteIf method performs delete operation on the keys after checking
+// the permissions only if the condition holds, see SetIf
+func (sdb *SecureDB) DeleteIf(s *Session, keys []string, cond func(get func(key string) (interface{}, bool)) bool) (_ []interface{}, _ bool, err error) {
+	defer func() { sdb.audit(s, "DEL", keys, err) }()
+
+	for _, key := range keys {
+		if !sdb.authorizeKey(s, DeletePermission, key) {
+			return nil, false, deniedErr()
+		}
+	}
+
+	cond, denied := sdb.authorizeCond(s, cond)
+	deleted, ok := sdb.ust.DeleteIf(keys, cond)
+	if !ok && *denied {
+		return nil, false, deniedErr()
+	}
+
+	return deleted, ok, nil
+}
+
+// DeleteIfVersion deletes the key after checking the delete permission
+// only if the version of the item is the passed version. It returns the
+// deleted value and true if the version matched
+func (sdb *SecureDB) DeleteIfVersion(s *Session, key string, version uint64) (_ interface{}, _ bool, err error) {
+	defer func() { sdb.audit(s, "DEL", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, DeletePermission, key) {
+		i, ok := sdb.ust.DeleteIfVersion(key, version)
+		return i, ok, nil
+	}
+
+	return nil, false, deniedErr()
+}
+
+// ExpireAt method returns the time when the key expires after
+// checking the permissions, see UnsecureStore
+func (sdb *SecureDB) ExpireAt(s *Session, key string) (_ time.Time, _ bool, err error) {
+	defer func() { sdb.auditRead(s, "TTL", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, ReadPermission, key) {
+		t, ok := sdb.ust.ExpireAt(key)
+		return t, ok, nil
+	}
+
+	return time.Time{}, false, deniedErr()
+}
+
+// SetExpireAt method changes the time when the key expires after
+// checking the permissions, see UnsecureStore
+func (sdb *SecureDB) SetExpireAt(s *Session, key string, expireAt time.Time) (_ bool, err error) {
+	defer func() { sdb.audit(s, "EXPIRE", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, WritePermission, key) {
+		return sdb.ust.SetExpireAt(key, expireAt), nil
+	}
+
+	return false, deniedErr()
+}
+
+// Incr adds delta to the integer stored against the key after
+// checking the write permission and returns the result
+func (sdb *SecureDB) Incr(s *Session, key string, delta int64) (_ int64, err error) {
+	defer func() { sdb.audit(s, "INCR", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, WritePermission, key) {
+		return sdb.ust.Incr(key, delta)
+	}
+
+	return 0, deniedErr()
+}
+
+// IncrFloat adds delta to the number stored against the key after
+// checking the write permission and returns the result
+func (sdb *SecureDB) IncrFloat(s *Session, key string, delta float64) (_ float64, err error) {
+	defer func() { sdb.audit(s, "INCRBYFLOAT", []string{key}, err) }()
+
+	if sdb.authorizeKey(s, WritePermission, key) {
+		return sdb.ust.IncrFloat(key, delta)
+	}
+
+	return 0, deniedErr()
+}
+
 // Wipe method performs wipe operation on the database after
-// checking the permissions
-func (sdb *SecureDB) Wipe() error {
-	if sdb.Authorize(WipeAccess) {
+// checking the permissions. Users whose access is scoped by
+// rules cannot wipe the database
+func (sdb *SecureDB) Wipe(s *Session) (err error) {
+	defer func() { sdb.audit(s, "WIPE", nil, err) }()
+
+	if perms, rules := sdb.resolve(s); perms.Has(WipePermission) && len(rules) == 0 {
 		sdb.ust.Wipe()
 		return nil
 	}
@@ -70,92 +223,779 @@ func (sdb *SecureDB) Wipe() error {
 	return deniedErr()
 }
 
-////////////// ACTIVE CLIENT SPECIFIC COMMANDS //////////////////
+////////////// SESSION SPECIFIC COMMANDS //////////////////
 
 // RegisterUser registers a new user with specified username, password and access level
-// it does not check for the already existing user with the same username. If a user with
-// same username exists then it will overwrite that user's data
-func (sdb *SecureDB) RegisterUser(username, password string, access uint) error {
-	if sdb.Authorize(ModifyUserAccess) {
-		a, err := ConvertUintToAccess(access)
-		if err != nil {
-			return err
-		}
+// it fails if a user with the same username already exists unless replace is true, in
+// which case the existing user is overwritten along with its roles and rules
+//
+// A user can only be given the permissions which the session has itself
+func (sdb *SecureDB) RegisterUser(s *Session, username, password string, access uint, replace bool) (err error) {
+	defer func() { sdb.audit(s, "REGUSER", []string{username}, err) }()
 
-		// Add a new user to the userdb
-		sdb.userdb.New(username, password, a, Events{})
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
 
-		return nil
+	a, err := ConvertUintToAccess(access)
+	if err != nil {
+		return err
 	}
 
-	return deniedErr()
+	if !sdb.Authorize(s, a.Permissions()) {
+		return deniedErr()
+	}
+
+	if isRoleKey(username) {
+		return fmt.Errorf("Username cannot start with %s", rolePrefix)
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	sdb.users.Lock()
+	defer sdb.users.Unlock()
+
+	if user, ok := sdb.userdb.FindUserByUsername(username); ok {
+		if !replace {
+			return fmt.Errorf("User %s already exists", username)
+		}
+
+		if !sdb.outranks(s, user) {
+			return deniedErr()
+		}
+	}
+
+	// Add a new user to the userdb
+	sdb.userdb.save(NewDBUser(username, hash, a, Events{}))
+	return nil
 }
 
-// Authenticate authenticates a client and changes the permissions for the
-// active client to that allocated to it
-func (sdb *SecureDB) Authenticate(username, password string) error {
+// DeleteUser removes the user. The user of the session cannot be
+// deleted through the session itself
+func (sdb *SecureDB) DeleteUser(s *Session, username string) (err error) {
+	defer func() { sdb.audit(s, "DELUSER", []string{username}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	sdb.users.Lock()
+	defer sdb.users.Unlock()
+
 	user, ok := sdb.userdb.FindUserByUsername(username)
-	if !ok || user.Password != password {
-		return fmt.Errorf("Invalid Credentials")
+	if !ok {
+		return userNotFoundErr(username)
 	}
 
-	sdb.ChangeActiveClient(user.Username, user.Password, user.Access, user.Events)
+	if !sdb.outranks(s, user) {
+		return deniedErr()
+	}
+
+	if username == s.Username() {
+		return fmt.Errorf("Cannot delete the authenticated user")
+	}
+
+	sdb.userdb.DeleteUser(username)
 	return nil
 }
 
-// Ping will subscribe the currently active user to the
-// passed in event. Only admins can use this method
-func (sdb *SecureDB) Ping(event string, on bool) error {
-	if !sdb.Authorize(AdminAccess) {
+// ChangePassword changes the password of the user of the session
+// after verifying the current password of the user. The failed
+// verifications are throttled like the failed authentications
+func (sdb *SecureDB) ChangePassword(s *Session, oldPassword, newPassword string) (err error) {
+	defer func() { sdb.audit(s, "PASSWD", []string{s.Username()}, err) }()
+
+	user, ok := sdb.userdb.FindUserByUsername(s.Username())
+	if !ok {
+		return fmt.Errorf("Not authenticated")
+	}
+
+	targets := authTargets(s, user.Username)
+	if err := sdb.lockouts.check(targets); err != nil {
+		return err
+	}
+
+	if valid, _ := VerifyPassword(user.Password, oldPassword); !valid {
+		sdb.failAuth(s, targets)
+		return errInvalidCredentials
+	}
+	sdb.lockouts.reset(targets[:1])
+
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return sdb.updateUser(user.Username, func(u *DBUser) error {
+		// The password verified above must still be the current one
+		if u.Password != user.Password {
+			return errInvalidCredentials
+		}
+
+		u.Password = hash
+		return nil
+	})
+}
+
+// ResetPassword forces a new password upon the user without
+// requiring the current one
+func (sdb *SecureDB) ResetPassword(s *Session, username, password string) (err error) {
+	defer func() { sdb.audit(s, "PASSWD", []string{username}, err) }()
+
+	if !sdb.canManageUsers(s) {
 		return deniedErr()
 	}
 
-	ev, err := ConvertStringToEvent(event)
+	user, ok := sdb.userdb.FindUserByUsername(username)
+	if !ok {
+		return userNotFoundErr(username)
+	}
+
+	// The password is only hashed for the users which can be managed
+	if !sdb.outranks(s, user) {
+		return deniedErr()
+	}
+
+	hash, err := HashPassword(password)
 	if err != nil {
 		return err
 	}
 
-	username := sdb.activeClient.Username
-	password := sdb.activeClient.Password
-	access := sdb.activeClient.Access
-	var events Events = make(Events, 1)
+	return sdb.updateUser(username, func(user *DBUser) error {
+		if !sdb.outranks(s, *user) {
+			return deniedErr()
+		}
+
+		user.Password = hash
+		return nil
+	})
+}
+
+// SetAccess changes the access level of the user. The session must
+// have the permissions of both the current and the new access level
+func (sdb *SecureDB) SetAccess(s *Session, username string, access uint) (err error) {
+	defer func() { sdb.audit(s, "SETACCESS", []string{username}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	a, err := ConvertUintToAccess(access)
+	if err != nil {
+		return err
+	}
+
+	return sdb.updateUser(username, func(user *DBUser) error {
+		if !sdb.outranks(s, *user) || !sdb.Authorize(s, a.Permissions()) {
+			return deniedErr()
+		}
+
+		user.Access = a
+		return nil
+	})
+}
+
+// Users returns every user along with the permissions
+// granted to the user by its access level and roles
+func (sdb *SecureDB) Users(s *Session) (_ map[string]Permission, err error) {
+	defer func() { sdb.auditRead(s, "LISTUSERS", nil, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return nil, deniedErr()
+	}
+
+	users := make(map[string]Permission)
+	for _, user := range sdb.userdb.Users() {
+		users[user.Username] = sdb.userdb.Permissions(user)
+	}
+
+	return users, nil
+}
+
+// WhoAmI returns the username and the permissions of the session
+func (sdb *SecureDB) WhoAmI(s *Session) (string, Permission) {
+	return s.Username(), sdb.Permissions(s)
+}
+
+// Authenticate authenticates a client and returns a new session of the
+// client with the permissions allocated to the user. The passed session
+// is left untouched
+func (sdb *SecureDB) Authenticate(s *Session, username, password string) (_ *Session, err error) {
+	defer func() { sdb.audit(s, "AUTH", []string{username}, err) }()
+
+	targets := authTargets(s, username)
+	if err := sdb.lockouts.check(targets); err != nil {
+		return s, err
+	}
+
+	user, ok := sdb.userdb.FindUserByUsername(username)
+	if !ok {
+		// Spend the same time as for a wrong password so that
+		// the existence of the user cannot be inferred
+		VerifyPassword(dummyHash, password)
+		sdb.failAuth(s, targets)
+		return s, errInvalidCredentials
+	}
+
+	valid, rehash := VerifyPassword(user.Password, password)
+	if !valid {
+		sdb.failAuth(s, targets)
+		return s, errInvalidCredentials
+	}
+	// Only the failures of the user are forgiven, otherwise a client
+	// could keep guessing by authenticating with a known user in between
+	sdb.lockouts.reset(targets[:1])
+
+	// Upgrade the stored hash to the current parameters
+	// unless the password has been changed in the meantime
+	if rehash {
+		if hash, err := HashPassword(password); err == nil {
+			sdb.updateUser(username, func(u *DBUser) error {
+				if u.Password == user.Password {
+					u.Password = hash
+				}
+				return nil
+			})
+		}
+	}
+
+	return s.authenticated(user), nil
+}
+
+// AuthenticateCertificate authenticates a client as the user named by
+// its client certificate and returns a new session of the client. The
+// certificate must have been verified by the caller, no password is
+// needed hence the lockouts don't apply
+func (sdb *SecureDB) AuthenticateCertificate(s *Session, username string) (_ *Session, err error) {
+	defer func() { sdb.audit(s, "AUTH CERT", []string{username}, err) }()
+
+	user, ok := sdb.userdb.FindUserByUsername(username)
+	if !ok {
+		return s, fmt.Errorf("Unknown user %s", username)
+	}
+
+	return s.authenticated(user), nil
+}
+
+// failAuth records the failed authentication attempt of the targets
+// and audits the lockouts triggered by it
+func (sdb *SecureDB) failAuth(s *Session, targets []string) {
+	for _, l := range sdb.lockouts.fail(targets) {
+		sdb.audit(s, "LOCKOUT", []string{l.Target}, nil)
+	}
+}
+
+// Ping subscribes (or unsubscribes) the session to the passed in event
+// and returns the updated session. The subscriptions are saved for the
+// user of the session as well
+func (sdb *SecureDB) Ping(s *Session, event string, on bool) (_ *Session, err error) {
+	defer func() { sdb.audit(s, "PING", []string{event}, err) }()
+
+	if !sdb.Authorize(s, SubscribePermission) {
+		return s, deniedErr()
+	}
+
+	ev, err := ConvertStringToEvent(event)
+	if err != nil {
+		return s, err
+	}
+
+	// The events of the data reveal the values and
+	// lockouts the users and the addresses of the clients
+	if (ev.carriesData() && !sdb.Authorize(s, ReadPermission)) || (ev == LOCKOUT && !sdb.canManageUsers(s)) {
+		return s, deniedErr()
+	}
+
+	var events Events
 	if on {
-		events = sdb.activeClient.Events.Set(ev)
+		events = s.Events().Set(ev)
 	} else {
-		events = sdb.activeClient.Events.Unset(ev)
+		events = s.Events().Unset(ev)
 	}
 
-	// Change the currently active client
-	sdb.ChangeActiveClient(username, password, access, events)
-
 	// Update the same in the users database
-	sdb.userdb.New(username, password, access, events)
+	sdb.updateUser(s.Username(), func(user *DBUser) error {
+		user.Events = events
+		return nil
+	})
+
+	return s.withEvents(events), nil
+}
+
+////////////// CONFIGURATION SPECIFIC COMMANDS //////////////////
+
+// ConfigGet returns the settings matching the glob pattern
+// It requires the config permission
+func (sdb *SecureDB) ConfigGet(s *Session, pattern string) (_ map[string]string, err error) {
+	defer func() { sdb.auditRead(s, "CONFIG GET", []string{pattern}, err) }()
+
+	if !sdb.Authorize(s, ConfigPermission) {
+		return nil, deniedErr()
+	}
+
+	if sdb.settings == nil {
+		return nil, noSettingsErr()
+	}
+
+	return sdb.settings.Get(pattern), nil
+}
+
+// ConfigSet changes a setting of the running database
+// It requires the config permission
+func (sdb *SecureDB) ConfigSet(s *Session, name, value string) (err error) {
+	defer func() { sdb.audit(s, "CONFIG SET", []string{name}, err) }()
+
+	if !sdb.Authorize(s, ConfigPermission) {
+		return deniedErr()
+	}
+
+	if sdb.settings == nil {
+		return noSettingsErr()
+	}
+
+	return sdb.settings.Set(name, value)
+}
+
+// ConfigRewrite writes the effective configuration back to
+// the configuration file. It requires the config permission
+func (sdb *SecureDB) ConfigRewrite(s *Session) (err error) {
+	defer func() { sdb.audit(s, "CONFIG REWRITE", nil, err) }()
+
+	if !sdb.Authorize(s, ConfigPermission) {
+		return deniedErr()
+	}
+
+	if sdb.settings == nil {
+		return noSettingsErr()
+	}
+
+	return sdb.settings.Rewrite()
+}
+
+////////////// ROLE SPECIFIC COMMANDS //////////////////
+
+// CreateRole creates a role with the passed permissions. An existing
+// role with the same name is replaced. A role can only be given the
+// permissions which the session has itself
+func (sdb *SecureDB) CreateRole(s *Session, name string, permissions []string) (err error) {
+	defer func() { sdb.audit(s, "CREATEROLE", []string{name}, err) }()
+
+	perms, err := sdb.grantable(s, permissions)
+	if err != nil {
+		return err
+	}
+
+	if isBuiltinRole(name) {
+		return builtinRoleErr(name)
+	}
+
+	sdb.users.Lock()
+	sdb.userdb.SaveRole(Role{name, perms})
+	sdb.users.Unlock()
 
 	return nil
 }
 
-// Authorize authorizes the requests and returns true if a client
-// is permitted to perform a certain action
-func (sdb *SecureDB) Authorize(reqAccess Access) bool {
-	return sdb.activeClient.Access >= reqAccess
+// DropRole removes the role. The users which were assigned
+// the role lose its permissions
+func (sdb *SecureDB) DropRole(s *Session, name string) (err error) {
+	defer func() { sdb.audit(s, "DROPROLE", []string{name}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	if isBuiltinRole(name) {
+		return builtinRoleErr(name)
+	}
+
+	sdb.users.Lock()
+	defer sdb.users.Unlock()
+
+	if !sdb.userdb.DeleteRole(name) {
+		return roleNotFoundErr(name)
+	}
+
+	return nil
 }
 
-// ChangeActiveClient changes the active client of the database by assigning new username
-// password and access levels to the activeClient attribute
-func (sdb *SecureDB) ChangeActiveClient(username, password string, access Access, events Events) {
-	sdb.activeClient = newDBClient(username, password, access, events)
+// GrantRole adds the permissions to the role
+func (sdb *SecureDB) GrantRole(s *Session, name string, permissions []string) (err error) {
+	defer func() { sdb.audit(s, "GRANT", []string{name}, err) }()
+
+	return sdb.updateRole(s, name, permissions, func(role, perms Permission) Permission {
+		return role | perms
+	})
 }
 
-// IsSubscribed returns true if the active client has subscribed
-// to the mentioned event
-func (sdb *SecureDB) IsSubscribed(event Event) bool {
-	_, exists := sdb.activeClient.Events.Exists(event)
-	return exists
+// RevokeRole removes the permissions from the role
+func (sdb *SecureDB) RevokeRole(s *Session, name string, permissions []string) (err error) {
+	defer func() { sdb.audit(s, "REVOKE", []string{name}, err) }()
+
+	return sdb.updateRole(s, name, permissions, func(role, perms Permission) Permission {
+		return role &^ perms
+	})
+}
+
+// AssignRole assigns the role to the user. A role can only be
+// assigned if the session has every permission of the role
+func (sdb *SecureDB) AssignRole(s *Session, name, username string) (err error) {
+	defer func() { sdb.audit(s, "ASSIGN", []string{name, username}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	role, ok := sdb.userdb.FindRole(name)
+	if !ok {
+		return roleNotFoundErr(name)
+	}
+
+	if !sdb.Authorize(s, role.Permissions) {
+		return deniedErr()
+	}
+
+	return sdb.updateUser(username, func(user *DBUser) error {
+		for _, r := range user.Roles {
+			if r == name {
+				return nil
+			}
+		}
+
+		user.Roles = append(user.Roles, name)
+		return nil
+	})
+}
+
+// UnassignRole removes the role from the user. The session
+// must have every permission of the user
+func (sdb *SecureDB) UnassignRole(s *Session, name, username string) (err error) {
+	defer func() { sdb.audit(s, "UNASSIGN", []string{name, username}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	return sdb.updateUser(username, func(user *DBUser) error {
+		if !sdb.outranks(s, *user) {
+			return deniedErr()
+		}
+
+		roles := make([]string, 0, len(user.Roles))
+		for _, r := range user.Roles {
+			if r != name {
+				roles = append(roles, r)
+			}
+		}
+
+		user.Roles = roles
+		return nil
+	})
+}
+
+// Roles returns every role sorted by the names of the roles
+func (sdb *SecureDB) Roles(s *Session) (_ []Role, err error) {
+	defer func() { sdb.auditRead(s, "ROLES", nil, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return nil, deniedErr()
+	}
+
+	return sdb.userdb.Roles(), nil
+}
+
+// updateRole replaces the permissions of the role with the ones
+// returned by the update function
+func (sdb *SecureDB) updateRole(s *Session, name string, permissions []string, update func(role, perms Permission) Permission) error {
+	perms, err := sdb.grantable(s, permissions)
+	if err != nil {
+		return err
+	}
+
+	if isBuiltinRole(name) {
+		return builtinRoleErr(name)
+	}
+
+	sdb.users.Lock()
+	defer sdb.users.Unlock()
+
+	role, ok := sdb.userdb.FindRole(name)
+	if !ok {
+		return roleNotFoundErr(name)
+	}
+
+	role.Permissions = update(role.Permissions, perms)
+	sdb.userdb.SaveRole(role)
+	return nil
+}
+
+// grantable parses the permissions and checks if the session is
+// allowed to manage the roles and has every permission itself
+func (sdb *SecureDB) grantable(s *Session, permissions []string) (Permission, error) {
+	if !sdb.canManageUsers(s) {
+		return NoPermission, deniedErr()
+	}
+
+	perms, err := ParsePermissions(permissions)
+	if err != nil {
+		return NoPermission, err
+	}
+
+	if !sdb.Authorize(s, perms) {
+		return NoPermission, deniedErr()
+	}
+
+	return perms, nil
+}
+
+////////////// ACL SPECIFIC COMMANDS //////////////////
+
+// AddRule scopes the access of the user to the keys matching the pattern
+// with the passed permissions. An existing rule with the same pattern is
+// replaced. A rule can only grant the permissions which the session has
+// to the users whose permissions the session has as well
+func (sdb *SecureDB) AddRule(s *Session, username, pattern string, permissions []string) (err error) {
+	defer func() { sdb.audit(s, "ACL ADD", []string{username, pattern}, err) }()
+
+	perms, err := sdb.grantable(s, permissions)
+	if err != nil {
+		return err
+	}
+
+	return sdb.updateUser(username, func(user *DBUser) error {
+		if !sdb.outranks(s, *user) {
+			return deniedErr()
+		}
+
+		rules := []Rule{}
+		for _, r := range user.Rules {
+			if r.Pattern != pattern {
+				rules = append(rules, r)
+			}
+		}
+
+		user.Rules = append(rules, Rule{pattern, perms})
+		return nil
+	})
+}
+
+// DeleteRule removes the rule with the pattern from the user. The
+// user can access every key again once its last rule is removed, so
+// the session must have every permission of the user
+func (sdb *SecureDB) DeleteRule(s *Session, username, pattern string) (err error) {
+	defer func() { sdb.audit(s, "ACL DEL", []string{username, pattern}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return deniedErr()
+	}
+
+	return sdb.updateUser(username, func(user *DBUser) error {
+		if !sdb.outranks(s, *user) {
+			return deniedErr()
+		}
+
+		rules := []Rule{}
+		for _, r := range user.Rules {
+			if r.Pattern != pattern {
+				rules = append(rules, r)
+			}
+		}
+
+		if len(rules) == len(user.Rules) {
+			return fmt.Errorf("Rule %s does not exist for user %s", pattern, username)
+		}
+
+		user.Rules = rules
+		return nil
+	})
+}
+
+// Rules returns the rules of the user
+func (sdb *SecureDB) Rules(s *Session, username string) (_ []Rule, err error) {
+	defer func() { sdb.auditRead(s, "ACL LIST", []string{username}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return nil, deniedErr()
+	}
+
+	user, ok := sdb.userdb.FindUserByUsername(username)
+	if !ok {
+		return nil, userNotFoundErr(username)
+	}
+
+	return user.Rules, nil
+}
+
+////////////// LOCKOUT SPECIFIC COMMANDS //////////////////
+
+// SetLockoutPolicy changes the policy used to throttle the failed
+// authentication attempts. The current lockouts are kept
+func (sdb *SecureDB) SetLockoutPolicy(p LockoutPolicy) {
+	sdb.lockouts.setPolicy(p)
+}
+
+// OnLockout sets the function which is called whenever a user
+// or an address gets locked out
+func (sdb *SecureDB) OnLockout(fn func(Lockout)) {
+	sdb.lockouts.setNotify(fn)
+}
+
+// Locks returns the users and the addresses which are locked out
+func (sdb *SecureDB) Locks(s *Session) (_ []Lockout, err error) {
+	defer func() { sdb.auditRead(s, "LOCKS", nil, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return nil, deniedErr()
+	}
+
+	return sdb.lockouts.locked(), nil
+}
+
+// Unlock forgets the failed attempts of the targets matching the glob
+// pattern and returns the number of targets which were locked out
+func (sdb *SecureDB) Unlock(s *Session, pattern string) (_ int, err error) {
+	defer func() { sdb.audit(s, "UNLOCK", []string{pattern}, err) }()
+
+	if !sdb.canManageUsers(s) {
+		return 0, deniedErr()
+	}
+
+	return sdb.lockouts.unlock(pattern), nil
+}
+
+// updateUser reads the user, applies the change to it and saves it
+// back unless the change fails. The users are updated one at a time
+// so that concurrent changes of a user aren't lost
+func (sdb *SecureDB) updateUser(username string, change func(user *DBUser) error) error {
+	sdb.users.Lock()
+	defer sdb.users.Unlock()
+
+	user, ok := sdb.userdb.FindUserByUsername(username)
+	if !ok {
+		return userNotFoundErr(username)
+	}
+
+	if err := change(&user); err != nil {
+		return err
+	}
+
+	sdb.userdb.save(user)
+	return nil
+}
+
+// Authorize authorizes the requests and returns true if the
+// session has every one of the requested permissions
+func (sdb *SecureDB) Authorize(s *Session, req Permission) bool {
+	return sdb.Permissions(s).Has(req)
+}
+
+// AuthorizeEvent returns true if the event on the key can be sent to
+// the session. The permissions needed to subscribe to the event, see
+// Ping, are checked again as they could have been revoked since then,
+// and the events of the data only if the session can read the key
+func (sdb *SecureDB) AuthorizeEvent(s *Session, ev Event, key string) bool {
+	switch {
+	case ev.carriesData():
+		return sdb.Authorize(s, SubscribePermission) && sdb.authorizeKey(s, ReadPermission, key)
+	case ev == LOCKOUT:
+		return sdb.Authorize(s, SubscribePermission) && sdb.canManageUsers(s)
+	}
+
+	return sdb.Authorize(s, SubscribePermission)
+}
+
+// Permissions returns the permissions of the session which are
+// resolved from the roles of the user of the session
+func (sdb *SecureDB) Permissions(s *Session) Permission {
+	perms, _ := sdb.resolve(s)
+	return perms
+}
+
+// canManageUsers returns true if the session can manage the users, the
+// roles and the rules. Users whose access is scoped by rules cannot, as
+// they could otherwise lift their own restrictions
+func (sdb *SecureDB) canManageUsers(s *Session) bool {
+	perms, rules := sdb.resolve(s)
+	return perms.Has(ManageUsersPermission) && len(rules) == 0
+}
+
+// outranks returns true if the session has every permission of the
+// user, so that users cannot be used to manage more privileged ones
+func (sdb *SecureDB) outranks(s *Session, user DBUser) bool {
+	return sdb.Authorize(s, sdb.userdb.Permissions(user))
+}
+
+// authorizeKey returns true if the session has the requested
+// permissions and the rules of its user allow using them on the key
+func (sdb *SecureDB) authorizeKey(s *Session, req Permission, key string) bool {
+	perms, rules := sdb.resolve(s)
+	return perms.Has(req) && allowsKey(rules, req, key)
+}
+
+// authorizeCond returns the condition which doesn't hold once it reads
+// a key that the session isn't allowed to read. denied is set to true
+// when the condition is evaluated if it read such a key
+func (sdb *SecureDB) authorizeCond(s *Session, cond func(get func(key string) (interface{}, bool)) bool) (func(get func(key string) (interface{}, bool)) bool, *bool) {
+	perms, rules := sdb.resolve(s)
+	denied := new(bool)
+
+	return func(get func(key string) (interface{}, bool)) bool {
+		ok := cond(func(key string) (interface{}, bool) {
+			if !perms.Has(ReadPermission) || !allowsKey(rules, ReadPermission, key) {
+				*denied = true
+				return nil, false
+			}
+			return get(key)
+		})
+
+		return ok && !*denied
+	}, denied
+}
+
+// resolve returns the permissions and the rules of the session
+func (sdb *SecureDB) resolve(s *Session) (Permission, []Rule) {
+	if s.trusted {
+		return s.permissions, nil
+	}
+
+	if s.username == "" {
+		return NoPermission, nil
+	}
+
+	user, ok := sdb.userdb.FindUserByUsername(s.username)
+	if !ok {
+		return NoPermission, nil
+	}
+
+	return sdb.userdb.Permissions(user), user.Rules
 }
 
 // ========================= HELPER FUNCTIONS =============================
 
 // deniedErr returns a pre formatted error
 func deniedErr() error {
-	return fmt.Errorf("Access denied")
+	return errAccessDenied
+}
+
+// builtinRoleErr returns a pre formatted error
+func builtinRoleErr(name string) error {
+	return fmt.Errorf("Role %s is a built-in role and cannot be changed", name)
+}
+
+// roleNotFoundErr returns a pre formatted error
+func roleNotFoundErr(name string) error {
+	return fmt.Errorf("Role %s does not exist", name)
+}
+
+// userNotFoundErr returns a pre formatted error
+func userNotFoundErr(username string) error {
+	return fmt.Errorf("User %s does not exist", username)
+}
+
+// noSettingsErr returns a pre formatted error
+func noSettingsErr() error {
+	return fmt.Errorf("Database cannot be configured at runtime")
 }
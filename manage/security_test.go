@@ -3,15 +3,16 @@ package manage
 import (
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
 func TestSecureDB_Set(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		key      string
@@ -21,9 +22,9 @@ func TestSecureDB_Set(t *testing.T) {
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("admin", "pass", AdminAccess, Events{})
-	ac2 := newDBClient("test", "test", WriteAccess, Events{})
-	ac3 := newDBClient("test2", "test", ReadAccess, Events{})
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", WriteAccess.Permissions())
+	ac3 := NewTrustedSession("test2", ReadAccess.Permissions())
 
 	tests := []struct {
 		name    string
@@ -53,22 +54,113 @@ func TestSecureDB_Set(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			if err := sdb.Set(tt.args.key, tt.args.data, tt.args.expireIn); (err != nil) != tt.wantErr {
+			if err := sdb.Set(tt.fields.session, tt.args.key, tt.args.data, tt.args.expireIn); (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Set() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestSecureDB_SetIf(t *testing.T) {
+	db := &MockDB{map[string]interface{}{"tenantA:k1": 1, "tenantB:k1": 2}}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: db, userdb: udb}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	reader := NewTrustedSession("reader", ReadAccess.Permissions())
+
+	if err := udb.New("team", "pass", WipeAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sdb.AddRule(admin, "team", "tenantA:*", []string{"read", "write", "delete"}); err != nil {
+		t.Fatal(err)
+	}
+	team, err := sdb.Authenticate(NewSession(""), "team", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists := func(key string) func(func(string) (interface{}, bool)) bool {
+		return func(get func(string) (interface{}, bool)) bool {
+			_, ok := get(key)
+			return ok
+		}
+	}
+
+	steps := []struct {
+		name    string
+		op      func() (bool, error)
+		want    bool
+		wantErr bool
+	}{
+		{"SET IF CONDITION HOLDS", func() (bool, error) { return sdb.SetIf(team, "tenantA:k2", 1, 0, exists("tenantA:k1")) }, true, false},
+		{"SET IF CONDITION DOESN'T HOLD", func() (bool, error) { return sdb.SetIf(team, "tenantA:k2", 1, 0, exists("tenantA:k3")) }, false, false},
+		{"SET IF CONDITION READS OUT OF SCOPE", func() (bool, error) { return sdb.SetIf(team, "tenantA:k2", 1, 0, exists("tenantB:k1")) }, false, true},
+		{"SET IF OUT OF SCOPE", func() (bool, error) { return sdb.SetIf(team, "tenantB:k2", 1, 0, exists("tenantA:k1")) }, false, true},
+		{"SET IF WITH READ ACCESS LEVEL", func() (bool, error) { return sdb.SetIf(reader, "k1", 1, 0, exists("tenantA:k1")) }, false, true},
+		{"DELETE IF CONDITION READS OUT OF SCOPE", func() (bool, error) {
+			_, ok, err := sdb.DeleteIf(team, []string{"tenantA:k2"}, exists("tenantB:k1"))
+			return ok, err
+		}, false, true},
+		{"DELETE IF CONDITION HOLDS", func() (bool, error) {
+			_, ok, err := sdb.DeleteIf(team, []string{"tenantA:k1", "tenantA:k2"}, exists("tenantA:k2"))
+			return ok, err
+		}, true, false},
+		{"DELETE IF CONDITION DOESN'T HOLD", func() (bool, error) {
+			_, ok, err := sdb.DeleteIf(admin, []string{"tenantB:k1"}, exists("tenantA:k2"))
+			return ok, err
+		}, false, false},
+	}
+	for _, st := range steps {
+		got, err := st.op()
+		if (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+		if got != st.want {
+			t.Fatalf("%s: got = %v, want %v", st.name, got, st.want)
+		}
+	}
+
+	if !reflect.DeepEqual(db.db, map[string]interface{}{"tenantB:k1": 2}) {
+		t.Errorf("SecureDB.SetIf() data = %v", db.db)
+	}
+}
+
+func TestSecureDB_Incr(t *testing.T) {
+	db := &MockDB{map[string]interface{}{"k1": int64(1)}}
+	sdb := &SecureDB{ust: db, userdb: &UserDB{&MockDB{make(map[string]interface{})}}}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	reader := NewTrustedSession("reader", ReadAccess.Permissions())
+
+	if n, err := sdb.Incr(admin, "k1", 2); err != nil || n != 3 {
+		t.Errorf("SecureDB.Incr() = %v, %v, want 3", n, err)
+	}
+	if f, err := sdb.IncrFloat(admin, "k2", 0.5); err != nil || f != 0.5 {
+		t.Errorf("SecureDB.IncrFloat() = %v, %v, want 0.5", f, err)
+	}
+
+	// The counters require the write permission
+	if _, err := sdb.Incr(reader, "k1", 1); err == nil {
+		t.Error("SecureDB.Incr() with read access level should fail")
+	}
+	if _, err := sdb.IncrFloat(reader, "k2", 1); err == nil {
+		t.Error("SecureDB.IncrFloat() with read access level should fail")
+	}
+
+	if !reflect.DeepEqual(db.db, map[string]interface{}{"k1": int64(3), "k2": 0.5}) {
+		t.Errorf("SecureDB.Incr() data = %v", db.db)
+	}
+}
+
 func TestSecureDB_Get(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		key string
@@ -76,9 +168,9 @@ func TestSecureDB_Get(t *testing.T) {
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("admin", "pass", AdminAccess, Events{})
-	ac2 := newDBClient("test", "test", ReadAccess, Events{})
-	ac3 := newDBClient("test2", "test", NONE, Events{})
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", ReadAccess.Permissions())
+	ac3 := NewTrustedSession("test2", NONE.Permissions())
 
 	// Set data
 	db.Set("k1", 1234, db.DefaultExpiry())
@@ -145,11 +237,10 @@ func TestSecureDB_Get(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			got, got1, err := sdb.Get(tt.args.key)
+			got, got1, err := sdb.Get(tt.fields.session, tt.args.key)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Get() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -166,9 +257,9 @@ func TestSecureDB_Get(t *testing.T) {
 
 func TestSecureDB_Delete(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		key string
@@ -176,9 +267,9 @@ func TestSecureDB_Delete(t *testing.T) {
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("admin", "pass", AdminAccess, Events{})
-	ac2 := newDBClient("test", "test", WriteAccess, Events{})
-	ac3 := newDBClient("test2", "test", NONE, Events{})
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", WriteAccess.Permissions())
+	ac3 := NewTrustedSession("test2", NONE.Permissions())
 
 	// Set data
 	db.Set("k1", 1234, db.DefaultExpiry())
@@ -245,11 +336,10 @@ func TestSecureDB_Delete(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			got, got1, err := sdb.Delete(tt.args.key)
+			got, got1, err := sdb.Delete(tt.fields.session, tt.args.key)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Delete() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -266,16 +356,16 @@ func TestSecureDB_Delete(t *testing.T) {
 
 func TestSecureDB_Wipe(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("admin", "pass", AdminAccess, Events{})
-	ac2 := newDBClient("test", "test", WipeAccess, Events{})
-	ac3 := newDBClient("test2", "test", NONE, Events{})
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", WipeAccess.Permissions())
+	ac3 := NewTrustedSession("test2", NONE.Permissions())
 
 	// Set data
 	db.Set("k1", 1234, db.DefaultExpiry())
@@ -306,11 +396,10 @@ func TestSecureDB_Wipe(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			if err := sdb.Wipe(); (err != nil) != tt.wantErr {
+			if err := sdb.Wipe(tt.fields.session); (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Wipe() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -319,21 +408,22 @@ func TestSecureDB_Wipe(t *testing.T) {
 
 func TestSecureDB_RegisterUser(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		username string
 		password string
 		access   uint
+		replace  bool
 	}
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("admin", "pass", AdminAccess, Events{})
-	ac2 := newDBClient("test", "test", ModifyUserAccess, Events{})
-	ac3 := newDBClient("test2", "test", NONE, Events{})
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", ModifyUserAccess.Permissions())
+	ac3 := NewTrustedSession("test2", NONE.Permissions())
 
 	tests := []struct {
 		name    string
@@ -344,42 +434,65 @@ func TestSecureDB_RegisterUser(t *testing.T) {
 		{
 			"ADD A USER WITH VALID ACCESS USING ADMIN ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{"utkarsh", "test", 5},
+			args{"utkarsh", "test", 5, false},
 			false,
 		},
 		{
 			"ADD A USER WITH INVALID ACCESS USING ADMIN ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{"utkarsh", "test", 50},
+			args{"utkarsh", "test", 50, false},
 			true,
 		},
+		{
+			"ADD AN EXISTING USER WITHOUT REPLACE",
+			fields{db, &UserDB{udb}, ac},
+			args{"utkarsh", "test", 1, false},
+			true,
+		},
+		{
+			"REPLACE AN EXISTING USER USING ADMIN ACCESS",
+			fields{db, &UserDB{udb}, ac},
+			args{"utkarsh", "test", 5, true},
+			false,
+		},
 		{
 			"ADD A USER WITH VALID ACCESS USING MODIFY USER ACCESS",
 			fields{db, &UserDB{udb}, ac2},
-			args{"utkarsh", "test", 5},
+			args{"user2", "test", 2, false},
 			false,
 		},
+		{
+			"REPLACE AN ADMIN USING MODIFY USER ACCESS",
+			fields{db, &UserDB{udb}, ac2},
+			args{"utkarsh", "test", 2, true},
+			true,
+		},
+		{
+			"ADD AN ADMIN USING MODIFY USER ACCESS",
+			fields{db, &UserDB{udb}, ac2},
+			args{"user3", "test", 5, false},
+			true,
+		},
 		{
 			"ADD A USER WITH VALID ACCESS USING MODIFY USER ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{"utkarsh", "test", 500},
+			args{"user4", "test", 500, false},
 			true,
 		},
 		{
 			"ADD A USER WITH VALID ACCESS USING NONE ACCESS",
 			fields{db, &UserDB{udb}, ac3},
-			args{"utkarsh", "test", 5},
+			args{"user5", "test", 5, false},
 			true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			if err := sdb.RegisterUser(tt.args.username, tt.args.password, tt.args.access); (err != nil) != tt.wantErr {
+			if err := sdb.RegisterUser(tt.fields.session, tt.args.username, tt.args.password, tt.args.access, tt.args.replace); (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.RegisterUser() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -388,9 +501,9 @@ func TestSecureDB_RegisterUser(t *testing.T) {
 
 func TestSecureDB_Authenticate(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		username string
@@ -399,7 +512,7 @@ func TestSecureDB_Authenticate(t *testing.T) {
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("test", "test", NONE, Events{}) // Simulate the behaviour of a normal client
+	ac := NewTrustedSession("test", NONE.Permissions()) // Simulate the behaviour of a normal client
 
 	// Add new users to the database
 	udb.Set("test2", NewDBUser("test2", "test2", AdminAccess, Events{}), udb.DefaultExpiry())
@@ -432,30 +545,70 @@ func TestSecureDB_Authenticate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			if err := sdb.Authenticate(tt.args.username, tt.args.password); (err != nil) != tt.wantErr {
+			if _, err := sdb.Authenticate(tt.fields.session, tt.args.username, tt.args.password); (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Authenticate() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
+func TestSecureDB_AuthenticateCertificate(t *testing.T) {
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: &MockDB{make(map[string]interface{})}, userdb: udb}
+
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{"EXISTING USER", "bob", false},
+		{"UNKNOWN USER", "alice", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := sdb.AuthenticateCertificate(NewSession("10.0.0.1:5000"), tt.username)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SecureDB.AuthenticateCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := tt.username
+			if tt.wantErr {
+				want = ""
+			}
+			if s.Username() != want || s.RemoteAddr() != "10.0.0.1:5000" {
+				t.Errorf("SecureDB.AuthenticateCertificate() session = %+v, want user %q", s, want)
+			}
+		})
+	}
+}
+
 func TestSecureDB_Authorize(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
-		reqAccess Access
+		reqPerm Permission
 	}
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("test", "test", ModifyUserAccess, Events{})
+	ac := NewTrustedSession("test", ModifyUserAccess.Permissions())
+	ac2 := NewSession("127.0.0.1:4000").authenticated(NewDBUser("ops", "pass", NONE, Events{}))
+
+	// A user which can only wipe the database through a role
+	(&UserDB{udb}).SaveRole(Role{"wiper", WipePermission})
+	ops := NewDBUser("ops", "pass", NONE, Events{})
+	ops.Roles = []string{"wiper", "dropped"}
+	udb.Set("ops", ops, udb.DefaultExpiry())
 
 	tests := []struct {
 		name   string
@@ -466,78 +619,42 @@ func TestSecureDB_Authorize(t *testing.T) {
 		{
 			"REQUEST ADMIN ACCESS WITH MODIFY USER ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{AdminAccess},
+			args{AdminPermission},
 			false,
 		},
 		{
 			"REQUEST WIPE WITH MODIFY USER ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{WipeAccess},
+			args{WipePermission},
 			false,
 		},
 		{
 			"REQUEST WRITE ACCESS WITH MODIFY USER ACCESS",
 			fields{db, &UserDB{udb}, ac},
-			args{WriteAccess},
+			args{WritePermission},
 			true,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
-			}
-			if got := sdb.Authorize(tt.args.reqAccess); got != tt.want {
-				t.Errorf("SecureDB.Authorize() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestSecureDB_IsSubscribed(t *testing.T) {
-	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
-	}
-	type args struct {
-		event Event
-	}
-
-	db := &MockDB{make(map[string]interface{})}
-	udb := &MockDB{make(map[string]interface{})}
-	ac := newDBClient("test", "test", ModifyUserAccess, Events{GET, SET})
-
-	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		want   bool
-	}{
 		{
-			"EVENT THAT EXISTS IN THE EVENTS",
-			fields{db, &UserDB{udb}, ac},
-			args{GET},
+			"REQUEST WIPE WITH WIPER ROLE",
+			fields{db, &UserDB{udb}, ac2},
+			args{WipePermission},
 			true,
 		},
 		{
-			"EVENT THAT DOES NOT EXIST IN THE EVENTS",
-			fields{db, &UserDB{udb}, ac},
-			args{WIPE},
+			"REQUEST WRITE WITH WIPER ROLE",
+			fields{db, &UserDB{udb}, ac2},
+			args{WritePermission},
 			false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
-			if got := sdb.IsSubscribed(tt.args.event); got != tt.want {
-				t.Errorf("SecureDB.IsSubscribed() = %v, want %v", got, tt.want)
+			if got := sdb.Authorize(tt.fields.session, tt.args.reqPerm); got != tt.want {
+				t.Errorf("SecureDB.Authorize() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -545,9 +662,9 @@ func TestSecureDB_IsSubscribed(t *testing.T) {
 
 func TestSecureDB_Ping(t *testing.T) {
 	type fields struct {
-		ust          UnsecureStore
-		userdb       *UserDB
-		activeClient *DBClient
+		ust     UnsecureStore
+		userdb  *UserDB
+		session *Session
 	}
 	type args struct {
 		event string
@@ -555,8 +672,12 @@ func TestSecureDB_Ping(t *testing.T) {
 
 	db := &MockDB{make(map[string]interface{})}
 	udb := &UserDB{&MockDB{make(map[string]interface{})}}
-	ac := newDBClient("test", "test", ModifyUserAccess, Events{})
-	ac2 := newDBClient("test", "test", AdminAccess, Events{})
+	ac := NewTrustedSession("test", ModifyUserAccess.Permissions())
+	ac2 := NewTrustedSession("test", AdminAccess.Permissions())
+	ac3 := NewTrustedSession("test", SubscribePermission)
+
+	// The subscriptions are saved for the user of the session
+	udb.New("test", "test", AdminAccess, Events{})
 
 	tests := []struct {
 		name    string
@@ -576,6 +697,18 @@ func TestSecureDB_Ping(t *testing.T) {
 			args{"get"},
 			true,
 		},
+		{
+			"PING DATA EVENT WITHOUT READ PERMISSION (SHOULD FAIL)",
+			fields{db, udb, ac3},
+			args{"set"},
+			true,
+		},
+		{
+			"PING WIPE EVENT WITHOUT READ PERMISSION",
+			fields{db, udb, ac3},
+			args{"wipe"},
+			false,
+		},
 		{
 			"PING EVENT WITH INVALID EVENT AND ADMIN ACCESS",
 			fields{db, udb, ac2},
@@ -586,12 +719,11 @@ func TestSecureDB_Ping(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			sdb := &SecureDB{
-				ust:          tt.fields.ust,
-				userdb:       tt.fields.userdb,
-				activeClient: tt.fields.activeClient,
+				ust:    tt.fields.ust,
+				userdb: tt.fields.userdb,
 			}
 
-			err := sdb.Ping(tt.args.event, true)
+			session, err := sdb.Ping(tt.fields.session, tt.args.event, true)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("SecureDB.Ping() error = %v, wantErr %v", err, tt.wantErr)
@@ -615,7 +747,7 @@ func TestSecureDB_Ping(t *testing.T) {
 					ev = WIPE
 				}
 
-				for _, e := range sdb.activeClient.Events {
+				for _, e := range session.Events() {
 					if e == ev {
 						exists = true
 						break
@@ -623,12 +755,12 @@ func TestSecureDB_Ping(t *testing.T) {
 				}
 
 				if !exists {
-					t.Errorf("Event not added to the active users subscriptions, got = %v", sdb.activeClient.Events)
+					t.Errorf("Event not added to the session subscriptions, got = %v", session.Events())
 				}
 
 				// Check if event now exists for the user in the users db
 				exists = false
-				user, ok := udb.FindUserByUsername(sdb.activeClient.Username)
+				user, ok := udb.FindUserByUsername(session.Username())
 
 				if !ok {
 					t.Errorf("User was not created after subscribing to the event")
@@ -660,3 +792,289 @@ func TestSecureDB_Ping(t *testing.T) {
 		})
 	}
 }
+
+func TestSecureDB_AuthorizeEvent(t *testing.T) {
+	type args struct {
+		session *Session
+		ev      Event
+		key     string
+	}
+
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{
+		ust:    &MockDB{make(map[string]interface{})},
+		userdb: udb,
+	}
+	subscriber := NewTrustedSession("test", SubscribePermission)
+	reader := NewTrustedSession("test", SubscribePermission|ReadPermission)
+
+	// A user which can only read the keys of tenantA
+	tenant := NewDBUser("tenant", "pass", NONE, Events{})
+	tenant.Roles = []string{"admin"}
+	tenant.Rules = []Rule{{"tenantA:*", ReadPermission}}
+	udb.save(tenant)
+	scoped := NewSession("").authenticated(tenant)
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"DATA EVENT WITH READ PERMISSION", args{reader, SET, "k1"}, true},
+		{"DATA EVENT WITHOUT READ PERMISSION", args{subscriber, GET, "k1"}, false},
+		{"WIPE EVENT WITHOUT READ PERMISSION", args{subscriber, WIPE, ""}, true},
+		{"LOCKOUT EVENT WITHOUT MANAGE USERS PERMISSION", args{reader, LOCKOUT, "user:bob"}, false},
+		{"EVENT WITHOUT SUBSCRIBE PERMISSION", args{NewTrustedSession("test", ReadPermission), DEL, "k1"}, false},
+		{"DATA EVENT ON A KEY ALLOWED BY THE RULES", args{scoped, SET, "tenantA:x"}, true},
+		{"DATA EVENT ON A KEY OUTSIDE OF THE RULES", args{scoped, SET, "tenantB:x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sdb.AuthorizeEvent(tt.args.session, tt.args.ev, tt.args.key); got != tt.want {
+				t.Errorf("SecureDB.AuthorizeEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureDB_ConcurrentUserChanges(t *testing.T) {
+	udb := &HookDB{MockDB: &MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: &MockDB{make(map[string]interface{})}, userdb: &UserDB{udb}}
+
+	(&UserDB{udb}).New("bob", "pass", ReadAccess, Events{})
+	admin := NewTrustedSession("admin", AdminPermission)
+	bob := NewTrustedSession("bob", AdminPermission)
+
+	// The admin changes the access level of bob while bob is
+	// saving its subscriptions, the change must not be lost
+	var hooked int32
+	changed := make(chan error, 1)
+	udb.onGet = func(key string) {
+		if !atomic.CompareAndSwapInt32(&hooked, 0, 1) {
+			return
+		}
+
+		go func() { changed <- sdb.SetAccess(admin, "bob", uint(WriteAccess)) }()
+		select {
+		case err := <-changed:
+			changed <- err
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if _, err := sdb.Ping(bob, "set", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-changed; err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := (&UserDB{udb}).FindUserByUsername("bob")
+	if _, subscribed := user.Events.Exists(SET); user.Access != WriteAccess || !subscribed {
+		t.Errorf("user = %+v, want the access level and the subscription", user)
+	}
+}
+
+func TestSecureDB_ConfigGet(t *testing.T) {
+	type fields struct {
+		session  *Session
+		settings Settings
+	}
+
+	settings := &MockSettings{map[string]string{"max_clients": "0", "fsync": "everysec"}}
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", WipeAccess.Permissions())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		pattern string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"GET ALL SETTINGS WITH ADMIN ACCESS",
+			fields{ac, settings},
+			"*",
+			map[string]string{"max_clients": "0", "fsync": "everysec"},
+			false,
+		},
+		{
+			"GET A SETTING WITH ADMIN ACCESS",
+			fields{ac, settings},
+			"fsync",
+			map[string]string{"fsync": "everysec"},
+			false,
+		},
+		{
+			"GET SETTINGS WITH WIPE ACCESS",
+			fields{ac2, settings},
+			"*",
+			nil,
+			true,
+		},
+		{
+			"GET SETTINGS WITHOUT SETTINGS",
+			fields{ac, nil},
+			"*",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdb := &SecureDB{
+				settings: tt.fields.settings,
+			}
+			got, err := sdb.ConfigGet(tt.fields.session, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SecureDB.ConfigGet() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SecureDB.ConfigGet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecureDB_ConfigSet(t *testing.T) {
+	type fields struct {
+		session  *Session
+		settings Settings
+	}
+	type args struct {
+		name  string
+		value string
+	}
+
+	settings := &MockSettings{map[string]string{"max_clients": "0"}}
+	ac := NewTrustedSession("admin", AdminAccess.Permissions())
+	ac2 := NewTrustedSession("test", WipeAccess.Permissions())
+
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			"SET SETTING WITH ADMIN ACCESS",
+			fields{ac, settings},
+			args{"max_clients", "10"},
+			false,
+		},
+		{
+			"SET UNKNOWN SETTING WITH ADMIN ACCESS",
+			fields{ac, settings},
+			args{"unknown", "10"},
+			true,
+		},
+		{
+			"SET SETTING WITH WIPE ACCESS",
+			fields{ac2, settings},
+			args{"max_clients", "20"},
+			true,
+		},
+		{
+			"SET SETTING WITHOUT SETTINGS",
+			fields{ac, nil},
+			args{"max_clients", "20"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdb := &SecureDB{
+				settings: tt.fields.settings,
+			}
+			if err := sdb.ConfigSet(tt.fields.session, tt.args.name, tt.args.value); (err != nil) != tt.wantErr {
+				t.Errorf("SecureDB.ConfigSet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := settings.values["max_clients"]; got != "10" {
+		t.Errorf("SecureDB.ConfigSet() max_clients = %v, want 10", got)
+	}
+}
+
+func TestSecureDB_UserManagement(t *testing.T) {
+	db := &MockDB{make(map[string]interface{})}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	sdb := &SecureDB{ust: db, userdb: udb}
+
+	admin := NewTrustedSession("admin", AdminPermission)
+	manager := NewTrustedSession("manager", ModifyUserAccess.Permissions())
+
+	if err := udb.New("root", "pass", AdminAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := udb.New("bob", "pass", ReadAccess, Events{}); err != nil {
+		t.Fatal(err)
+	}
+	bob, err := sdb.Authenticate(NewSession(""), "bob", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		op      func() error
+		wantErr bool
+	}{
+		{"CHANGE PASSWORD WITH WRONG PASSWORD", func() error { return sdb.ChangePassword(bob, "wrong", "new") }, true},
+		{"CHANGE PASSWORD", func() error { return sdb.ChangePassword(bob, "pass", "new") }, false},
+		{"AUTHENTICATE WITH OLD PASSWORD", func() error { _, err := sdb.Authenticate(NewSession(""), "bob", "pass"); return err }, true},
+		{"AUTHENTICATE WITH NEW PASSWORD", func() error { _, err := sdb.Authenticate(NewSession(""), "bob", "new"); return err }, false},
+		{"CHANGE PASSWORD WITHOUT USER", func() error { return sdb.ChangePassword(NewSession(""), "pass", "new") }, true},
+		{"RESET PASSWORD", func() error { return sdb.ResetPassword(manager, "bob", "reset") }, false},
+		{"AUTHENTICATE WITH RESET PASSWORD", func() error { _, err := sdb.Authenticate(NewSession(""), "bob", "reset"); return err }, false},
+		{"RESET PASSWORD OF MORE PRIVILEGED USER", func() error { return sdb.ResetPassword(manager, "root", "reset") }, true},
+		{"RESET PASSWORD WITHOUT MANAGE USERS", func() error { return sdb.ResetPassword(bob, "root", "reset") }, true},
+		{"SET ACCESS", func() error { return sdb.SetAccess(manager, "bob", 2) }, false},
+		{"SET ACCESS BEYOND OWN PERMISSIONS", func() error { return sdb.SetAccess(manager, "bob", 5) }, true},
+		{"SET INVALID ACCESS", func() error { return sdb.SetAccess(admin, "bob", 50) }, true},
+		{"SET ACCESS OF UNKNOWN USER", func() error { return sdb.SetAccess(admin, "nobody", 1) }, true},
+		{"WRITE WITH NEW ACCESS", func() error { return sdb.Set(bob, "key", 1, 0) }, false},
+		{"DELETE MORE PRIVILEGED USER", func() error { return sdb.DeleteUser(manager, "root") }, true},
+		{"DELETE OWN USER", func() error { return sdb.DeleteUser(sessionOf(t, sdb, "root", "pass"), "root") }, true},
+		{"DELETE USER", func() error { return sdb.DeleteUser(manager, "bob") }, false},
+		{"DELETE UNKNOWN USER", func() error { return sdb.DeleteUser(admin, "bob") }, true},
+		{"USE DELETED USER", func() error { return sdb.Set(bob, "key", 1, 0) }, true},
+	}
+	for _, st := range steps {
+		if err := st.op(); (err != nil) != st.wantErr {
+			t.Fatalf("%s: error = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+	}
+
+	if _, err := sdb.Users(bob); err == nil {
+		t.Errorf("SecureDB.Users() without manage users should fail")
+	}
+
+	users, err := sdb.Users(manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]Permission{"root": AdminPermission}; !reflect.DeepEqual(users, want) {
+		t.Errorf("SecureDB.Users() = %v, want %v", users, want)
+	}
+
+	if username, perms := sdb.WhoAmI(sessionOf(t, sdb, "root", "pass")); username != "root" || perms != AdminPermission {
+		t.Errorf("SecureDB.WhoAmI() = %v, %v, want root, admin", username, perms)
+	}
+	if username, perms := sdb.WhoAmI(NewSession("")); username != "" || perms != NoPermission {
+		t.Errorf("SecureDB.WhoAmI() = %v, %v, want no user and no permissions", username, perms)
+	}
+}
+
+// sessionOf authenticates the user and returns its session
+func sessionOf(t *testing.T, sdb *SecureDB, username, password string) *Session {
+	s, err := sdb.Authenticate(NewSession(""), username, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
@@ -0,0 +1,72 @@
+package manage
+
+// Session is the security context of a client of the database. It
+// holds the identity of the client along with the events subscribed
+// by it. The permissions of an authenticated session are resolved from
+// the roles of its user on every operation, so that the changes to
+// the roles take effect immediately
+//
+// A Session is immutable, operations which change the identity of the
+// client like authentication return a new Session instead. This makes
+// it safe to share a Session between goroutines and allows a single
+// SecureDB to serve any number of clients
+type Session struct {
+	username string
+	events   Events
+	remote   string
+
+	// trusted sessions don't belong to a stored user,
+	// they have the permissions given to them instead
+	trusted     bool
+	permissions Permission
+}
+
+// NewSession returns an unauthenticated session for a client
+// connected from the remote address. Such a session has no
+// privileges until the client authenticates itself
+func NewSession(remote string) *Session {
+	return &Session{remote: remote}
+}
+
+// NewTrustedSession returns a session which acts with the passed
+// permissions without authenticating itself. It is meant for the
+// clients which are trusted by the application itself
+func NewTrustedSession(username string, permissions Permission) *Session {
+	return &Session{username: username, trusted: true, permissions: permissions}
+}
+
+// Username returns the name of the user the session belongs to
+// It is empty for an unauthenticated session
+func (s *Session) Username() string {
+	return s.username
+}
+
+// Events returns a copy of the events subscribed by the session
+func (s *Session) Events() Events {
+	return append(Events{}, s.events...)
+}
+
+// RemoteAddr returns the address the client is connected from
+func (s *Session) RemoteAddr() string {
+	return s.remote
+}
+
+// IsSubscribed returns true if the session has
+// subscribed to the mentioned event
+func (s *Session) IsSubscribed(event Event) bool {
+	_, exists := s.events.Exists(event)
+	return exists
+}
+
+// authenticated returns a new session of the same client
+// which belongs to the passed user
+func (s *Session) authenticated(user DBUser) *Session {
+	return &Session{username: user.Username, events: append(Events{}, user.Events...), remote: s.remote}
+}
+
+// withEvents returns a copy of the session subscribed to the events
+func (s *Session) withEvents(events Events) *Session {
+	c := *s
+	c.events = events
+	return &c
+}
@@ -0,0 +1,77 @@
+package manage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSession_IsSubscribed(t *testing.T) {
+	type args struct {
+		event Event
+	}
+
+	s := &Session{username: "test", trusted: true, permissions: ModifyUserAccess.Permissions(), events: Events{GET, SET}}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			"EVENT THAT EXISTS IN THE EVENTS",
+			args{GET},
+			true,
+		},
+		{
+			"EVENT THAT DOES NOT EXIST IN THE EVENTS",
+			args{WIPE},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.IsSubscribed(tt.args.event); got != tt.want {
+				t.Errorf("Session.IsSubscribed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSession_Immutable(t *testing.T) {
+	db := &MockDB{make(map[string]interface{})}
+	udb := &UserDB{&MockDB{make(map[string]interface{})}}
+	udb.New("admin", "pass", AdminAccess, Events{GET})
+
+	sdb := &SecureDB{ust: db, userdb: udb}
+	anon := NewSession("127.0.0.1:4000")
+
+	admin, err := sdb.Authenticate(anon, "admin", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sdb.Permissions(anon) != NoPermission || anon.Username() != "" {
+		t.Errorf("Authenticate() changed the passed session, got = %v, %v", anon.Username(), sdb.Permissions(anon))
+	}
+
+	if admin.Username() != "admin" || sdb.Permissions(admin) != AdminPermission || admin.RemoteAddr() != anon.RemoteAddr() {
+		t.Errorf("Authenticate() = %v, %v, %v", admin.Username(), sdb.Permissions(admin), admin.RemoteAddr())
+	}
+
+	subscribed, err := sdb.Ping(admin, "set", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(admin.Events(), Events{GET}) {
+		t.Errorf("Ping() changed the passed session, got = %v", admin.Events())
+	}
+
+	if !reflect.DeepEqual(subscribed.Events(), Events{GET, SET}) {
+		t.Errorf("Ping() events = %v, want %v", subscribed.Events(), Events{GET, SET})
+	}
+
+	if err := sdb.Set(anon, "k1", 1, 0); err == nil {
+		t.Errorf("Set() with an unauthenticated session should fail")
+	}
+}
@@ -19,17 +19,29 @@ type DBUser struct {
 	// Events determines all the Events to which a database
 	// user has subscribed
 	Events Events
+
+	// Roles are the names of the roles assigned to the user, the
+	// user has the permissions of these roles along with the
+	// permissions of its Access level
+	Roles []string
+
+	// Rules scope the permissions of the user to the keys matching
+	// them. A user without any rules can access every key
+	Rules []Rule
 }
 
 // NewDBUser creates a new database user object and return it
 // It does not create an entry in the user's database for the user
 func NewDBUser(username, pass string, access Access, events Events) DBUser {
-	return DBUser{username, pass, access, events}
+	return DBUser{username, pass, access, events, nil, nil}
 }
 
 // ToDBUser converts an interface{} to DBUser type
 // if the passed interface{} is not a DBUser then the
 // function panics
+//
+// Users loaded from a json backup come back as maps,
+// those are converted to DBUser as well
 func ToDBUser(data interface{}) DBUser {
 	v, ok := data.(DBUser)
 	if !ok {
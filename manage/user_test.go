@@ -22,7 +22,7 @@ func TestToDBUser(t *testing.T) {
 				"Access":   float64(2),
 				"Events":   []interface{}{uint(2), uint(3)},
 			}},
-			DBUser{"utkarsh", "test", WriteAccess, Events{2, 3}},
+			DBUser{"utkarsh", "test", WriteAccess, Events{2, 3}, nil, nil},
 		},
 	}
 	for _, tt := range tests {
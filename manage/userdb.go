@@ -1,5 +1,10 @@
 package manage
 
+import (
+	"sort"
+	"strings"
+)
+
 // UserDB is just an abstraction over the UnsecureStore
 // it is meant to be used to store the database user's info
 // so the authentication and authorization are not required
@@ -8,15 +13,49 @@ type UserDB struct {
 	UnsecureStore
 }
 
-// New adds a new db user to the database
-func (udb *UserDB) New(username, password string, access Access, events Events) {
-	udb.Set(username, NewDBUser(username, password, access, events), udb.DefaultExpiry())
+// New adds a new db user to the database. The password
+// is hashed before the user is stored
+func (udb *UserDB) New(username, password string, access Access, events Events) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	udb.save(NewDBUser(username, hash, access, events))
+	return nil
+}
+
+// Bootstrap creates the user or, if it already exists, changes its
+// password and access level while keeping its roles and subscriptions
+func (udb *UserDB) Bootstrap(username, password string, access Access) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, ok := udb.FindUserByUsername(username)
+	if !ok {
+		user = NewDBUser(username, "", access, Events{})
+	}
+
+	user.Password, user.Access = hash, access
+	udb.save(user)
+	return nil
+}
+
+// save stores the user as it is
+func (udb *UserDB) save(user DBUser) {
+	udb.Set(user.Username, user, udb.DefaultExpiry())
 }
 
 // FindUserByUsername finds a user by its username in the user database
 // It returns the DBUser and true if the user exists or an empty
 // DBUser object and false
 func (udb *UserDB) FindUserByUsername(username string) (DBUser, bool) {
+	if isRoleKey(username) {
+		return NewDBUser("", "", NONE, Events{}), false
+	}
+
 	user, ok := udb.Get(username)
 	if !ok {
 		return NewDBUser("", "", NONE, Events{}), false
@@ -24,3 +63,160 @@ func (udb *UserDB) FindUserByUsername(username string) (DBUser, bool) {
 
 	return ToDBUser(user), true
 }
+
+// Exists returns true if a user with the username exists
+func (udb *UserDB) Exists(username string) bool {
+	_, ok := udb.FindUserByUsername(username)
+	return ok
+}
+
+// DeleteUser removes the user, it returns false if it didn't exist
+func (udb *UserDB) DeleteUser(username string) bool {
+	if !udb.Exists(username) {
+		return false
+	}
+
+	_, ok := udb.Delete(username)
+	return ok
+}
+
+// SetPassword hashes the password and stores it for the user
+func (udb *UserDB) SetPassword(username, password string) error {
+	user, ok := udb.FindUserByUsername(username)
+	if !ok {
+		return userNotFoundErr(username)
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = hash
+	udb.save(user)
+	return nil
+}
+
+// SetAccess changes the access level of the user
+func (udb *UserDB) SetAccess(username string, access Access) error {
+	user, ok := udb.FindUserByUsername(username)
+	if !ok {
+		return userNotFoundErr(username)
+	}
+
+	user.Access = access
+	udb.save(user)
+	return nil
+}
+
+// Users returns every user sorted by their usernames
+func (udb *UserDB) Users() []DBUser {
+	var users []DBUser
+	for _, key := range udb.Keys() {
+		if user, ok := udb.FindUserByUsername(key); ok {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
+// MigratePasswords replaces the passwords stored in plain text by the
+// users created before hashing was introduced with their hashes
+//
+// It returns the number of users which were migrated
+func (udb *UserDB) MigratePasswords() (int, error) {
+	migrated := 0
+
+	for _, username := range udb.Keys() {
+		user, ok := udb.FindUserByUsername(username)
+		if !ok || IsHashedPassword(user.Password) {
+			continue
+		}
+
+		hash, err := HashPassword(user.Password)
+		if err != nil {
+			return migrated, err
+		}
+
+		user.Password = hash
+		udb.save(user)
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// rolePrefix is prepended to the names of the roles to get the
+// keys under which they are stored along with the users
+const rolePrefix = "role:"
+
+// isRoleKey returns true if the key belongs to a role
+func isRoleKey(key string) bool {
+	return strings.HasPrefix(key, rolePrefix)
+}
+
+// FindRole finds a role by its name. Built-in roles are found
+// even though they are not stored
+func (udb *UserDB) FindRole(name string) (Role, bool) {
+	if perms, ok := builtinRoles[name]; ok {
+		return Role{name, perms}, true
+	}
+
+	role, ok := udb.Get(rolePrefix + name)
+	if !ok {
+		return Role{}, false
+	}
+
+	r, ok := role.(Role)
+	return r, ok
+}
+
+// SaveRole stores the role
+func (udb *UserDB) SaveRole(role Role) {
+	udb.Set(rolePrefix+role.Name, role, udb.DefaultExpiry())
+}
+
+// DeleteRole removes the role, it returns false if it didn't exist
+func (udb *UserDB) DeleteRole(name string) bool {
+	_, ok := udb.Delete(rolePrefix + name)
+	return ok
+}
+
+// Roles returns every role including the built-in ones
+// sorted by their names
+func (udb *UserDB) Roles() []Role {
+	var roles []Role
+	for name, perms := range builtinRoles {
+		roles = append(roles, Role{name, perms})
+	}
+
+	for _, key := range udb.Keys() {
+		if !isRoleKey(key) {
+			continue
+		}
+
+		if role, ok := udb.FindRole(strings.TrimPrefix(key, rolePrefix)); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	sortRoles(roles)
+	return roles
+}
+
+// Permissions returns the permissions of the user which are the
+// permissions of its access level and of every role assigned to it
+func (udb *UserDB) Permissions(user DBUser) Permission {
+	perms := user.Access.Permissions()
+
+	for _, name := range user.Roles {
+		// Roles which have been dropped are ignored
+		if role, ok := udb.FindRole(name); ok {
+			perms |= role.Permissions
+		}
+	}
+
+	return perms
+}
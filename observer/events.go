@@ -7,5 +7,6 @@ const (
 	opSet         event = "op_set"
 	opWipe        event = "op_wipe"
 	opDel         event = "op_del"
+	opLockout     event = "op_lockout"
 	verifiedEvent event = "verified_event"
 )
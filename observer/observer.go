@@ -1,6 +1,7 @@
 package observer
 
 import (
+	"sync"
 	"time"
 
 	"github.com/utkarsh-pro/RapidoDB/eventbus"
@@ -13,13 +14,21 @@ import (
 //
 // ObserverDB is very tightly tied to the Client Management layer and
 // the singleton instance of the event bus
+//
+// An ObservedDB belongs to a single client, it performs every operation
+// on the shared SecureDB on behalf of the session of that client
 type ObservedDB struct {
-	*manage.SecureDB
+	sdb *manage.SecureDB
+
+	// mu guards the session which is replaced whenever the client
+	// authenticates or changes its subscriptions
+	mu      sync.RWMutex
+	session *manage.Session
 }
 
-// New returns a new observed store
-func New(db *manage.SecureDB) (*ObservedDB, *eventbus.EventBus) {
-	odb := &ObservedDB{db}
+// New returns a new observed store which acts on behalf of the session
+func New(db *manage.SecureDB, session *manage.Session) (*ObservedDB, *eventbus.EventBus) {
+	odb := &ObservedDB{sdb: db, session: session}
 	eb := eventbus.New()
 
 	go setupListenersAndDispatcher(
@@ -29,6 +38,7 @@ func New(db *manage.SecureDB) (*ObservedDB, *eventbus.EventBus) {
 		string(opSet),
 		string(opDel),
 		string(opWipe),
+		string(opLockout),
 	)
 
 	return odb, eb
@@ -40,52 +50,355 @@ func New(db *manage.SecureDB) (*ObservedDB, *eventbus.EventBus) {
 // Whenever a set operation is completed, this publishes a "op_set" event
 func (ost *ObservedDB) Set(key string, data interface{}, expireIn time.Duration) error {
 	// perform the action
-	err := ost.SecureDB.Set(key, data, expireIn)
+	err := ost.sdb.Set(ost.Session(), key, data, expireIn)
 	// publish the event
 	publish(opSet, key, data)
 
 	return err
 }
 
+// SetIf is like Set except that the "op_set" event is
+// only published if the condition held
+func (ost *ObservedDB) SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) (bool, error) {
+	// perform the action
+	ok, err := ost.sdb.SetIf(ost.Session(), key, data, expireIn, cond)
+	// publish the event
+	if ok {
+		publish(opSet, key, data)
+	}
+
+	return ok, err
+}
+
 // Get is a thin wapper over the native get method adds an observer
 // on the get operation
 //
 // Whenever a get operation is completed, this published a "op_get" event
 func (ost *ObservedDB) Get(key string) (interface{}, bool, error) {
 	// perform the action
-	v, ok, err := ost.SecureDB.Get(key)
+	v, ok, err := ost.sdb.Get(ost.Session(), key)
 	// publish the event
 	publish(opGet, key, v)
 
 	return v, ok, err
 }
 
+// GetVersion is like Get except that it also returns the version
+// of the item, it publishes a "op_get" event as well
+func (ost *ObservedDB) GetVersion(key string) (interface{}, uint64, bool, error) {
+	// perform the action
+	v, version, ok, err := ost.sdb.GetVersion(ost.Session(), key)
+	// publish the event
+	publish(opGet, key, v)
+
+	return v, version, ok, err
+}
+
+// SetIfVersion is like Set except that the "op_set" event
+// is only published if the version matched
+func (ost *ObservedDB) SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool, error) {
+	// perform the action
+	v, ok, err := ost.sdb.SetIfVersion(ost.Session(), key, version, data, expireIn)
+	// publish the event
+	if ok {
+		publish(opSet, key, data)
+	}
+
+	return v, ok, err
+}
+
 // Delete is a thin wapper over the native delete method adds an observer
 // on the delete operation
 //
 // Whenever a delete operation is completed, this published a "op_delete" event
 func (ost *ObservedDB) Delete(key string) (interface{}, bool, error) {
 	// perform the action
-	v, ok, err := ost.SecureDB.Delete(key)
+	v, ok, err := ost.sdb.Delete(ost.Session(), key)
 	// publish the event
 	publish(opDel, key, v)
 
 	return v, ok, err
 }
 
+// DeleteIf is like Delete except that the "op_del" events
+// are only published if the condition held
+func (ost *ObservedDB) DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool, error) {
+	// perform the action
+	vs, ok, err := ost.sdb.DeleteIf(ost.Session(), keys, cond)
+	// publish the events
+	if ok {
+		for i, key := range keys {
+			publish(opDel, key, vs[i])
+		}
+	}
+
+	return vs, ok, err
+}
+
+// DeleteIfVersion is like Delete except that the "op_del"
+// event is only published if the version matched
+func (ost *ObservedDB) DeleteIfVersion(key string, version uint64) (interface{}, bool, error) {
+	// perform the action
+	v, ok, err := ost.sdb.DeleteIfVersion(ost.Session(), key, version)
+	// publish the event
+	if ok {
+		publish(opDel, key, v)
+	}
+
+	return v, ok, err
+}
+
+// ExpireAt is a thin wrapper over the native expire at method
+func (ost *ObservedDB) ExpireAt(key string) (time.Time, bool, error) {
+	return ost.sdb.ExpireAt(ost.Session(), key)
+}
+
+// SetExpireAt is a thin wrapper over the native set expire at method
+func (ost *ObservedDB) SetExpireAt(key string, expireAt time.Time) (bool, error) {
+	return ost.sdb.SetExpireAt(ost.Session(), key, expireAt)
+}
+
+// Incr is a thin wrapper over the native incr method which adds
+// an observer on the operation
+//
+// Whenever a counter is changed, this publishes a "op_set" event
+// carrying the new value of the counter
+func (ost *ObservedDB) Incr(key string, delta int64) (int64, error) {
+	// perform the action
+	n, err := ost.sdb.Incr(ost.Session(), key, delta)
+	// publish the event
+	if err == nil {
+		publish(opSet, key, n)
+	}
+
+	return n, err
+}
+
+// IncrFloat is like Incr for the float counters
+func (ost *ObservedDB) IncrFloat(key string, delta float64) (float64, error) {
+	// perform the action
+	f, err := ost.sdb.IncrFloat(ost.Session(), key, delta)
+	// publish the event
+	if err == nil {
+		publish(opSet, key, f)
+	}
+
+	return f, err
+}
+
 // Wipe is a thin wapper over the native wipe method adds an observer
 // on the wipe operation
 //
 // Whenever a wipe operation is completed, this published a "op_wipe" event
 func (ost *ObservedDB) Wipe() error {
 	// perform the action
-	err := ost.SecureDB.Wipe()
+	err := ost.sdb.Wipe(ost.Session())
 	// publish the event
 	publish(opWipe, "wipe", true)
 
 	return err
 }
 
+// Authenticate authenticates the client and replaces its session
+// with the one of the authenticated user
+func (ost *ObservedDB) Authenticate(username, password string) error {
+	ost.mu.Lock()
+	defer ost.mu.Unlock()
+
+	s, err := ost.sdb.Authenticate(ost.session, username, password)
+	if err != nil {
+		return err
+	}
+
+	ost.session = s
+	return nil
+}
+
+// RegisterUser registers a new user on behalf of the client
+func (ost *ObservedDB) RegisterUser(username, password string, access uint, replace bool) error {
+	return ost.sdb.RegisterUser(ost.Session(), username, password, access, replace)
+}
+
+// DeleteUser removes the user
+func (ost *ObservedDB) DeleteUser(username string) error {
+	return ost.sdb.DeleteUser(ost.Session(), username)
+}
+
+// ChangePassword changes the password of the authenticated user
+func (ost *ObservedDB) ChangePassword(oldPassword, newPassword string) error {
+	return ost.sdb.ChangePassword(ost.Session(), oldPassword, newPassword)
+}
+
+// ResetPassword forces a new password upon the user
+func (ost *ObservedDB) ResetPassword(username, password string) error {
+	return ost.sdb.ResetPassword(ost.Session(), username, password)
+}
+
+// SetAccess changes the access level of the user
+func (ost *ObservedDB) SetAccess(username string, access uint) error {
+	return ost.sdb.SetAccess(ost.Session(), username, access)
+}
+
+// Users returns the names of the permissions of every
+// user keyed by the usernames
+func (ost *ObservedDB) Users() (map[string][]string, error) {
+	users, err := ost.sdb.Users(ost.Session())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string, len(users))
+	for username, perms := range users {
+		res[username] = perms.Names()
+	}
+
+	return res, nil
+}
+
+// WhoAmI returns the username of the client and the
+// names of the permissions granted to it
+func (ost *ObservedDB) WhoAmI() (string, []string) {
+	username, perms := ost.sdb.WhoAmI(ost.Session())
+	return username, perms.Names()
+}
+
+// Ping subscribes (or unsubscribes) the client to the event
+func (ost *ObservedDB) Ping(event string, on bool) error {
+	ost.mu.Lock()
+	defer ost.mu.Unlock()
+
+	s, err := ost.sdb.Ping(ost.session, event, on)
+	if err != nil {
+		return err
+	}
+
+	ost.session = s
+	return nil
+}
+
+// ConfigGet returns the settings matching the glob pattern
+func (ost *ObservedDB) ConfigGet(pattern string) (map[string]string, error) {
+	return ost.sdb.ConfigGet(ost.Session(), pattern)
+}
+
+// ConfigSet changes a setting of the running database
+func (ost *ObservedDB) ConfigSet(name, value string) error {
+	return ost.sdb.ConfigSet(ost.Session(), name, value)
+}
+
+// ConfigRewrite writes the configuration back to the configuration file
+func (ost *ObservedDB) ConfigRewrite() error {
+	return ost.sdb.ConfigRewrite(ost.Session())
+}
+
+// CreateRole creates a role with the permissions
+func (ost *ObservedDB) CreateRole(name string, permissions []string) error {
+	return ost.sdb.CreateRole(ost.Session(), name, permissions)
+}
+
+// DropRole removes the role
+func (ost *ObservedDB) DropRole(name string) error {
+	return ost.sdb.DropRole(ost.Session(), name)
+}
+
+// GrantRole adds the permissions to the role
+func (ost *ObservedDB) GrantRole(name string, permissions []string) error {
+	return ost.sdb.GrantRole(ost.Session(), name, permissions)
+}
+
+// RevokeRole removes the permissions from the role
+func (ost *ObservedDB) RevokeRole(name string, permissions []string) error {
+	return ost.sdb.RevokeRole(ost.Session(), name, permissions)
+}
+
+// AssignRole assigns the role to the user
+func (ost *ObservedDB) AssignRole(name, username string) error {
+	return ost.sdb.AssignRole(ost.Session(), name, username)
+}
+
+// UnassignRole removes the role from the user
+func (ost *ObservedDB) UnassignRole(name, username string) error {
+	return ost.sdb.UnassignRole(ost.Session(), name, username)
+}
+
+// Roles returns the names of the permissions of every role
+// keyed by the names of the roles
+func (ost *ObservedDB) Roles() (map[string][]string, error) {
+	roles, err := ost.sdb.Roles(ost.Session())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string, len(roles))
+	for _, r := range roles {
+		res[r.Name] = r.Permissions.Names()
+	}
+
+	return res, nil
+}
+
+// AddRule scopes the access of the user to the keys matching the pattern
+func (ost *ObservedDB) AddRule(username, pattern string, permissions []string) error {
+	return ost.sdb.AddRule(ost.Session(), username, pattern, permissions)
+}
+
+// DeleteRule removes the rule with the pattern from the user
+func (ost *ObservedDB) DeleteRule(username, pattern string) error {
+	return ost.sdb.DeleteRule(ost.Session(), username, pattern)
+}
+
+// Rules returns the names of the permissions of every
+// rule of the user keyed by the patterns of the rules
+func (ost *ObservedDB) Rules(username string) (map[string][]string, error) {
+	rules, err := ost.sdb.Rules(ost.Session(), username)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string, len(rules))
+	for _, r := range rules {
+		res[r.Pattern] = r.Permissions.Names()
+	}
+
+	return res, nil
+}
+
+// Session returns the current session of the client
+func (ost *ObservedDB) Session() *manage.Session {
+	ost.mu.RLock()
+	defer ost.mu.RUnlock()
+
+	return ost.session
+}
+
+// Locks returns the time left until the lockout of
+// every locked out target ends keyed by the targets
+func (ost *ObservedDB) Locks() (map[string]time.Duration, error) {
+	locks, err := ost.sdb.Locks(ost.Session())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := make(map[string]time.Duration, len(locks))
+	for _, l := range locks {
+		res[l.Target] = l.Until.Sub(now)
+	}
+
+	return res, nil
+}
+
+// Unlock lifts the lockouts of the targets matching the pattern
+func (ost *ObservedDB) Unlock(pattern string) (int, error) {
+	return ost.sdb.Unlock(ost.Session(), pattern)
+}
+
+// PublishLockout publishes a "op_lockout" event for the lockout, it
+// is meant to be passed to the OnLockout method of the SecureDB
+func PublishLockout(l manage.Lockout) {
+	publish(opLockout, l.Target, l.Until)
+}
+
 // publish publishes the event to the event bus to be consumed by the subscribers
 func publish(event event, key string, value interface{}) {
 	eventbus.Instance.Publish(string(event), eventbus.NewDataEvent(string(event), key, value))
@@ -93,11 +406,13 @@ func publish(event event, key string, value interface{}) {
 
 // setupListenerAndDispatcher sets up the listeners on the multiplexed channel
 // it publishes "verified_event" if an event is subscribed by the current client
+// and the client is still allowed to receive it
 func setupListenersAndDispatcher(odb *ObservedDB, eb *eventbus.EventBus, events ...string) {
 	muxcd := eventbus.ChannelMultiplexer(eventbus.Instance, 0, events...)
 
 	for msg := range muxcd {
-		if odb.IsSubscribed(eventToClientEvent(event(msg.Event()))) {
+		ev, session := eventToClientEvent(event(msg.Event())), odb.Session()
+		if session.IsSubscribed(ev) && odb.sdb.AuthorizeEvent(session, ev, msg.Key()) {
 			eb.Publish(
 				string(verifiedEvent),
 				eventbus.NewDataEvent(string(msg.Event()),
@@ -121,6 +436,8 @@ func eventToClientEvent(event event) manage.Event {
 		return manage.DEL
 	case opWipe:
 		return manage.WIPE
+	case opLockout:
+		return manage.LOCKOUT
 	default:
 		return manage.NULL
 	}
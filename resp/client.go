@@ -0,0 +1,473 @@
+package resp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecureDB interface defines the operations of the
+// database which are mapped onto the RESP commands
+type SecureDB interface {
+	Set(key string, data interface{}, expireIn time.Duration) error
+	Get(key string) (interface{}, bool, error)
+	Delete(key string) (interface{}, bool, error)
+	Wipe() error
+	Authenticate(username string, password string) error
+	Ping(event string, on bool) error
+}
+
+// defaultUser is the user authenticated by AUTH without a username
+const defaultUser = "default"
+
+// Client represents an active TCP client speaking RESP
+type Client struct {
+	conn net.Conn
+	log  *log.Logger
+	db   SecureDB
+	r    *Reader
+
+	// execMu is held while a command is being executed
+	// so that closing the client waits for it to finish
+	execMu sync.Mutex
+
+	// subscribed are the events subscribed by the client
+	// they are only accessed while holding execMu
+	subscribed map[string]bool
+
+	// writeMu serializes the writes on the connection
+	// and guards the writer and the closed flag
+	writeMu sync.Mutex
+	w       *Writer
+
+	// closed is set once the client has been closed
+	closed bool
+}
+
+// New returns a new client instance
+func New(conn net.Conn, l *log.Logger, db SecureDB) *Client {
+	return &Client{
+		conn:       conn,
+		log:        l,
+		db:         db,
+		r:          NewReader(conn),
+		w:          NewWriter(conn),
+		subscribed: make(map[string]bool),
+	}
+}
+
+// SetMaxRequestSize sets the maximum size in bytes of the commands, a
+// client sending a larger command is disconnected. It can be called
+// while the client is served
+func (c *Client) SetMaxRequestSize(n int) {
+	c.r.SetMaxRequestSize(n)
+}
+
+// InitRead reads the commands of the client and executes them
+// until the client disconnects or is closed
+func (c *Client) InitRead() {
+	for {
+		args, err := c.r.ReadCommand()
+		if err != nil {
+			// The connection was closed on purpose
+			if c.isClosed() {
+				return
+			}
+
+			if err == io.EOF {
+				c.log.Printf("Client %s disconnected", c.conn.RemoteAddr().String())
+				c.conn.Close()
+				return
+			}
+
+			// The stream cannot be resynchronized after a protocol error
+			if errors.Is(err, ErrProtocol) {
+				c.reply(func(w *Writer) { w.Error("ERR " + err.Error()) })
+				c.conn.Close()
+			}
+
+			c.log.Printf("Error from client %s: %v", c.conn.RemoteAddr().String(), err)
+			return
+		}
+
+		if !c.exec(args) {
+			return
+		}
+	}
+}
+
+// exec executes the command and sends back the reply. It returns
+// false if the client has been closed in the meantime or has quit
+func (c *Client) exec(args []string) bool {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	name := strings.ToUpper(args[0])
+	if name == "QUIT" {
+		c.reply(func(w *Writer) { w.SimpleString("OK") })
+		c.conn.Close()
+		return false
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		c.reply(func(w *Writer) { w.Error(fmt.Sprintf("ERR unknown command '%s'", args[0])) })
+		return true
+	}
+
+	if len(args)-1 < cmd.min || (cmd.max >= 0 && len(args)-1 > cmd.max) {
+		c.reply(func(w *Writer) {
+			w.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+		})
+		return true
+	}
+
+	cmd.exec(c, args[1:])
+	return true
+}
+
+// Close waits for the command being executed (if any) to finish,
+// sends the notice to the client as an error and closes the
+// connection. No more commands are executed once it is closed
+func (c *Client) Close(notice string) error {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	if notice != "" {
+		// The notices of the server may already be marked as errors
+		notice = strings.TrimPrefix(notice, "ERR: ")
+		c.reply(func(w *Writer) { w.Error("ERR " + notice) })
+	}
+
+	c.writeMu.Lock()
+	c.closed = true
+	c.writeMu.Unlock()
+
+	return c.conn.Close()
+}
+
+// Event pushes the event to the client as a message of the
+// subscribed event. The events of the observer are named
+// like "op_set" while the clients subscribe to "set"
+func (c *Client) Event(event, key string, value interface{}) {
+	event = strings.TrimPrefix(event, "op_")
+
+	c.reply(func(w *Writer) {
+		w.Push(3)
+		w.Bulk("message")
+		w.Bulk(event)
+		w.Bulk(key)
+	})
+}
+
+// isClosed returns true if the client has been closed
+func (c *Client) isClosed() bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.closed
+}
+
+// reply writes the reply on the connection unless
+// the client is closed and sends it right away
+func (c *Client) reply(write func(w *Writer)) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	write(c.w)
+	c.w.Flush()
+}
+
+// command describes a command and the number of arguments
+// it takes, a max of less than zero means unlimited
+type command struct {
+	min, max int
+	exec     func(c *Client, args []string)
+}
+
+// commands are the commands understood by the client
+var commands = map[string]command{
+	"AUTH":        {1, 2, (*Client).auth},
+	"HELLO":       {0, 6, (*Client).hello},
+	"GET":         {1, 1, (*Client).get},
+	"SET":         {2, 4, (*Client).set},
+	"DEL":         {1, -1, (*Client).del},
+	"FLUSHALL":    {0, 1, (*Client).flush},
+	"FLUSHDB":     {0, 1, (*Client).flush},
+	"SUBSCRIBE":   {1, -1, (*Client).subscribe},
+	"UNSUBSCRIBE": {0, -1, (*Client).unsubscribe},
+	"PING":        {0, 1, (*Client).ping},
+	"ECHO":        {1, 1, (*Client).echo},
+	"SELECT":      {1, 1, (*Client).selectDB},
+	"COMMAND":     {0, -1, (*Client).command},
+}
+
+// auth authenticates the client, the username
+// defaults to "default" like it does in Redis
+func (c *Client) auth(args []string) {
+	username, password := defaultUser, args[0]
+	if len(args) == 2 {
+		username, password = args[0], args[1]
+	}
+
+	c.ok(c.db.Authenticate(username, password))
+}
+
+// hello switches the protocol version, authenticating
+// the client first if asked to, and describes the server
+func (c *Client) hello(args []string) {
+	proto := 0
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v < 2 || v > 3 {
+			c.reply(func(w *Writer) { w.Error("NOPROTO unsupported protocol version") })
+			return
+		}
+		proto, args = v, args[1:]
+	}
+
+	for len(args) > 0 {
+		switch {
+		case strings.EqualFold(args[0], "AUTH") && len(args) >= 3:
+			if err := c.db.Authenticate(args[1], args[2]); err != nil {
+				c.err(err)
+				return
+			}
+			args = args[3:]
+		case strings.EqualFold(args[0], "SETNAME") && len(args) >= 2:
+			args = args[2:]
+		default:
+			c.reply(func(w *Writer) { w.Error("ERR syntax error") })
+			return
+		}
+	}
+
+	c.reply(func(w *Writer) {
+		if proto != 0 {
+			w.SetProtocol(proto)
+		}
+
+		w.Map(3)
+		w.Bulk("server")
+		w.Bulk("rapidodb")
+		w.Bulk("proto")
+		w.Integer(int64(w.Protocol()))
+		w.Bulk("mode")
+		w.Bulk("standalone")
+	})
+}
+
+// get replies with the value of the key as a bulk string
+func (c *Client) get(args []string) {
+	v, ok, err := c.db.Get(args[0])
+	if err != nil {
+		c.err(err)
+		return
+	}
+
+	c.reply(func(w *Writer) {
+		if !ok {
+			w.Null()
+			return
+		}
+
+		w.Bulk(formatValue(v))
+	})
+}
+
+// formatValue returns the text of the value sent in a bulk
+// string, the JSON documents are sent as their JSON text
+func formatValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+// set stores the value against the key, the expiry is
+// set by either EX in seconds or PX in milliseconds
+func (c *Client) set(args []string) {
+	var expireIn time.Duration
+
+	if len(args) > 2 {
+		if len(args) != 4 {
+			c.reply(func(w *Writer) { w.Error("ERR syntax error") })
+			return
+		}
+
+		var unit time.Duration
+		switch strings.ToUpper(args[2]) {
+		case "EX":
+			unit = time.Second
+		case "PX":
+			unit = time.Millisecond
+		default:
+			c.reply(func(w *Writer) { w.Error("ERR syntax error") })
+			return
+		}
+
+		n, err := strconv.ParseInt(args[3], 10, 64)
+		if err != nil || n <= 0 || n > int64(1<<63-1)/int64(unit) {
+			c.reply(func(w *Writer) { w.Error("ERR invalid expire time in 'set' command") })
+			return
+		}
+		expireIn = time.Duration(n) * unit
+	}
+
+	c.ok(c.db.Set(args[0], args[1], expireIn))
+}
+
+// del deletes the keys and replies with the number of deleted keys
+func (c *Client) del(args []string) {
+	var deleted int64
+	for _, key := range args {
+		_, ok, err := c.db.Delete(key)
+		if err != nil {
+			c.err(err)
+			return
+		}
+
+		if ok {
+			deleted++
+		}
+	}
+
+	c.reply(func(w *Writer) { w.Integer(deleted) })
+}
+
+// flush deletes every key, the ASYNC and SYNC modes are the same
+func (c *Client) flush(args []string) {
+	c.ok(c.db.Wipe())
+}
+
+// subscribe subscribes the client to the events
+func (c *Client) subscribe(args []string) {
+	for _, event := range args {
+		event = strings.ToLower(event)
+		if err := c.db.Ping(event, true); err != nil {
+			c.err(err)
+			return
+		}
+
+		c.subscribed[event] = true
+		c.subscription("subscribe", event)
+	}
+}
+
+// unsubscribe unsubscribes the client from the
+// events, or from every event if none are passed
+func (c *Client) unsubscribe(args []string) {
+	if len(args) == 0 {
+		for event := range c.subscribed {
+			args = append(args, event)
+		}
+		sort.Strings(args)
+
+		if len(args) == 0 {
+			c.reply(func(w *Writer) {
+				w.Push(3)
+				w.Bulk("unsubscribe")
+				w.Null()
+				w.Integer(0)
+			})
+			return
+		}
+	}
+
+	for _, event := range args {
+		event = strings.ToLower(event)
+		if err := c.db.Ping(event, false); err != nil {
+			c.err(err)
+			return
+		}
+
+		delete(c.subscribed, event)
+		c.subscription("unsubscribe", event)
+	}
+}
+
+// subscription confirms the (un)subscription of the event
+func (c *Client) subscription(kind, event string) {
+	count := int64(len(c.subscribed))
+
+	c.reply(func(w *Writer) {
+		w.Push(3)
+		w.Bulk(kind)
+		w.Bulk(event)
+		w.Integer(count)
+	})
+}
+
+// ping replies with PONG or with the message
+func (c *Client) ping(args []string) {
+	c.reply(func(w *Writer) {
+		if len(args) == 0 {
+			w.SimpleString("PONG")
+			return
+		}
+
+		w.Bulk(args[0])
+	})
+}
+
+// echo replies with the message
+func (c *Client) echo(args []string) {
+	c.reply(func(w *Writer) { w.Bulk(args[0]) })
+}
+
+// selectDB accepts only the database 0 as there is a single database
+func (c *Client) selectDB(args []string) {
+	c.reply(func(w *Writer) {
+		if args[0] != "0" {
+			w.Error("ERR DB index is out of range")
+			return
+		}
+
+		w.SimpleString("OK")
+	})
+}
+
+// command replies with an empty list of commands, it
+// is only there for the clients which call it on startup
+func (c *Client) command(args []string) {
+	c.reply(func(w *Writer) { w.Array(0) })
+}
+
+// ok replies with OK or with the error
+func (c *Client) ok(err error) {
+	if err != nil {
+		c.err(err)
+		return
+	}
+
+	c.reply(func(w *Writer) { w.SimpleString("OK") })
+}
+
+// err replies with the error of the database
+func (c *Client) err(err error) {
+	c.reply(func(w *Writer) { w.Error("ERR " + err.Error()) })
+}
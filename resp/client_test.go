@@ -0,0 +1,147 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MockDB is an in memory SecureDB which only lets
+// the user "admin" with the password "pass" in
+type MockDB struct {
+	data   map[string]interface{}
+	expiry map[string]time.Duration
+	authed bool
+	pinged map[string]bool
+}
+
+func (db *MockDB) Set(key string, data interface{}, expireIn time.Duration) error {
+	if !db.authed {
+		return errors.New("Access denied")
+	}
+	db.data[key], db.expiry[key] = data, expireIn
+	return nil
+}
+
+func (db *MockDB) Get(key string) (interface{}, bool, error) {
+	v, ok := db.data[key]
+	return v, ok, nil
+}
+
+func (db *MockDB) Delete(key string) (interface{}, bool, error) {
+	v, ok := db.data[key]
+	delete(db.data, key)
+	return v, ok, nil
+}
+
+func (db *MockDB) Wipe() error {
+	db.data = make(map[string]interface{})
+	return nil
+}
+
+func (db *MockDB) Authenticate(username, password string) error {
+	if username != "admin" || password != "pass" {
+		return errors.New("Invalid Credentials")
+	}
+	db.authed = true
+	return nil
+}
+
+func (db *MockDB) Ping(event string, on bool) error {
+	db.pinged[event] = on
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	db := &MockDB{
+		data:   map[string]interface{}{"n": 42},
+		expiry: make(map[string]time.Duration),
+		pinged: make(map[string]bool),
+	}
+
+	server, conn := net.Pipe()
+	c := New(server, log.New(ioutil.Discard, "", 0), db)
+	go c.InitRead()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	// send writes the command, if any, and reads the n lines of the reply
+	send := func(cmd string, n int) string {
+		if cmd != "" {
+			go conn.Write([]byte(cmd))
+		}
+
+		var lines []string
+		for i := 0; i < n; i++ {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\r\n"))
+		}
+		return strings.Join(lines, " ")
+	}
+
+	tests := []struct {
+		name  string
+		cmd   string
+		lines int
+		want  string
+	}{
+		{"PING", "PING\r\n", 1, "+PONG"},
+		{"SET WITHOUT AUTHENTICATION", "SET k1 v1\r\n", 1, "-ERR Access denied"},
+		{"AUTH WITH INVALID PASSWORD", "AUTH admin wrong\r\n", 1, "-ERR Invalid Credentials"},
+		{"AUTH WITH DEFAULT USER", "AUTH pass\r\n", 1, "-ERR Invalid Credentials"},
+		{"AUTH", "*3\r\n$4\r\nAUTH\r\n$5\r\nadmin\r\n$4\r\npass\r\n", 1, "+OK"},
+		{"SET WITH PX", "*5\r\n$3\r\nSET\r\n$2\r\nk1\r\n$5\r\nhello\r\n$2\r\npx\r\n$4\r\n1500\r\n", 1, "+OK"},
+		{"SET WITH INVALID EXPIRY", "SET k1 v1 EX -1\r\n", 1, "-ERR invalid expire time in 'set' command"},
+		{"SET WITH UNKNOWN OPTION", "SET k1 v1 KEEPTTL 1\r\n", 1, "-ERR syntax error"},
+		{"GET", "GET k1\r\n", 2, "$5 hello"},
+		{"GET NON STRING VALUE", "GET n\r\n", 2, "$2 42"},
+		{"GET MISSING KEY", "GET k2\r\n", 1, "$-1"},
+		{"WRONG NUMBER OF ARGUMENTS", "GET\r\n", 1, "-ERR wrong number of arguments for 'get' command"},
+		{"UNKNOWN COMMAND", "LPUSH l 1\r\n", 1, "-ERR unknown command 'LPUSH'"},
+		{"DEL", "DEL k1 k2 n\r\n", 1, ":2"},
+		{"SUBSCRIBE", "SUBSCRIBE set DEL\r\n", 6, "*3 $9 subscribe $3 set :1"},
+		{"SECOND SUBSCRIPTION", "", 6, "*3 $9 subscribe $3 del :2"},
+		{"HELLO 3", "HELLO 3\r\n", 12, "%3 $6 server $8 rapidodb $5 proto :3"},
+		{"RESP3 NULL", "GET k1\r\n", 1, "_"},
+		{"UNSUBSCRIBE ALL", "UNSUBSCRIBE\r\n", 6, ">3 $11 unsubscribe $3 del :1"},
+		{"LAST UNSUBSCRIPTION", "", 6, ">3 $11 unsubscribe $3 set :0"},
+		{"UNSUPPORTED PROTOCOL", "HELLO 4\r\n", 1, "-NOPROTO unsupported protocol version"},
+		{"SELECT", "SELECT 1\r\n", 1, "-ERR DB index is out of range"},
+		{"FLUSHALL", "FLUSHALL\r\n", 1, "+OK"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.cmd, tt.lines); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("%q = %q, want %q", tt.cmd, got, tt.want)
+			}
+		})
+	}
+
+	if db.expiry["k1"] != 1500*time.Millisecond {
+		t.Errorf("expiry of k1 = %v, want 1.5s", db.expiry["k1"])
+	}
+	if db.pinged["set"] || db.pinged["del"] {
+		t.Errorf("events = %v, want none subscribed", db.pinged)
+	}
+
+	// The events are pushed as messages
+	go c.Event("op_set", "k1", "hello")
+	if got := send("", 7); got != ">3 $7 message $3 set $2 k1" {
+		t.Errorf("event = %q, want a message of set", got)
+	}
+
+	// The clients are sent the notice when closed
+	go c.Close("Server is shutting down")
+	if got := send("", 1); got != "-ERR Server is shutting down" {
+		t.Errorf("notice = %q, want the shutdown notice", got)
+	}
+}
@@ -0,0 +1,291 @@
+/*
+   resp package is a transport front-end which speaks the Redis
+   serialization protocol (RESP2 and RESP3), so that the existing
+   Redis clients and tools like redis-cli can talk to RapidoDB.
+
+   The following commands are mapped onto the operations of the database
+
+   AUTH [username] password        Authenticate, the username defaults to "default"
+   HELLO [2|3 [AUTH user pass]]    switches the protocol version
+   GET key                         Get
+   SET key value [EX s | PX ms]    Set
+   DEL key [key ...]               Delete
+   FLUSHALL, FLUSHDB               Wipe
+   SUBSCRIBE event [event ...]     Ping, the events are get, set, del, wipe and lockout
+   UNSUBSCRIBE [event ...]         Ping
+   PING [message], ECHO message, SELECT 0, COMMAND and QUIT
+
+   The values are always returned as bulk strings like Redis does
+*/
+
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// maxBulkLen is the maximum length of a bulk string
+	maxBulkLen = 512 << 20
+
+	// maxArrayLen is the maximum number of arguments of a command
+	maxArrayLen = 1 << 20
+
+	// DefaultMaxRequestSize is the default maximum size of a
+	// command, which bounds the lengths above any further
+	DefaultMaxRequestSize = 64 << 20
+)
+
+// ErrProtocol is returned when the client doesn't speak RESP
+var ErrProtocol = errors.New("Protocol error")
+
+// Reader reads the commands sent by a client
+type Reader struct {
+	// max is the maximum size in bytes of a command, the size
+	// is unlimited if it is zero. It is accessed atomically so
+	// it comes first to be 64-bit aligned
+	max int64
+
+	r *bufio.Reader
+
+	// used is the size of the command being read so far
+	used int
+}
+
+// NewReader returns a reader of the commands sent over r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{max: DefaultMaxRequestSize, r: bufio.NewReader(r)}
+}
+
+// SetMaxRequestSize sets the maximum size in bytes of a command
+// reading a larger command fails with a protocol error. It can be
+// called while a command is read
+func (r *Reader) SetMaxRequestSize(n int) {
+	atomic.StoreInt64(&r.max, int64(n))
+}
+
+// ReadCommand reads the next command which is either an array of
+// bulk strings, as sent by the clients, or an inline command, as
+// typed into telnet. Empty inline commands are skipped
+func (r *Reader) ReadCommand() ([]string, error) {
+	for {
+		r.used = 0
+
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if line == "" {
+			continue
+		}
+
+		if line[0] != '*' {
+			if args := strings.Fields(line); len(args) > 0 {
+				return args, nil
+			}
+			continue
+		}
+
+		n, err := parseLen(line[1:], maxArrayLen)
+		if err != nil {
+			return nil, err
+		}
+
+		// The arguments are only allocated as they are read
+		// so that a large count alone doesn't take any memory
+		var args []string
+		for i := 0; i < n; i++ {
+			arg, err := r.readBulk()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+
+		if len(args) > 0 {
+			return args, nil
+		}
+	}
+}
+
+// readBulk reads a bulk string
+func (r *Reader) readBulk() (string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return "", err
+	}
+
+	if line == "" || line[0] != '$' {
+		return "", fmt.Errorf("%w: expected '$', got %q", ErrProtocol, line)
+	}
+
+	n, err := parseLen(line[1:], maxBulkLen)
+	if err != nil {
+		return "", err
+	}
+
+	if err := r.take(n + 2); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return "", err
+	}
+
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string is not terminated by CRLF", ErrProtocol)
+	}
+
+	return string(b[:n]), nil
+}
+
+// readLine reads a line without its terminating CRLF
+// or LF, the latter is accepted for inline commands
+func (r *Reader) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := r.r.ReadSlice('\n')
+		if err := r.take(len(b)); err != nil {
+			return "", err
+		}
+		line = append(line, b...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+
+	return strings.TrimSuffix(string(line[:len(line)-1]), "\r"), nil
+}
+
+// take accounts for the n bytes of the command being read, it fails
+// if the command would get larger than the maximum request size
+func (r *Reader) take(n int) error {
+	max := int(atomic.LoadInt64(&r.max))
+	if max <= 0 {
+		return nil
+	}
+
+	if n > max-r.used {
+		return fmt.Errorf("%w: command exceeds the maximum of %d bytes", ErrProtocol, max)
+	}
+
+	r.used += n
+	return nil
+}
+
+// parseLen parses the length of an array or a bulk string
+func parseLen(s string, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("%w: invalid length %q", ErrProtocol, s)
+	}
+
+	return n, nil
+}
+
+// Writer writes the replies to a client in the protocol
+// version chosen by the client, which is RESP2 by default
+type Writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+// NewWriter returns a writer of RESP2 replies over w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{bufio.NewWriter(w), 2}
+}
+
+// Protocol returns the protocol version of the replies
+func (w *Writer) Protocol() int {
+	return w.proto
+}
+
+// SetProtocol switches the protocol version of the replies
+func (w *Writer) SetProtocol(proto int) {
+	w.proto = proto
+}
+
+// SimpleString writes a status reply like OK
+func (w *Writer) SimpleString(s string) {
+	w.line('+', s)
+}
+
+// Error writes an error reply, the message should start
+// with the error code like "ERR unknown command"
+func (w *Writer) Error(msg string) {
+	w.line('-', strings.Replace(msg, "\n", " ", -1))
+}
+
+// Integer writes an integer reply
+func (w *Writer) Integer(n int64) {
+	w.line(':', strconv.FormatInt(n, 10))
+}
+
+// Bulk writes a bulk string reply
+func (w *Writer) Bulk(s string) {
+	w.line('$', strconv.Itoa(len(s)))
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// Null writes the null reply
+func (w *Writer) Null() {
+	if w.proto == 3 {
+		w.w.WriteString("_\r\n")
+		return
+	}
+
+	w.w.WriteString("$-1\r\n")
+}
+
+// Array writes the header of an array of n elements
+func (w *Writer) Array(n int) {
+	w.line('*', strconv.Itoa(n))
+}
+
+// Map writes the header of a map of n pairs, which is an
+// array of the keys followed by their values in RESP2
+func (w *Writer) Map(n int) {
+	if w.proto == 3 {
+		w.line('%', strconv.Itoa(n))
+		return
+	}
+
+	w.Array(2 * n)
+}
+
+// Push writes the header of an out of band message of
+// n elements, which is a plain array in RESP2
+func (w *Writer) Push(n int) {
+	if w.proto == 3 {
+		w.line('>', strconv.Itoa(n))
+		return
+	}
+
+	w.Array(n)
+}
+
+// Flush sends the buffered replies to the client
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// line writes the type prefixed line
+func (w *Writer) line(typ byte, s string) {
+	w.w.WriteByte(typ)
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
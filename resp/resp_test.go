@@ -0,0 +1,137 @@
+package resp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr error
+	}{
+		{
+			"ARRAY OF BULK STRINGS",
+			"*3\r\n$3\r\nSET\r\n$2\r\nk1\r\n$12\r\nHello\r\nWorld\r\n",
+			[][]string{{"SET", "k1", "Hello\r\nWorld"}},
+			io.EOF,
+		},
+		{
+			"PIPELINED COMMANDS",
+			"*1\r\n$4\r\nPING\r\n*2\r\n$3\r\nGET\r\n$2\r\nk1\r\n",
+			[][]string{{"PING"}, {"GET", "k1"}},
+			io.EOF,
+		},
+		{
+			"INLINE COMMANDS",
+			"\r\nGET  k1\r\nPING\n",
+			[][]string{{"GET", "k1"}, {"PING"}},
+			io.EOF,
+		},
+		{
+			"EMPTY BULK STRING",
+			"*2\r\n$4\r\nECHO\r\n$0\r\n\r\n",
+			[][]string{{"ECHO", ""}},
+			io.EOF,
+		},
+		{
+			"INVALID ARRAY LENGTH",
+			"*x\r\n",
+			nil,
+			ErrProtocol,
+		},
+		{
+			"MISSING BULK STRING",
+			"*1\r\n:1\r\n",
+			nil,
+			ErrProtocol,
+		},
+		{
+			"UNTERMINATED BULK STRING",
+			"*1\r\n$2\r\nGETX\r\n",
+			nil,
+			ErrProtocol,
+		},
+		{
+			"COMMANDS TOGETHER LARGER THAN THE MAXIMUM",
+			strings.Repeat("*1\r\n$4\r\nPING\r\n", 5),
+			[][]string{{"PING"}, {"PING"}, {"PING"}, {"PING"}, {"PING"}},
+			io.EOF,
+		},
+		{
+			"BULK STRING LARGER THAN THE MAXIMUM",
+			"*2\r\n$3\r\nSET\r\n$536870911\r\n",
+			nil,
+			ErrProtocol,
+		},
+		{
+			"INLINE COMMAND LARGER THAN THE MAXIMUM",
+			"GET " + strings.Repeat("k", 100) + "\r\n",
+			nil,
+			ErrProtocol,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tt.input))
+			r.SetMaxRequestSize(64)
+
+			var got [][]string
+			var err error
+			for {
+				var args []string
+				if args, err = r.ReadCommand(); err != nil {
+					break
+				}
+				got = append(got, args)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.ReadCommand() = %q, want %q", got, tt.want)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Reader.ReadCommand() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name  string
+		proto int
+		write func(w *Writer)
+		want  string
+	}{
+		{"SIMPLE STRING", 2, func(w *Writer) { w.SimpleString("OK") }, "+OK\r\n"},
+		{"ERROR", 2, func(w *Writer) { w.Error("ERR bad\ninput") }, "-ERR bad input\r\n"},
+		{"INTEGER", 2, func(w *Writer) { w.Integer(-12) }, ":-12\r\n"},
+		{"BULK STRING", 2, func(w *Writer) { w.Bulk("Hello") }, "$5\r\nHello\r\n"},
+		{"RESP2 NULL", 2, func(w *Writer) { w.Null() }, "$-1\r\n"},
+		{"RESP3 NULL", 3, func(w *Writer) { w.Null() }, "_\r\n"},
+		{"RESP2 MAP", 2, func(w *Writer) { w.Map(2) }, "*4\r\n"},
+		{"RESP3 MAP", 3, func(w *Writer) { w.Map(2) }, "%2\r\n"},
+		{"RESP2 PUSH", 2, func(w *Writer) { w.Push(3) }, "*3\r\n"},
+		{"RESP3 PUSH", 3, func(w *Writer) { w.Push(3) }, ">3\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.SetProtocol(tt.proto)
+
+			tt.write(w)
+			w.Flush()
+
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Writer wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
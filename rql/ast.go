@@ -1,6 +1,9 @@
 package rql
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // ================================ TYPES ================================
 
@@ -19,6 +22,14 @@ type Statement struct {
 	WipeStatement    *WipeStatement
 	RegUserStatement *RegUserStatement
 	PingStatement    *PingStatement
+	ConfigStatement  *ConfigStatement
+	RoleStatement    *RoleStatement
+	ACLStatement     *ACLStatement
+	UserStatement    *UserStatement
+	LockStatement    *LockStatement
+	ExpireStatement  *ExpireStatement
+	CounterStatement *CounterStatement
+	CasStatement     *CasStatement
 	Typ              AstType
 }
 
@@ -27,16 +38,29 @@ type SetStatement struct {
 	key string
 	val interface{}
 	exp uint
+	// cond is the optional IF clause, the
+	// value is only set if it holds
+	cond *expression
 }
 
 // GetStatement contains the structure for a "GET" command
 type GetStatement struct {
 	keys []string
+	// withVersion is set by WITH VERSION, the version
+	// of every key is returned after its value
+	withVersion bool
 }
 
 // DeleteStatement contains the structure for a "DEL" command
 type DeleteStatement struct {
 	keys []string
+	// cond is the optional IF clause, the
+	// keys are only deleted if it holds
+	cond *expression
+	// version is the version the key must have if
+	// checkVersion is set by the IF VERSION clause
+	version      uint64
+	checkVersion bool
 }
 
 // AuthStatement contains the structure for a "AUTH" command
@@ -54,6 +78,7 @@ type RegUserStatement struct {
 	username string
 	password string
 	access   uint
+	replace  bool
 }
 
 // PingStatement contains the structure for a "PING ON" command
@@ -62,17 +87,108 @@ type PingStatement struct {
 	operation string
 }
 
+// ConfigStatement contains the structure for a "CONFIG" command
+type ConfigStatement struct {
+	// action is one of "get", "set" and "rewrite"
+	action string
+	// name is the name of the setting, it is a glob pattern for "get"
+	name  string
+	value string
+}
+
+// RoleStatement contains the structure for the "CREATEROLE", "DROPROLE",
+// "GRANT", "REVOKE", "ASSIGN", "UNASSIGN" and "ROLES" commands
+type RoleStatement struct {
+	// action is the keyword of the command
+	action      string
+	role        string
+	permissions []string
+	username    string
+}
+
+// ACLStatement contains the structure for a "ACL" command
+type ACLStatement struct {
+	// action is one of "add", "del" and "list"
+	action      string
+	username    string
+	pattern     string
+	permissions []string
+}
+
+// UserStatement contains the structure for the "DELUSER", "PASSWD",
+// "SETACCESS", "LISTUSERS" and "WHOAMI" commands
+type UserStatement struct {
+	// action is the keyword of the command
+	action string
+	// username is empty when the client changes its own password
+	username    string
+	oldPassword string
+	password    string
+	access      uint
+}
+
+// LockStatement contains the structure for the "LOCKS" and "UNLOCK" commands
+type LockStatement struct {
+	// action is the keyword of the command
+	action string
+	// pattern matches the targets to unlock
+	pattern string
+}
+
+// ExpireStatement contains the structure for the "TTL", "PTTL",
+// "EXPIRE", "EXPIREAT" and "PERSIST" commands
+type ExpireStatement struct {
+	// action is the keyword of the command
+	action string
+	key    string
+	// expireIn is the duration after which the key expires for "EXPIRE"
+	expireIn time.Duration
+	// expireAt is the time when the key expires for "EXPIREAT"
+	expireAt time.Time
+}
+
+// CounterStatement contains the structure for the "INCR", "DECR",
+// "INCRBY" and "INCRBYFLOAT" commands
+type CounterStatement struct {
+	// action is the keyword of the command
+	action string
+	key    string
+	// by is the integer added to the counter, it is 1
+	// for "INCR" and -1 for "DECR"
+	by int64
+	// byFloat is the number added to the counter for "INCRBYFLOAT"
+	byFloat float64
+}
+
+// CasStatement contains the structure for a "CAS" command, the
+// value is only set if the key is at the version
+type CasStatement struct {
+	key     string
+	version uint64
+	val     interface{}
+	exp     uint
+}
+
 // AstType represents the type of abstract syntax tree
 type AstType uint
 
+// binaryExpression is either a comparison of the value of a
+// key, a, with a literal, b, or the AND / OR of two conditions
 type binaryExpression struct {
 	a  expression
 	b  expression
 	op token
 }
 
+// expression is a node of the condition of an IF clause
+//
+// The literal of an existsType expression is the key whose existence
+// is checked. An identifier literal is the name of a key on the left
+// of a comparison and a value on its right, like in a SET statement,
+// the typed value of the literal on the right is kept in value
 type expression struct {
 	literal *token
+	value   interface{}
 	binary  *binaryExpression
 	typ     expressionType
 }
@@ -84,6 +200,7 @@ type expressionType uint
 const (
 	literalType expressionType = iota
 	binaryType
+	existsType
 )
 
 // Supported AST type
@@ -95,6 +212,14 @@ const (
 	WipeType
 	RegUserType
 	PingType
+	ConfigType
+	RoleType
+	ACLType
+	UserType
+	LockType
+	ExpireType
+	CounterType
+	CasType
 )
 
 // ===========================================================================
@@ -123,6 +248,30 @@ func (a Ast) String() string {
 		if stmt.PingStatement != nil {
 			s += fmt.Sprintf("%+v", stmt.PingStatement)
 		}
+		if stmt.ConfigStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.ConfigStatement)
+		}
+		if stmt.RoleStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.RoleStatement)
+		}
+		if stmt.ACLStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.ACLStatement)
+		}
+		if stmt.UserStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.UserStatement)
+		}
+		if stmt.LockStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.LockStatement)
+		}
+		if stmt.ExpireStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.ExpireStatement)
+		}
+		if stmt.CounterStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.CounterStatement)
+		}
+		if stmt.CasStatement != nil {
+			s += fmt.Sprintf("%+v", stmt.CasStatement)
+		}
 	}
 
 	return s + " ]"
@@ -0,0 +1,336 @@
+package rql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrMalformed is returned by DecodeBinary if the
+// results weren't encoded by EncodeBinary
+var ErrMalformed = errors.New("Malformed binary results")
+
+// Kinds of the values in the binary encoding
+const (
+	kindNil byte = iota
+	kindString
+	kindInt
+	kindFloat
+	kindBool
+	kindJSON
+)
+
+// Flags of a result in the binary encoding
+const (
+	flagOK byte = 1 << iota
+	flagValues
+	flagPairs
+	flagError
+	flagFound
+)
+
+// EncodeBinary encodes the results of a query in a binary safe format,
+// the integers are big endian and the strings are prefixed by their
+// uint32 length
+//
+//	results := uint32 count, result...
+//	result  := statement, uint8 flags, message,
+//	           [uint32 count, value...], (flag 2)
+//	           [uint32 count, (name, uint32 count, string...)...], (flag 4)
+//	           [code, message] (flag 8)
+//	           [uint32 count, uint8 found...] (flag 16)
+//	value   := uint8 kind, payload
+//
+// The flag 1 is set if the statement succeeded. A value is either nil
+// (kind 0), a string (1), an int64 (2), a float64 (3), a bool (4) or a
+// JSON object or array (5) sent as a string holding its JSON text. The
+// values of the other types are sent as text
+func EncodeBinary(results []Result) []byte {
+	var b bytes.Buffer
+
+	putUint32(&b, len(results))
+	for _, r := range results {
+		var flags byte
+		if r.OK {
+			flags |= flagOK
+		}
+		if r.Values != nil {
+			flags |= flagValues
+		}
+		if r.Pairs != nil {
+			flags |= flagPairs
+		}
+		if r.Error != nil {
+			flags |= flagError
+		}
+		if r.Found != nil {
+			flags |= flagFound
+		}
+
+		putString(&b, r.Statement)
+		b.WriteByte(flags)
+		putString(&b, r.Message)
+
+		if r.Values != nil {
+			putUint32(&b, len(r.Values))
+			for _, v := range r.Values {
+				putValue(&b, v)
+			}
+		}
+
+		if r.Pairs != nil {
+			names := make([]string, 0, len(r.Pairs))
+			for name := range r.Pairs {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			putUint32(&b, len(names))
+			for _, name := range names {
+				putString(&b, name)
+				putUint32(&b, len(r.Pairs[name]))
+				for _, s := range r.Pairs[name] {
+					putString(&b, s)
+				}
+			}
+		}
+
+		if r.Error != nil {
+			putString(&b, r.Error.Code)
+			putString(&b, r.Error.Message)
+		}
+
+		if r.Found != nil {
+			putUint32(&b, len(r.Found))
+			for _, found := range r.Found {
+				if found {
+					b.WriteByte(1)
+				} else {
+					b.WriteByte(0)
+				}
+			}
+		}
+	}
+
+	return b.Bytes()
+}
+
+// DecodeBinary decodes the results encoded by EncodeBinary. The integers
+// are decoded as int64 and the floats as float64
+func DecodeBinary(b []byte) ([]Result, error) {
+	d := decoder{b: b}
+
+	n := d.uint32()
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var results []Result
+	for i := 0; i < n && d.err == nil; i++ {
+		r := Result{Statement: d.string()}
+		flags := d.byte()
+		r.OK = flags&flagOK != 0
+		r.Message = d.string()
+
+		if flags&flagValues != 0 {
+			r.Values = make([]interface{}, d.len())
+			for j := range r.Values {
+				r.Values[j] = d.value()
+			}
+		}
+
+		if flags&flagPairs != 0 {
+			r.Pairs = make(map[string][]string)
+			for j, n := 0, d.len(); j < n; j++ {
+				name := d.string()
+				values := make([]string, d.len())
+				for k := range values {
+					values[k] = d.string()
+				}
+				r.Pairs[name] = values
+			}
+		}
+
+		if flags&flagError != 0 {
+			r.Error = &Error{Code: d.string(), Message: d.string()}
+		}
+
+		if flags&flagFound != 0 {
+			r.Found = make([]bool, d.len())
+			for j := range r.Found {
+				r.Found[j] = d.byte() != 0
+			}
+		}
+
+		results = append(results, r)
+	}
+
+	if d.err == nil && len(d.b) != 0 {
+		d.err = ErrMalformed
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return results, nil
+}
+
+func putUint32(b *bytes.Buffer, n int) {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(n))
+	b.Write(buf[:])
+}
+
+func putUint64(b *bytes.Buffer, n uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+	b.Write(buf[:])
+}
+
+func putString(b *bytes.Buffer, s string) {
+	putUint32(b, len(s))
+	b.WriteString(s)
+}
+
+func putValue(b *bytes.Buffer, v interface{}) {
+	var i int64
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(kindNil)
+		return
+	case string:
+		b.WriteByte(kindString)
+		putString(b, v)
+		return
+	case []byte:
+		b.WriteByte(kindString)
+		putString(b, string(v))
+		return
+	case bool:
+		b.WriteByte(kindBool)
+		if v {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		return
+	case float64:
+		b.WriteByte(kindFloat)
+		putUint64(b, math.Float64bits(v))
+		return
+	case float32:
+		b.WriteByte(kindFloat)
+		putUint64(b, math.Float64bits(float64(v)))
+		return
+	case int:
+		i = int64(v)
+	case int8:
+		i = int64(v)
+	case int16:
+		i = int64(v)
+	case int32:
+		i = int64(v)
+	case int64:
+		i = v
+	case uint8:
+		i = int64(v)
+	case uint16:
+		i = int64(v)
+	case uint32:
+		i = int64(v)
+	case map[string]interface{}, []interface{}:
+		b.WriteByte(kindJSON)
+		putString(b, encodeJSON(v))
+		return
+	default:
+		b.WriteByte(kindString)
+		putString(b, stringify(v))
+		return
+	}
+
+	b.WriteByte(kindInt)
+	putUint64(b, uint64(i))
+}
+
+// decoder reads the binary encoding, the first
+// error is kept and every later read is a no-op
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(d.b) {
+		d.err = ErrMalformed
+		return nil
+	}
+
+	p := d.b[:n]
+	d.b = d.b[n:]
+	return p
+}
+
+func (d *decoder) byte() byte {
+	if p := d.next(1); p != nil {
+		return p[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() int {
+	if p := d.next(4); p != nil {
+		return int(binary.BigEndian.Uint32(p))
+	}
+	return 0
+}
+
+// len reads a count, which cannot exceed the remaining bytes
+// as every element is at least a byte long
+func (d *decoder) len() int {
+	n := d.uint32()
+	if n > len(d.b) {
+		d.err = ErrMalformed
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) uint64() uint64 {
+	if p := d.next(8); p != nil {
+		return binary.BigEndian.Uint64(p)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.next(d.uint32()))
+}
+
+func (d *decoder) value() interface{} {
+	switch kind := d.byte(); kind {
+	case kindNil:
+		return nil
+	case kindString:
+		return d.string()
+	case kindInt:
+		return int64(d.uint64())
+	case kindFloat:
+		return math.Float64frombits(d.uint64())
+	case kindBool:
+		return d.byte() != 0
+	case kindJSON:
+		v, err := decodeJSON(d.string())
+		if err != nil && d.err == nil {
+			d.err = ErrMalformed
+		}
+		return v
+	default:
+		d.err = ErrMalformed
+		return nil
+	}
+}
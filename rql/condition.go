@@ -0,0 +1,90 @@
+package rql
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// conditionNotMetMsg is the message of the statements which
+// weren't applied because the condition of their IF clause
+// didn't hold
+const conditionNotMetMsg = "Condition not met"
+
+// holds evaluates the condition of an IF clause, get returns the
+// current value of a key and false if the key doesn't exist
+//
+// A comparison with a key which doesn't exist only holds for "!=".
+// The values which are both numbers are compared as numbers, the
+// other values are compared as their text, so that the JSON documents
+// are equal if they hold the same keys and values
+func (e *expression) holds(get func(key string) (interface{}, bool)) bool {
+	switch e.typ {
+	case existsType:
+		_, ok := get(e.literal.val)
+		return ok
+	case binaryType:
+		a, b, op := &e.binary.a, &e.binary.b, e.binary.op.val
+
+		switch op {
+		case string(andKeyword):
+			return a.holds(get) && b.holds(get)
+		case string(orKeyword):
+			return a.holds(get) || b.holds(get)
+		}
+
+		val, ok := get(a.literal.val)
+		if !ok {
+			return op == string(neqSymbol)
+		}
+
+		return compareWith(op, compareValues(stringify(val), stringify(b.value)))
+	}
+
+	return false
+}
+
+// compareValues returns -1, 0 or 1 if a is less than,
+// equal to or greater than b respectively
+func compareValues(a, b string) int {
+	x, xok := parseNumber(a)
+	y, yok := parseNumber(b)
+	if !xok || !yok {
+		return strings.Compare(a, b)
+	}
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// compareWith returns true if the result of compareValues
+// satisfies the comparison operator
+func compareWith(op string, cmp int) bool {
+	switch op {
+	case string(eqSymbol):
+		return cmp == 0
+	case string(neqSymbol):
+		return cmp != 0
+	case string(ltSymbol):
+		return cmp < 0
+	case string(lteSymbol):
+		return cmp <= 0
+	case string(gtSymbol):
+		return cmp > 0
+	case string(gteSymbol):
+		return cmp >= 0
+	}
+
+	return false
+}
+
+// parseNumber returns the number represented by the string
+func parseNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil && !math.IsNaN(f)
+}
@@ -0,0 +1,71 @@
+package rql
+
+import "testing"
+
+func TestExpression_holds(t *testing.T) {
+	data := map[string]interface{}{
+		"k1":   "4",
+		"k2":   "hello",
+		"k3":   int64(10),
+		"k4":   "9",
+		"k5":   2.5,
+		"user": "utkarsh",
+	}
+	get := func(key string) (interface{}, bool) {
+		v, ok := data[key]
+		return v, ok
+	}
+
+	tests := []struct {
+		name    string
+		cond    string
+		want    bool
+		wantErr bool
+	}{
+		{"EQUAL NUMBER", `k1 == 4`, true, false},
+		{"EQUAL NUMBER WITH DIFFERENT FORMAT", `k1 == 4.0`, true, false},
+		{"NOT EQUAL NUMBER", `k1 != 4`, false, false},
+		{"EQUAL STRING", `k2 == "hello"`, true, false},
+		{"EQUAL IDENTIFIER", `k2 == hello`, true, false},
+		{"NUMBERS ARE COMPARED AS NUMBERS", `k4 < 10`, true, false},
+		{"TYPED NUMBERS ARE COMPARED AS NUMBERS", `k3 > 9`, true, false},
+		{"IDENTIFIER ON THE RIGHT IS A VALUE", `k2 == k2`, false, false},
+		{"FLOAT", `k5 >= 2.5`, true, false},
+		{"STRINGS ARE COMPARED AS STRINGS", `k2 < "world"`, true, false},
+		{"QUOTED NUMBERS ARE COMPARED AS NUMBERS", `k4 < "10"`, true, false},
+		{"NUMBER AND STRING ARE COMPARED AS STRINGS", `k2 > 10`, true, false},
+		{"MISSING KEY", `missing == 4`, false, false},
+		{"MISSING KEY IS NOT EQUAL", `missing != 4`, true, false},
+		{"EXISTS", `EXISTS(k1)`, true, false},
+		{"EXISTS MISSING KEY", `exists(missing)`, false, false},
+		{"AND", `k1 == 4 AND EXISTS(k2)`, true, false},
+		{"AND WITH FALSE OPERAND", `k1 == 4 AND EXISTS(missing)`, false, false},
+		{"OR", `k1 == 5 OR user == utkarsh`, true, false},
+		{"AND BINDS TIGHTER THAN OR", `k1 == 4 OR k1 == 5 AND k1 == 6`, true, false},
+		{"PARENTHESES", `(k1 == 4 OR k1 == 5) AND k1 == 6`, false, false},
+		{"NESTED PARENTHESES", `((k1 == 4) AND (k2 == hello OR (k3 < 5)))`, true, false},
+		{"MISSING CLOSING PARENTHESIS", `(k1 == 4`, false, true},
+		{"MISSING OPERATOR", `k1 4`, false, true},
+		{"MISSING VALUE", `k1 ==`, false, true},
+		{"VALUE ON THE LEFT", `4 == k1`, false, true},
+		{"MISSING OPERAND", `k1 == 4 AND`, false, true},
+		{"EXISTS WITHOUT PARENTHESES", `EXISTS k1`, false, true},
+		{"EMPTY CONDITION", ``, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := Parse("SET k v IF " + tt.cond + ";")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if got := ast.Statements[0].SetStatement.cond.holds(get); got != tt.want {
+				t.Errorf("expression.holds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
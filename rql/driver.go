@@ -2,6 +2,8 @@ package rql
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -9,12 +11,42 @@ import (
 // driver expects.
 type SecureDB interface {
 	Set(key string, data interface{}, expireIn time.Duration) error
+	SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) (bool, error)
 	Get(key string) (interface{}, bool, error)
+	GetVersion(key string) (interface{}, uint64, bool, error)
+	SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool, error)
 	Delete(key string) (interface{}, bool, error)
+	DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool, error)
+	DeleteIfVersion(key string, version uint64) (interface{}, bool, error)
+	ExpireAt(key string) (time.Time, bool, error)
+	SetExpireAt(key string, expireAt time.Time) (bool, error)
+	Incr(key string, delta int64) (int64, error)
+	IncrFloat(key string, delta float64) (float64, error)
 	Wipe() error
 	Authenticate(username string, password string) error
-	RegisterUser(username string, password string, access uint) error
+	RegisterUser(username string, password string, access uint, replace bool) error
+	DeleteUser(username string) error
+	ChangePassword(oldPassword, newPassword string) error
+	ResetPassword(username, password string) error
+	SetAccess(username string, access uint) error
+	Users() (map[string][]string, error)
+	WhoAmI() (string, []string)
+	Locks() (map[string]time.Duration, error)
+	Unlock(pattern string) (int, error)
 	Ping(event string, on bool) error
+	ConfigGet(pattern string) (map[string]string, error)
+	ConfigSet(name, value string) error
+	ConfigRewrite() error
+	CreateRole(name string, permissions []string) error
+	DropRole(name string) error
+	GrantRole(name string, permissions []string) error
+	RevokeRole(name string, permissions []string) error
+	AssignRole(name, username string) error
+	UnassignRole(name, username string) error
+	Roles() (map[string][]string, error)
+	AddRule(username, pattern string, permissions []string) error
+	DeleteRule(username, pattern string) error
+	Rules(username string) (map[string][]string, error)
 }
 
 // Driver is the RQL driver which acts as an interface between a database client and
@@ -38,76 +70,125 @@ func New(db SecureDB) *Driver {
 // This method returns the response generated by query/queries and if any
 // error occured while processing the query/queries
 func (d *Driver) Operate(src string) (string, error) {
+	var result string
+
+	for _, res := range d.Execute(src) {
+		if res.err != nil {
+			return result, res.err
+		}
+		result = prepareResponse(result, res.Text())
+	}
+
+	return result, nil
+}
+
+// OperateJSON is like Operate except that it returns the
+// typed results of the statements encoded by EncodeJSON
+func (d *Driver) OperateJSON(src string) string {
+	return EncodeJSON(d.Execute(src))
+}
+
+// OperateBinary is like OperateJSON except that the placeholders
+// of the query are replaced by the args and the results are
+// encoded by EncodeBinary, which keeps the values binary safe
+func (d *Driver) OperateBinary(src string, args []string) []byte {
+	return EncodeBinary(d.ExecuteArgs(src, args))
+}
+
+// Execute executes the statements of the query one after the other and
+// returns their results. The execution stops at the first statement
+// which fails, its result is the last one. An invalid query returns
+// a single failed result with the CodeParse code
+func (d *Driver) Execute(src string) []Result {
+	return d.ExecuteArgs(src, nil)
+}
+
+// ExecuteArgs is like Execute except that the placeholders
+// of the query are replaced by the args, see ParseArgs
+func (d *Driver) ExecuteArgs(src string, args []string) []Result {
 	// Parse the src
-	ast, err := Parse(src)
+	ast, err := ParseArgs(src, args)
 	if err != nil {
-		return "", err
+		return []Result{failed("", CodeParse, err)}
 	}
 	if ast == nil {
-		return "", nil
+		return nil
 	}
 
-	var result string
+	var results []Result
 
 	for _, stmt := range ast.Statements {
+		var res Result
+
 		switch stmt.Typ {
 		case SetType:
-			res, err := d.set(stmt.SetStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.set(stmt.SetStatement)
 		case GetType:
-			res, err := d.get(stmt.GetStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.get(stmt.GetStatement)
 		case DeleteType:
-			res, err := d.delete(stmt.DeleteStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.delete(stmt.DeleteStatement)
 		case WipeType:
-			res, err := d.wipe(stmt.WipeStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.wipe(stmt.WipeStatement)
 		case AuthType:
-			res, err := d.auth(stmt.AuthStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.auth(stmt.AuthStatement)
 		case RegUserType:
-			res, err := d.reguser(stmt.RegUserStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.reguser(stmt.RegUserStatement)
 		case PingType:
-			res, err := d.ping(stmt.PingStatement)
-			if err != nil {
-				return result, err
-			}
-			result = prepareResponse(result, res)
+			res, err = d.ping(stmt.PingStatement)
+		case ConfigType:
+			res, err = d.config(stmt.ConfigStatement)
+		case RoleType:
+			res, err = d.role(stmt.RoleStatement)
+		case ACLType:
+			res, err = d.acl(stmt.ACLStatement)
+		case UserType:
+			res, err = d.user(stmt.UserStatement)
+		case LockType:
+			res, err = d.lock(stmt.LockStatement)
+		case ExpireType:
+			res, err = d.expire(stmt.ExpireStatement)
+		case CounterType:
+			res, err = d.counter(stmt.CounterStatement)
+		case CasType:
+			res, err = d.cas(stmt.CasStatement)
+		default:
+			continue
 		}
+
+		if err != nil {
+			return append(results, failed(statementName(stmt), "", err))
+		}
+
+		res.Statement, res.OK = statementName(stmt), true
+		results = append(results, res)
 	}
 
-	return result, nil
+	return results
 }
 
 // set method calls the set method on the database by providing
 // appropriate parameters
-func (d *Driver) set(stmt *SetStatement) (string, error) {
+//
+// The value of a statement with an IF clause is set only if the
+// condition holds, which the database checks atomically
+func (d *Driver) set(stmt *SetStatement) (Result, error) {
+	if stmt.cond != nil {
+		ok, err := d.db.SetIf(stmt.key, stmt.val, convertToDuration(stmt.exp), stmt.cond.holds)
+		if err != nil {
+			return Result{}, err
+		}
+		if !ok {
+			return Result{Message: conditionNotMetMsg}, nil
+		}
+		return Result{Message: "Success"}, nil
+	}
+
 	err := d.db.Set(stmt.key, stmt.val, convertToDuration(stmt.exp))
 
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
-	return "Success", nil
+	return Result{Message: "Success"}, nil
 }
 
 // get method calls the get method on the database by providing
@@ -115,19 +196,32 @@ func (d *Driver) set(stmt *SetStatement) (string, error) {
 // it ignores the "keys" which do not exists in the database and places
 // nil in the slice for them
 //
-// It returns the stringified slice
-func (d *Driver) get(stmt *GetStatement) (string, error) {
-	var res []interface{}
+// With WITH VERSION the version of every key follows its value, it
+// is 0 for the keys which do not exist. Found tells which keys exist
+func (d *Driver) get(stmt *GetStatement) (Result, error) {
+	res := make([]interface{}, 0, len(stmt.keys))
+	found := make([]bool, 0, len(stmt.keys))
 
 	for _, key := range stmt.keys {
-		val, _, err := d.db.Get(key)
+		if stmt.withVersion {
+			val, version, ok, err := d.db.GetVersion(key)
+			if err != nil {
+				return Result{}, err
+			}
+			res = append(res, val, int64(version))
+			found = append(found, ok)
+			continue
+		}
+
+		val, ok, err := d.db.Get(key)
 		if err != nil {
-			return "", err
+			return Result{}, err
 		}
 		res = append(res, val)
+		found = append(found, ok)
 	}
 
-	return stringify(res), nil
+	return Result{Values: res, Found: found}, nil
 }
 
 // delete method calls the delete method on the database by providing
@@ -135,63 +229,358 @@ func (d *Driver) get(stmt *GetStatement) (string, error) {
 // it ignores the "keys" which do not exists in the database and places
 // nil in the slice for them
 //
-// It returns the stringified slice
-func (d *Driver) delete(stmt *DeleteStatement) (string, error) {
-	var res []interface{}
+// The keys of a statement with an IF clause are deleted only if the
+// condition holds, which the database checks atomically. The same goes
+// for the key of an IF VERSION clause and its version. Found tells which
+// keys existed for the statements without a condition
+func (d *Driver) delete(stmt *DeleteStatement) (Result, error) {
+	if stmt.checkVersion {
+		val, ok, err := d.db.DeleteIfVersion(stmt.keys[0], stmt.version)
+		if err != nil {
+			return Result{}, err
+		}
+		if !ok {
+			return Result{Message: conditionNotMetMsg}, nil
+		}
+		return Result{Values: []interface{}{val}}, nil
+	}
+
+	if stmt.cond != nil {
+		res, ok, err := d.db.DeleteIf(stmt.keys, stmt.cond.holds)
+		if err != nil {
+			return Result{}, err
+		}
+		if !ok {
+			return Result{Message: conditionNotMetMsg}, nil
+		}
+		return Result{Values: res}, nil
+	}
+
+	res := make([]interface{}, 0, len(stmt.keys))
+	found := make([]bool, 0, len(stmt.keys))
 
 	for _, key := range stmt.keys {
-		val, _, err := d.db.Delete(key)
+		val, ok, err := d.db.Delete(key)
 		if err != nil {
-			return "", err
+			return Result{}, err
 		}
 		res = append(res, val)
+		found = append(found, ok)
 	}
 
-	return stringify(res), nil
+	return Result{Values: res, Found: found}, nil
 }
 
 // wipe method call the wipe method on the secure database
 // if any error occurs in the process then that error is passed
 // on to the client
-func (d *Driver) wipe(stmt *WipeStatement) (string, error) {
+func (d *Driver) wipe(stmt *WipeStatement) (Result, error) {
 	if err := d.db.Wipe(); err != nil {
-		return "", err
+		return Result{}, err
 	}
 
-	return "Success", nil
+	return Result{Message: "Success"}, nil
 }
 
 // auth takes in the authStatement and executes Authenticate method on the database
-func (d *Driver) auth(stmt *AuthStatement) (string, error) {
+func (d *Driver) auth(stmt *AuthStatement) (Result, error) {
 	if err := d.db.Authenticate(stmt.username, stmt.password); err != nil {
-		return "", err
+		return Result{}, err
 	}
 
-	return "Successfully Authenticated", nil
+	return Result{Message: "Successfully Authenticated"}, nil
 }
 
 // reguser takes username, password and access level for the user and creates a newuser
 // by invoking the RegisterUser method on the SecureDB
-func (d *Driver) reguser(stmt *RegUserStatement) (string, error) {
-	if err := d.db.RegisterUser(stmt.username, stmt.password, stmt.access); err != nil {
-		return "", err
+func (d *Driver) reguser(stmt *RegUserStatement) (Result, error) {
+	if err := d.db.RegisterUser(stmt.username, stmt.password, stmt.access, stmt.replace); err != nil {
+		return Result{}, err
 	}
 
-	return "Created user " + stmt.username, nil
+	return Result{Message: "Created user " + stmt.username}, nil
+}
+
+// user manages the users and the password of the client depending upon
+// the action
+//
+// Users are returned as username=permission,permission pairs
+func (d *Driver) user(stmt *UserStatement) (Result, error) {
+	var err error
+
+	switch stmt.action {
+	case string(listusersKeyword):
+		users, err := d.db.Users()
+		if err != nil {
+			return Result{}, err
+		}
+
+		return Result{Pairs: pairs(users)}, nil
+	case string(whoamiKeyword):
+		username, perms := d.db.WhoAmI()
+		if username == "" {
+			username = "anonymous"
+		}
+
+		return Result{Pairs: map[string][]string{username: perms}}, nil
+	case string(deluserKeyword):
+		err = d.db.DeleteUser(stmt.username)
+	case string(passwdKeyword):
+		if stmt.username != "" {
+			err = d.db.ResetPassword(stmt.username, stmt.password)
+		} else {
+			err = d.db.ChangePassword(stmt.oldPassword, stmt.password)
+		}
+	case string(setaccessKeyword):
+		err = d.db.SetAccess(stmt.username, stmt.access)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Message: "Success"}, nil
 }
 
 // ping takes in the operation to subscribe and subscribe to the operation
 // if is the user has access to such operation
-func (d *Driver) ping(stmt *PingStatement) (string, error) {
+func (d *Driver) ping(stmt *PingStatement) (Result, error) {
 	if err := d.db.Ping(stmt.operation, stmt.on); err != nil {
-		return "", err
+		return Result{}, err
 	}
 	if stmt.on {
-		return "Subscribed to " + stmt.operation, nil
+		return Result{Message: "Subscribed to " + stmt.operation}, nil
 	} else {
-		return "Unsubscribed from " + stmt.operation, nil
+		return Result{Message: "Unsubscribed from " + stmt.operation}, nil
+	}
+
+}
+
+// config reads or changes the settings of the database or writes
+// them back to the configuration file depending upon the action
+//
+// Settings are returned as name=value pairs
+func (d *Driver) config(stmt *ConfigStatement) (Result, error) {
+	switch stmt.action {
+	case string(getKeyword):
+		settings, err := d.db.ConfigGet(stmt.name)
+		if err != nil {
+			return Result{}, err
+		}
+
+		res := make(map[string][]string, len(settings))
+		for name, value := range settings {
+			res[name] = []string{value}
+		}
+
+		return Result{Pairs: res}, nil
+	case string(setKeyword):
+		if err := d.db.ConfigSet(stmt.name, stmt.value); err != nil {
+			return Result{}, err
+		}
+	case string(rewriteKeyword):
+		if err := d.db.ConfigRewrite(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	return Result{Message: "Success"}, nil
+}
+
+// role manages the roles and their assignments depending upon the action
+//
+// Roles are returned as name=permission,permission pairs
+func (d *Driver) role(stmt *RoleStatement) (Result, error) {
+	var err error
+
+	switch stmt.action {
+	case string(rolesKeyword):
+		roles, err := d.db.Roles()
+		if err != nil {
+			return Result{}, err
+		}
+
+		return Result{Pairs: pairs(roles)}, nil
+	case string(createroleKeyword):
+		err = d.db.CreateRole(stmt.role, stmt.permissions)
+	case string(droproleKeyword):
+		err = d.db.DropRole(stmt.role)
+	case string(grantKeyword):
+		err = d.db.GrantRole(stmt.role, stmt.permissions)
+	case string(revokeKeyword):
+		err = d.db.RevokeRole(stmt.role, stmt.permissions)
+	case string(assignKeyword):
+		err = d.db.AssignRole(stmt.role, stmt.username)
+	case string(unassignKeyword):
+		err = d.db.UnassignRole(stmt.role, stmt.username)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Message: "Success"}, nil
+}
+
+// acl manages the rules scoping the access of the users to the keys
+//
+// Rules are returned as pattern=permission,permission pairs
+func (d *Driver) acl(stmt *ACLStatement) (Result, error) {
+	switch stmt.action {
+	case "list":
+		rules, err := d.db.Rules(stmt.username)
+		if err != nil {
+			return Result{}, err
+		}
+
+		return Result{Pairs: pairs(rules)}, nil
+	case "add":
+		if err := d.db.AddRule(stmt.username, stmt.pattern, stmt.permissions); err != nil {
+			return Result{}, err
+		}
+	case string(delKeyword):
+		if err := d.db.DeleteRule(stmt.username, stmt.pattern); err != nil {
+			return Result{}, err
+		}
 	}
 
+	return Result{Message: "Success"}, nil
+}
+
+// lock lists or lifts the lockouts of the users and the addresses
+// which failed to authenticate too many times
+//
+// Lockouts are returned as target=remaining pairs
+func (d *Driver) lock(stmt *LockStatement) (Result, error) {
+	if stmt.action == string(unlockKeyword) {
+		n, err := d.db.Unlock(stmt.pattern)
+		if err != nil {
+			return Result{}, err
+		}
+
+		return Result{Message: fmt.Sprintf("Unlocked %d", n)}, nil
+	}
+
+	locks, err := d.db.Locks()
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := make(map[string][]string, len(locks))
+	for target, remaining := range locks {
+		res[target] = []string{remaining.Round(time.Second).String()}
+	}
+
+	return Result{Pairs: res}, nil
+}
+
+// expire reads or changes the expiry of the key depending upon the action
+//
+// TTL and PTTL return the remaining time to live in seconds and in
+// milliseconds respectively, -1 if the key never expires and -2 if it
+// doesn't exist. The other actions return true if the key exists
+func (d *Driver) expire(stmt *ExpireStatement) (Result, error) {
+	var ok bool
+	var err error
+
+	switch stmt.action {
+	case string(ttlKeyword), string(pttlKeyword):
+		expireAt, ok, err := d.db.ExpireAt(stmt.key)
+		if err != nil {
+			return Result{}, err
+		}
+
+		unit := time.Second
+		if stmt.action == string(pttlKeyword) {
+			unit = time.Millisecond
+		}
+
+		return Result{Values: []interface{}{timeToLive(expireAt, ok, unit)}}, nil
+	case string(expireKeyword):
+		ok, err = d.db.SetExpireAt(stmt.key, time.Now().Add(stmt.expireIn))
+	case string(expireatKeyword):
+		ok, err = d.db.SetExpireAt(stmt.key, stmt.expireAt)
+	case string(persistKeyword):
+		ok, err = d.db.SetExpireAt(stmt.key, time.Time{})
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Values: []interface{}{ok}}, nil
+}
+
+// counter method adds to the counter of the statement and returns
+// its new value, an int64 or a float64 for "INCRBYFLOAT"
+func (d *Driver) counter(stmt *CounterStatement) (Result, error) {
+	if stmt.action == string(incrbyfloatKeyword) {
+		f, err := d.db.IncrFloat(stmt.key, stmt.byFloat)
+		if err != nil {
+			return Result{}, err
+		}
+
+		return Result{Values: []interface{}{f}}, nil
+	}
+
+	n, err := d.db.Incr(stmt.key, stmt.by)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Values: []interface{}{n}}, nil
+}
+
+// cas method sets the value only if the key is at the version of the
+// statement, it returns the new version of the key
+func (d *Driver) cas(stmt *CasStatement) (Result, error) {
+	version, ok, err := d.db.SetIfVersion(stmt.key, stmt.version, stmt.val, convertToDuration(stmt.exp))
+	if err != nil {
+		return Result{}, err
+	}
+	if !ok {
+		return Result{Message: conditionNotMetMsg}, nil
+	}
+
+	return Result{Values: []interface{}{int64(version)}}, nil
+}
+
+// statementName returns the keywords of the statement like "ACL ADD"
+func statementName(stmt *Statement) string {
+	switch stmt.Typ {
+	case SetType:
+		return "SET"
+	case GetType:
+		return "GET"
+	case DeleteType:
+		return "DEL"
+	case WipeType:
+		return "WIPE"
+	case AuthType:
+		return "AUTH"
+	case RegUserType:
+		return "REGUSER"
+	case PingType:
+		return "PING"
+	case ConfigType:
+		return "CONFIG " + strings.ToUpper(stmt.ConfigStatement.action)
+	case RoleType:
+		return strings.ToUpper(stmt.RoleStatement.action)
+	case ACLType:
+		return "ACL " + strings.ToUpper(stmt.ACLStatement.action)
+	case UserType:
+		return strings.ToUpper(stmt.UserStatement.action)
+	case LockType:
+		return strings.ToUpper(stmt.LockStatement.action)
+	case ExpireType:
+		return strings.ToUpper(stmt.ExpireStatement.action)
+	case CounterType:
+		return strings.ToUpper(stmt.CounterStatement.action)
+	case CasType:
+		return "CAS"
+	default:
+		return ""
+	}
 }
 
 // ============================ HELPER FUNCTIONS ===================================
@@ -204,13 +593,55 @@ func convertToDuration(t uint) time.Duration {
 	return time.Duration(time.Duration(t) * time.Millisecond)
 }
 
+// timeToLive returns the time left until the expiry rounded to the unit,
+// -1 if the key never expires and -2 if the key doesn't exist
+func timeToLive(expireAt time.Time, exists bool, unit time.Duration) int64 {
+	if !exists {
+		return -2
+	}
+	if expireAt.IsZero() {
+		return -1
+	}
+
+	return int64((time.Until(expireAt) + unit/2) / unit)
+}
+
 // stringify function can be used to stringify any data type
 // It internally uses fmt.Sprintf("%v", ...) to perform the conversion
 // which internally uses the String() method on the objects to perform the conversion
+//
+// The JSON documents are stringified as compact JSON
 func stringify(any interface{}) string {
+	switch any.(type) {
+	case map[string]interface{}, []interface{}:
+		return encodeJSON(any)
+	}
+
 	return fmt.Sprintf("%v", any)
 }
 
+// pairs returns the map, or an empty map if it is nil so
+// that the result is known to be made of pairs
+func pairs(m map[string][]string) map[string][]string {
+	if m == nil {
+		return map[string][]string{}
+	}
+
+	return m
+}
+
+// joinPairs joins the names with their comma separated
+// values and returns the pairs sorted by the names
+func joinPairs(m map[string][]string) []string {
+	res := make([]string, 0, len(m))
+	for name, values := range m {
+		res = append(res, name+"="+strings.Join(values, ","))
+	}
+	sort.Strings(res)
+
+	return res
+}
+
 // prepareResponse just concatanates the passed string and separates
 // them with a newline character
 func prepareResponse(str1, str2 string) string {
@@ -1,7 +1,9 @@
 package rql
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -52,20 +54,62 @@ const (
 	pingKeyword    keyword = "ping"
 	onKeyword      keyword = "on"
 	offKeyword     keyword = "off"
-	// Data types
-	// numberKeyword keyword = "number"
-	// stringKeyword keyword = "string"
-	// boolKeyword   keyword = "bool"
-	// jsonKeyword   keyword = "json"
-	// anyKeyword    keyword = "any"
 
 	// Conditionals
-	ifKeyword  keyword = "if"
-	andKeyword keyword = "and"
-	orKeyword  keyword = "or"
+	ifKeyword     keyword = "if"
+	andKeyword    keyword = "and"
+	orKeyword     keyword = "or"
+	existsKeyword keyword = "exists"
 
 	// Meta
 	expireinKeyword keyword = "expirein"
+
+	// Expiry
+	ttlKeyword      keyword = "ttl"
+	pttlKeyword     keyword = "pttl"
+	expireKeyword   keyword = "expire"
+	expireatKeyword keyword = "expireat"
+	persistKeyword  keyword = "persist"
+	// Counters
+	incrKeyword        keyword = "incr"
+	decrKeyword        keyword = "decr"
+	incrbyKeyword      keyword = "incrby"
+	incrbyfloatKeyword keyword = "incrbyfloat"
+	// Versions
+	casKeyword     keyword = "cas"
+	withKeyword    keyword = "with"
+	versionKeyword keyword = "version"
+
+	// Administration
+	configKeyword  keyword = "config"
+	rewriteKeyword keyword = "rewrite"
+
+	// Access control lists
+	aclKeyword keyword = "acl"
+
+	// Users
+	deluserKeyword   keyword = "deluser"
+	passwdKeyword    keyword = "passwd"
+	setaccessKeyword keyword = "setaccess"
+	listusersKeyword keyword = "listusers"
+	whoamiKeyword    keyword = "whoami"
+	replaceKeyword   keyword = "replace"
+
+	// Lockouts
+	locksKeyword   keyword = "locks"
+	unlockKeyword  keyword = "unlock"
+	lockoutKeyword keyword = "lockout"
+
+	// Roles
+	createroleKeyword keyword = "createrole"
+	droproleKeyword   keyword = "droprole"
+	grantKeyword      keyword = "grant"
+	revokeKeyword     keyword = "revoke"
+	assignKeyword     keyword = "assign"
+	unassignKeyword   keyword = "unassign"
+	rolesKeyword      keyword = "roles"
+	toKeyword         keyword = "to"
+	fromKeyword       keyword = "from"
 )
 
 // RQL Symbol
@@ -81,6 +125,10 @@ const (
 	lteSymbol        symbol = "<="
 	gtSymbol         symbol = ">"
 	gteSymbol        symbol = ">="
+
+	// placeholderSymbol stands for an argument passed along with
+	// the query, see ParseArgs
+	placeholderSymbol symbol = "?"
 )
 
 // RQL Token type
@@ -91,6 +139,15 @@ const (
 	stringType
 	numericType
 	boolType
+	nullType
+	jsonType
+)
+
+// RQL literals which aren't lexed as identifiers
+const (
+	trueLiteral  = "true"
+	falseLiteral = "false"
+	nullLiteral  = "null"
 )
 
 // ============================================================
@@ -111,7 +168,7 @@ func lex(src string) ([]*token, error) {
 
 lex:
 	for cur.ptr < uint(len(src)) {
-		lexers := []lexer{lexKeyword, lexSymbol, lexString, lexNumeric, lexIdentifier}
+		lexers := []lexer{lexKeyword, lexLiteral, lexSymbol, lexString, lexJSON, lexNumeric, lexIdentifier}
 		for _, l := range lexers {
 			if token, newCursor, ok := l(src, cur); ok {
 				cur = newCursor
@@ -144,35 +201,98 @@ lex:
 	return tokens, nil
 }
 
+// keywords are the keywords recognized by the lexer
+var keywords = []keyword{
+	// Commands
+	authKeyword,
+	getKeyword,
+	setKeyword,
+	delKeyword,
+	wipeKeyword,
+	reguserKeyword,
+	pingKeyword,
+	onKeyword,
+	offKeyword,
+
+	// Conditionals
+	ifKeyword,
+	andKeyword,
+	orKeyword,
+	existsKeyword,
+
+	// Meta
+	expireinKeyword,
+
+	// Counters
+	incrKeyword,
+	decrKeyword,
+	incrbyKeyword,
+	incrbyfloatKeyword,
+
+	// Users
+	deluserKeyword,
+	passwdKeyword,
+	setaccessKeyword,
+	listusersKeyword,
+	whoamiKeyword,
+	replaceKeyword,
+}
+
+// words are the keywords which are lexed as identifiers, so that they
+// can still be used as keys. The parsers match them by value where a
+// statement or a clause is expected, like ADD and LIST after ACL
+var words = []keyword{
+	// Expiry
+	ttlKeyword,
+	pttlKeyword,
+	expireKeyword,
+	expireatKeyword,
+	persistKeyword,
+
+	// Versions
+	casKeyword,
+	withKeyword,
+	versionKeyword,
+
+	// Administration
+	configKeyword,
+	rewriteKeyword,
+
+	// Access control lists
+	aclKeyword,
+
+	// Lockouts
+	locksKeyword,
+	unlockKeyword,
+	lockoutKeyword,
+
+	// Roles
+	createroleKeyword,
+	droproleKeyword,
+	grantKeyword,
+	revokeKeyword,
+	assignKeyword,
+	unassignKeyword,
+	rolesKeyword,
+	toKeyword,
+	fromKeyword,
+}
+
+// Keywords returns the keywords of RQL in upper case,
+// they are meant for the completion of the queries
+func Keywords() []string {
+	res := make([]string, 0, len(keywords)+len(words))
+	for _, k := range append(keywords, words...) {
+		res = append(res, strings.ToUpper(string(k)))
+	}
+
+	sort.Strings(res)
+	return res
+}
+
 // lexKeyword analysis the source code for keywords
 func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 	cur := ic
-	keywords := []keyword{
-		// Commands
-		authKeyword,
-		getKeyword,
-		setKeyword,
-		delKeyword,
-		wipeKeyword,
-		reguserKeyword,
-		pingKeyword,
-		onKeyword,
-		offKeyword,
-		// Data types
-		// numberKeyword,
-		// stringKeyword,
-		// boolKeyword,
-		// jsonKeyword,
-		// anyKeyword,
-
-		// Conditionals
-		ifKeyword,
-		andKeyword,
-		orKeyword,
-
-		// Meta
-		expireinKeyword,
-	}
 
 	var options []string
 	for _, k := range keywords {
@@ -184,6 +304,12 @@ func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 		return nil, ic, false
 	}
 
+	// A keyword must not be the prefix of an identifier
+	// like "set" in "settings"
+	if end := ic.ptr + uint(len(match)); end < uint(len(source)) && isIdentifierChar(source[end]) {
+		return nil, ic, false
+	}
+
 	cur.ptr = ic.ptr + uint(len(match))
 	cur.loc.col = ic.loc.col + uint(len(match))
 
@@ -196,6 +322,37 @@ func lexKeyword(source string, ic cursor) (*token, cursor, bool) {
 	}, cur, true
 }
 
+// lexLiteral analysis the source code for the true,
+// false and null literals, which are case insensitive
+func lexLiteral(source string, ic cursor) (*token, cursor, bool) {
+	cur := ic
+
+	match := longestMatch(source, ic, []string{trueLiteral, falseLiteral, nullLiteral})
+	if match == "" {
+		return nil, ic, false
+	}
+
+	// A literal must not be the prefix of an identifier
+	// like "null" in "nullable"
+	if end := ic.ptr + uint(len(match)); end < uint(len(source)) && isIdentifierChar(source[end]) {
+		return nil, ic, false
+	}
+
+	cur.ptr = ic.ptr + uint(len(match))
+	cur.loc.col = ic.loc.col + uint(len(match))
+
+	ttype := boolType
+	if match == nullLiteral {
+		ttype = nullType
+	}
+
+	return &token{
+		val: match,
+		typ: ttype,
+		loc: ic.loc,
+	}, cur, true
+}
+
 // lexSymbol analysis the symbols in the source code
 //
 // lexSymbol will eat up the white spaces in the source string
@@ -233,6 +390,7 @@ func lexSymbol(source string, ic cursor) (*token, cursor, bool) {
 		gtSymbol,
 		gteSymbol,
 		asteriskSymbol,
+		placeholderSymbol,
 	}
 
 	var options []string
@@ -263,10 +421,54 @@ func lexString(src string, ic cursor) (*token, cursor, bool) {
 	return lexCharacterDelimited(src, ic, '"')
 }
 
-// lexNumeric analysis the numbers in the source code
+// lexJSON analysis the JSON objects and arrays in the source
+// code, they can span several lines
+func lexJSON(src string, ic cursor) (*token, cursor, bool) {
+	if c := src[ic.ptr]; c != '{' && c != '[' {
+		return nil, ic, false
+	}
+
+	dec := json.NewDecoder(strings.NewReader(src[ic.ptr:]))
+
+	var doc json.RawMessage
+	if err := dec.Decode(&doc); err != nil {
+		return nil, ic, false
+	}
+
+	cur := ic
+	cur.ptr = ic.ptr + uint(dec.InputOffset())
+
+	val := src[ic.ptr:cur.ptr]
+	if i := strings.LastIndexByte(val, '\n'); i >= 0 {
+		cur.loc.line += uint(strings.Count(val, "\n"))
+		cur.loc.col = uint(len(val) - i - 1)
+	} else {
+		cur.loc.col += uint(len(val))
+	}
+
+	return &token{
+		val: val,
+		loc: ic.loc,
+		typ: jsonType,
+	}, cur, true
+}
+
+// lexNumeric analysis the numbers in the source code, they
+// can be negative
 func lexNumeric(src string, ic cursor) (*token, cursor, bool) {
 	cur := ic
 
+	// A minus sign must be followed by the number
+	if src[cur.ptr] == '-' {
+		if cur.ptr+1 >= uint(len(src)) {
+			return nil, ic, false
+		}
+
+		cur.ptr++
+		cur.loc.col++
+	}
+	start := cur.ptr
+
 	// Notes if a period has been found
 	periodFound := false
 	// Notes if an exponent has been found
@@ -282,7 +484,7 @@ func lexNumeric(src string, ic cursor) (*token, cursor, bool) {
 		isExpMarker := ch == 'e'
 
 		// A number must start with a digit or period
-		if cur.ptr == ic.ptr {
+		if cur.ptr == start {
 			if !isDigit && !isPeriod {
 				return nil, ic, false
 			}
@@ -329,12 +531,14 @@ func lexNumeric(src string, ic cursor) (*token, cursor, bool) {
 		}
 
 		if !isDigit {
+			// The character isn't a part of the number
+			cur.loc.col--
 			break
 		}
 	}
 
 	// No characters accumulated
-	if cur.ptr == ic.ptr {
+	if cur.ptr == start {
 		return nil, ic, false
 	}
 
@@ -362,10 +566,7 @@ func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
 	for ; cur.ptr < uint(len(source)); cur.ptr++ {
 		c = source[cur.ptr]
 
-		// Other characters count too, big ignoring non-ascii for now
-		isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
-		isNumeric := c >= '0' && c <= '9'
-		if isAlphabetical || isNumeric || c == '$' || c == '_' {
+		if isIdentifierChar(c) {
 			value = append(value, c)
 			cur.loc.col++
 			continue
@@ -386,6 +587,22 @@ func lexIdentifier(source string, ic cursor) (*token, cursor, bool) {
 	}, cur, true
 }
 
+// isIdentifierChar returns true if the character can
+// be a part of an identifier after its first character
+//
+// ':', '.' and '-' allow namespaced keys like tenantA:users.1
+func isIdentifierChar(c byte) bool {
+	// Other characters count too, big ignoring non-ascii for now
+	isAlphabetical := (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+	isNumeric := c >= '0' && c <= '9'
+	switch c {
+	case '$', '_', ':', '.', '-':
+		return true
+	}
+
+	return isAlphabetical || isNumeric
+}
+
 // lexCharacterDelimited analysis the source code for string with custom delimiter
 func lexCharacterDelimited(src string, ic cursor, delimiter byte) (*token, cursor, bool) {
 	cur := ic
@@ -35,6 +35,44 @@ func Test_lex(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"SET NEGATIVE NUMBER",
+			args{"SET data -1.5e3"},
+			[]*token{
+				{"set", keywordType, location{0, 0}},
+				{"data", identifierType, location{0, 4}},
+				{"-1.5e3", numericType, location{0, 9}},
+			},
+			false,
+		},
+		{
+			"LITERALS",
+			args{"TRUE false Null nullable"},
+			[]*token{
+				{"true", boolType, location{0, 0}},
+				{"false", boolType, location{0, 5}},
+				{"null", nullType, location{0, 11}},
+				{"nullable", identifierType, location{0, 16}},
+			},
+			false,
+		},
+		{
+			"SET JSON",
+			args{"SET data {\"a\": [1, \"}\"],\n\"b\": null} 5"},
+			[]*token{
+				{"set", keywordType, location{0, 0}},
+				{"data", identifierType, location{0, 4}},
+				{"{\"a\": [1, \"}\"],\n\"b\": null}", jsonType, location{0, 9}},
+				{"5", numericType, location{1, 11}},
+			},
+			false,
+		},
+		{
+			"INVALID JSON",
+			args{`SET data {"a": }`},
+			nil,
+			true,
+		},
 		{
 			"GET",
 			args{`GET data`},
@@ -92,6 +130,38 @@ func Test_lex(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"KEYWORD PREFIXED IDENTIFIERS",
+			args{`get settings offset`},
+			[]*token{
+				{"get", keywordType, location{0, 0}},
+				{"settings", identifierType, location{0, 4}},
+				{"offset", identifierType, location{0, 13}},
+			},
+			false,
+		},
+		{
+			"NAMESPACED IDENTIFIERS",
+			args{`get tenantA:users.1 user-2`},
+			[]*token{
+				{"get", keywordType, location{0, 0}},
+				{"tenantA:users.1", identifierType, location{0, 4}},
+				{"user-2", identifierType, location{0, 20}},
+			},
+			false,
+		},
+		{
+			"CONFIG SET DURATION",
+			args{`CONFIG SET janitor_interval 10s`},
+			[]*token{
+				{"CONFIG", identifierType, location{0, 0}},
+				{"set", keywordType, location{0, 7}},
+				{"janitor_interval", identifierType, location{0, 11}},
+				{"10", numericType, location{0, 28}},
+				{"s", identifierType, location{0, 30}},
+			},
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Parser is the parser for RQL
@@ -33,6 +35,17 @@ func expectToken(tokens []*token, cursor uint, t token) bool {
 	return t.equals(tokens[cursor])
 }
 
+// expectWord returns true if the token at the cursor is the word, see
+// words. The words are case insensitive like the keywords
+func expectWord(tokens []*token, cursor uint, k keyword) bool {
+	if cursor >= uint(len(tokens)) {
+		return false
+	}
+
+	t := tokens[cursor]
+	return t.typ == identifierType && strings.ToLower(t.val) == string(k)
+}
+
 func parseToken(tokens []*token, initialCursor uint, typ tokenType) (*token, uint, bool) {
 	cursor := initialCursor
 
@@ -48,6 +61,29 @@ func parseToken(tokens []*token, initialCursor uint, typ tokenType) (*token, uin
 	return nil, initialCursor, false
 }
 
+// bindArgs replaces the placeholders by the
+// arguments, taken as identifiers
+func bindArgs(tokens []*token, args []string) ([]*token, error) {
+	placeholder := tokenFromSymbol(placeholderSymbol)
+
+	n := 0
+	for i, t := range tokens {
+		if !placeholder.equals(t) {
+			continue
+		}
+		if n < len(args) {
+			tokens[i] = &token{val: args[n], loc: t.loc, typ: identifierType}
+		}
+		n++
+	}
+
+	if n != len(args) {
+		return nil, fmt.Errorf("Expected %d arguments for the placeholders, got %d", n, len(args))
+	}
+
+	return tokens, nil
+}
+
 func helpMessage(tokens []*token, cursor uint, msg string) string {
 	var c *token
 	if cursor < uint(len(tokens)) {
@@ -61,10 +97,21 @@ func helpMessage(tokens []*token, cursor uint, msg string) string {
 
 // Parse parses the tokens generated by the lexer and generates and AST
 func Parse(source string) (*Ast, error) {
+	return ParseArgs(source, nil)
+}
+
+// ParseArgs is like Parse except that every "?" placeholder of the source
+// is replaced by the next argument. An argument is taken as it is, so it
+// can hold any bytes including newlines, quotes and semicolons. The
+// placeholders stand for keys, values, user names, passwords and patterns
+func ParseArgs(source string, args []string) (*Ast, error) {
 	tokens, err := lex(source)
 	if err != nil {
 		return nil, err
 	}
+	if tokens, err = bindArgs(tokens, args); err != nil {
+		return nil, err
+	}
 	a := Ast{}
 	cursor := uint(0)
 	for cursor < uint(len(tokens)) {
@@ -159,11 +206,83 @@ func parseStatement(tokens []*token, initialCursor uint, delimiter token) (*Stat
 			PingStatement: ping,
 		}, newCursor, true, err
 	}
+
+	// Look for a CONFIG statement
+	config, newCursor, ok, err := parseConfigStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:             ConfigType,
+			ConfigStatement: config,
+		}, newCursor, true, err
+	}
+
+	// Look for a role statement
+	role, newCursor, ok, err := parseRoleStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:           RoleType,
+			RoleStatement: role,
+		}, newCursor, true, err
+	}
+
+	// Look for an ACL statement
+	acl, newCursor, ok, err := parseACLStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:          ACLType,
+			ACLStatement: acl,
+		}, newCursor, true, err
+	}
+
+	// Look for a user statement
+	user, newCursor, ok, err := parseUserStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:           UserType,
+			UserStatement: user,
+		}, newCursor, true, err
+	}
+
+	// Look for a lock statement
+	lock, newCursor, ok, err := parseLockStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:           LockType,
+			LockStatement: lock,
+		}, newCursor, true, err
+	}
+
+	// Look for an expiry statement
+	expire, newCursor, ok, err := parseExpireStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:             ExpireType,
+			ExpireStatement: expire,
+		}, newCursor, true, err
+	}
+
+	// Look for a counter statement
+	counter, newCursor, ok, err := parseCounterStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:              CounterType,
+			CounterStatement: counter,
+		}, newCursor, true, err
+	}
+
+	// Look for a compare and swap statement
+	cas, newCursor, ok, err := parseCasStatement(tokens, cursor, delimiter)
+	if ok {
+		return &Statement{
+			Typ:          CasType,
+			CasStatement: cas,
+		}, newCursor, true, err
+	}
 	return nil, initialCursor, false, nil
 }
 
 func parseSetStatement(tokens []*token, initialCursor uint, delimiter token) (*SetStatement, uint, bool, error) {
-	// SET <key> <value> [expiry] [TODO: ADD COMPLEX EXPRESSIONS SUPPORT]
+	// SET <key> <value> [expiry | EXPIREIN <duration>] [IF <condition>]
 	cursor := initialCursor
 
 	// Look for the SET keyword
@@ -184,28 +303,122 @@ func parseSetStatement(tokens []*token, initialCursor uint, delimiter token) (*S
 	if !ok {
 		return nil, initialCursor, true, errors.New(helpMessage(tokens, cursor, "Expected a value"))
 	}
+
+	data, err := literalValue(val)
+	if err != nil {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid value"))
+	}
 	cursor = newCursor
 
+	stmt := &SetStatement{key: key.val, val: data}
+
 	// Search for optional expiry
-	exp, newCursor, ok := parseToken(tokens, cursor, numericType)
+	stmt.exp, cursor, err = parseExpiry(tokens, cursor)
+	if err != nil {
+		return nil, cursor, true, err
+	}
+
+	// Search for optional condition
+	cond, newCursor, err := parseCondition(tokens, cursor)
+	if err != nil {
+		return nil, cursor, true, err
+	}
+	stmt.cond = cond
+
+	return stmt, newCursor, true, nil
+}
+
+// parseExpiry parses the optional expiry of a value in milliseconds,
+// either as a number or as a duration after the EXPIREIN keyword. It
+// returns 0 if there is none
+func parseExpiry(tokens []*token, initialCursor uint) (uint, uint, error) {
+	cursor := initialCursor
+
+	if expectToken(tokens, cursor, tokenFromKeyword(expireinKeyword)) {
+		cursor++
+
+		exp, newCursor, ok := parseDuration(tokens, cursor)
+		if !ok || exp < time.Millisecond {
+			return 0, cursor, errors.New(helpMessage(tokens, cursor, "Expected a duration like 500ms, 10s, 5m or 1h"))
+		}
+
+		return uint(exp / time.Millisecond), newCursor, nil
+	}
+
+	if exp, newCursor, ok := parseToken(tokens, cursor, numericType); ok {
+		expVal, err := strconv.ParseUint(exp.val, 10, 32)
+		if err != nil {
+			return 0, cursor, errors.New(helpMessage(tokens, cursor, "Invalid expiry provided"))
+		}
+
+		return uint(expVal), newCursor, nil
+	}
+
+	return 0, initialCursor, nil
+}
+
+func parseCasStatement(tokens []*token, initialCursor uint, delimiter token) (*CasStatement, uint, bool, error) {
+	// CAS <key> <version> <value> [expiry | EXPIREIN <duration>]
+	cursor := initialCursor
+
+	// Look for the CAS keyword
+	if !expectWord(tokens, cursor, casKeyword) {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	// Look for the key name
+	key, newCursor, ok := parseToken(tokens, cursor, identifierType)
 	if !ok {
-		return &SetStatement{
-			key: key.val,
-			val: val.val,
-		}, cursor, true, nil
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a key name"))
 	}
+	cursor = newCursor
 
-	expVal, err := strconv.ParseUint(exp.val, 10, 32)
+	version, cursor, err := parseVersion(tokens, cursor)
 	if err != nil {
-		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid expiry provided"))
+		return nil, cursor, true, err
+	}
+
+	// Look for the value
+	val, newCursor, ok := parseExpression(tokens, cursor)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a value"))
+	}
+
+	data, err := literalValue(val)
+	if err != nil {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid value"))
 	}
 	cursor = newCursor
 
-	return &SetStatement{key.val, val.val, uint(expVal)}, cursor, true, nil
+	stmt := &CasStatement{key: key.val, version: version, val: data}
+
+	// Search for optional expiry
+	stmt.exp, cursor, err = parseExpiry(tokens, cursor)
+	if err != nil {
+		return nil, cursor, true, err
+	}
+
+	return stmt, cursor, true, nil
+}
+
+// parseVersion parses the version of a key
+func parseVersion(tokens []*token, initialCursor uint) (uint64, uint, error) {
+	t, cursor, ok := parseToken(tokens, initialCursor, numericType)
+	if !ok {
+		return 0, initialCursor, errors.New(helpMessage(tokens, initialCursor, "Expected a version"))
+	}
+
+	version, err := strconv.ParseUint(t.val, 10, 64)
+	if err != nil {
+		return 0, initialCursor, errors.New(helpMessage(tokens, initialCursor, "Invalid version provided"))
+	}
+
+	return version, cursor, nil
 }
 
 func parseGetStatement(tokens []*token, initialCursor uint, delimiter token) (*GetStatement, uint, bool, error) {
-	// GET key1 key2 ...
+	// GET key1 key2 ... [WITH VERSION]
 	cursor := initialCursor
 
 	// Look for the GET keyword
@@ -218,13 +431,23 @@ func parseGetStatement(tokens []*token, initialCursor uint, delimiter token) (*G
 
 	for {
 		key, newCursor, ok := parseToken(tokens, cursor, identifierType)
+
+		// WITH VERSION ends the keys, the keys can be named after its words
+		withVersion := len(keys) > 0 && expectWord(tokens, cursor, withKeyword) &&
+			expectWord(tokens, cursor+1, versionKeyword) && expectToken(tokens, cursor+2, delimiter)
+		if withVersion {
+			return &GetStatement{keys: keys, withVersion: true}, cursor + 2, true, nil
+		}
+
 		if !ok {
+			stmt := &GetStatement{keys: keys}
+
 			// Check if the token is the delimiter
-			if !expectToken(tokens, newCursor, delimiter) {
-				return nil, newCursor, true, errors.New(helpMessage(tokens, cursor, "Invalid key name"))
+			if !expectToken(tokens, cursor, delimiter) {
+				return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid key name"))
 			}
 
-			return &GetStatement{keys}, cursor, true, nil
+			return stmt, cursor, true, nil
 		}
 
 		keys = append(keys, key.val)
@@ -233,7 +456,7 @@ func parseGetStatement(tokens []*token, initialCursor uint, delimiter token) (*G
 }
 
 func parseDeleteStatement(tokens []*token, initialCursor uint, delimiter token) (*DeleteStatement, uint, bool, error) {
-	// DEL key1 key2 ...
+	// DEL key1 key2 ... [IF <condition>] | DEL key IF VERSION <version>
 	cursor := initialCursor
 
 	// Look for the DEL keyword
@@ -247,17 +470,44 @@ func parseDeleteStatement(tokens []*token, initialCursor uint, delimiter token)
 	for {
 		key, newCursor, ok := parseToken(tokens, cursor, identifierType)
 		if !ok {
-			// Check if the token is the delimiter
-			if !expectToken(tokens, newCursor, delimiter) {
-				return nil, newCursor, true, errors.New(helpMessage(tokens, cursor, "Invalid key name"))
-			}
-
-			return &DeleteStatement{keys}, cursor, true, nil
+			break
 		}
 
 		keys = append(keys, key.val)
 		cursor = newCursor
 	}
+
+	// Search for the optional version of the key, IF VERSION is a
+	// condition upon the key named version unless a number follows
+	_, _, numbered := parseToken(tokens, cursor+2, numericType)
+	if expectToken(tokens, cursor, tokenFromKeyword(ifKeyword)) && expectWord(tokens, cursor+1, versionKeyword) && numbered {
+		if len(keys) != 1 {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a single key with IF VERSION"))
+		}
+
+		version, newCursor, err := parseVersion(tokens, cursor+2)
+		if err != nil {
+			return nil, cursor, true, err
+		}
+
+		return &DeleteStatement{keys: keys, version: version, checkVersion: true}, newCursor, true, nil
+	}
+
+	// Search for optional condition
+	cond, newCursor, err := parseCondition(tokens, cursor)
+	if err != nil {
+		return nil, cursor, true, err
+	}
+	if cond != nil {
+		return &DeleteStatement{keys: keys, cond: cond}, newCursor, true, nil
+	}
+
+	// Check if the token is the delimiter
+	if !expectToken(tokens, cursor, delimiter) {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid key name"))
+	}
+
+	return &DeleteStatement{keys: keys}, cursor, true, nil
 }
 
 func parseAuthStatement(tokens []*token, initialCursor uint, delimiter token) (*AuthStatement, uint, bool, error) {
@@ -301,7 +551,7 @@ func parseWipeStatement(tokens []*token, initialCursor uint, delimiter token) (*
 }
 
 func parseRegUserStatement(tokens []*token, initialCursor uint, delimiter token) (*RegUserStatement, uint, bool, error) {
-	// REGUSER <username> <password> [access_level];
+	// REGUSER <username> <password> [access_level] [REPLACE];
 	cursor := initialCursor
 
 	// Look for the REGUSER keyword
@@ -324,26 +574,29 @@ func parseRegUserStatement(tokens []*token, initialCursor uint, delimiter token)
 	}
 	cursor = newCursor
 
+	stmt := &RegUserStatement{username: username.val, password: password.val}
+
 	// Search for optional accesslevel
-	exp, newCursor, ok := parseToken(tokens, cursor, numericType)
-	if !ok {
-		return &RegUserStatement{
-			username: username.val,
-			password: password.val,
-		}, cursor, true, nil
+	if exp, newCursor, ok := parseToken(tokens, cursor, numericType); ok {
+		accesslevel, err := strconv.ParseUint(exp.val, 10, 16)
+		if err != nil {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid access level provided"))
+		}
+		cursor = newCursor
+		stmt.access = uint(accesslevel)
 	}
 
-	accesslevel, err := strconv.ParseUint(exp.val, 10, 16)
-	if err != nil {
-		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid access level provided"))
+	// Search for optional REPLACE
+	if expectToken(tokens, cursor, tokenFromKeyword(replaceKeyword)) {
+		cursor++
+		stmt.replace = true
 	}
-	cursor = newCursor
 
-	return &RegUserStatement{username.val, password.val, uint(accesslevel)}, cursor, true, nil
+	return stmt, cursor, true, nil
 }
 
 func parsePingStatement(tokens []*token, initialCursor uint, delimiter token) (*PingStatement, uint, bool, error) {
-	// PING ON <OPERATION>
+	// PING ON|OFF GET|SET|DEL|WIPE|LOCKOUT
 	cursor := initialCursor
 
 	// Look for "PING" keyword
@@ -372,13 +625,678 @@ func parsePingStatement(tokens []*token, initialCursor uint, delimiter token) (*
 		}
 	}
 
+	// Look for the "LOCKOUT" word
+	if expectWord(tokens, cursor, lockoutKeyword) {
+		cursor++
+		return &PingStatement{on, string(lockoutKeyword)}, cursor, true, nil
+	}
+
 	return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a valid operation"))
 }
 
+func parseConfigStatement(tokens []*token, initialCursor uint, delimiter token) (*ConfigStatement, uint, bool, error) {
+	// CONFIG GET <pattern> | CONFIG SET <name> <value> | CONFIG REWRITE
+	cursor := initialCursor
+
+	// Look for the CONFIG keyword
+	if !expectWord(tokens, cursor, configKeyword) {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	switch {
+	case expectToken(tokens, cursor, tokenFromKeyword(getKeyword)):
+		cursor++
+
+		// Look for the pattern
+		pattern, newCursor, ok := parsePattern(tokens, cursor)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a setting name or pattern"))
+		}
+
+		return &ConfigStatement{action: string(getKeyword), name: pattern}, newCursor, true, nil
+	case expectToken(tokens, cursor, tokenFromKeyword(setKeyword)):
+		cursor++
+
+		// Look for the setting name
+		name, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a setting name"))
+		}
+		cursor = newCursor
+
+		// Look for the value
+		value, newCursor, ok := parseSettingValue(tokens, cursor)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a value"))
+		}
+
+		return &ConfigStatement{string(setKeyword), name.val, value}, newCursor, true, nil
+	case expectWord(tokens, cursor, rewriteKeyword):
+		cursor++
+
+		return &ConfigStatement{action: string(rewriteKeyword)}, cursor, true, nil
+	}
+
+	return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected GET, SET or REWRITE after CONFIG"))
+}
+
+func parseRoleStatement(tokens []*token, initialCursor uint, delimiter token) (*RoleStatement, uint, bool, error) {
+	// CREATEROLE <role> [permission ...] | DROPROLE <role> | ROLES
+	// GRANT <permission> [permission ...] TO <role>
+	// REVOKE <permission> [permission ...] FROM <role>
+	// ASSIGN <role> TO <username> | UNASSIGN <role> FROM <username>
+	cursor := initialCursor
+
+	var action keyword
+	for _, kw := range []keyword{createroleKeyword, droproleKeyword, grantKeyword, revokeKeyword, assignKeyword, unassignKeyword, rolesKeyword} {
+		if expectWord(tokens, cursor, kw) {
+			action = kw
+			break
+		}
+	}
+	if action == "" {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	stmt := &RoleStatement{action: string(action)}
+
+	switch action {
+	case rolesKeyword:
+		return stmt, cursor, true, nil
+	case createroleKeyword, droproleKeyword:
+		role, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a role name"))
+		}
+		cursor = newCursor
+		stmt.role = role.val
+
+		if action == createroleKeyword {
+			stmt.permissions, cursor = parsePermissions(tokens, cursor, delimiter)
+		}
+
+		return stmt, cursor, true, nil
+	case grantKeyword, revokeKeyword:
+		stmt.permissions, cursor = parsePermissions(tokens, cursor, delimiter)
+		if len(stmt.permissions) == 0 {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a permission"))
+		}
+
+		preposition := toKeyword
+		if action == revokeKeyword {
+			preposition = fromKeyword
+		}
+		if !expectWord(tokens, cursor, preposition) {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected "+strings.ToUpper(string(preposition))))
+		}
+		cursor++
+
+		role, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a role name"))
+		}
+		stmt.role = role.val
+
+		return stmt, newCursor, true, nil
+	}
+
+	// ASSIGN and UNASSIGN
+	role, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a role name"))
+	}
+	cursor = newCursor
+	stmt.role = role.val
+
+	preposition := toKeyword
+	if action == unassignKeyword {
+		preposition = fromKeyword
+	}
+	if !expectWord(tokens, cursor, preposition) {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected "+strings.ToUpper(string(preposition))))
+	}
+	cursor++
+
+	username, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a username"))
+	}
+	stmt.username = username.val
+
+	return stmt, newCursor, true, nil
+}
+
+func parseACLStatement(tokens []*token, initialCursor uint, delimiter token) (*ACLStatement, uint, bool, error) {
+	// ACL ADD <username> <pattern> <permission> [permission ...]
+	// ACL DEL <username> <pattern> | ACL LIST <username>
+	cursor := initialCursor
+
+	// Look for the ACL keyword
+	if !expectWord(tokens, cursor, aclKeyword) {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	// ADD and LIST are not keywords so that they can still be used as keys
+	if cursor >= uint(len(tokens)) {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected ADD, DEL or LIST after ACL"))
+	}
+	action := strings.ToLower(tokens[cursor].val)
+	switch {
+	case tokens[cursor].typ == identifierType && (action == "add" || action == "list"):
+	case expectToken(tokens, cursor, tokenFromKeyword(delKeyword)):
+	default:
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected ADD, DEL or LIST after ACL"))
+	}
+	cursor++
+
+	// Look for the username
+	username, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a username"))
+	}
+	cursor = newCursor
+
+	stmt := &ACLStatement{action: action, username: username.val}
+	if action == "list" {
+		return stmt, cursor, true, nil
+	}
+
+	// Look for the key pattern
+	pattern, newCursor, ok := parsePattern(tokens, cursor)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a key pattern"))
+	}
+	cursor = newCursor
+	stmt.pattern = pattern
+
+	if action == "add" {
+		stmt.permissions, cursor = parsePermissions(tokens, cursor, delimiter)
+		if len(stmt.permissions) == 0 {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a permission"))
+		}
+	}
+
+	return stmt, cursor, true, nil
+}
+
+func parseUserStatement(tokens []*token, initialCursor uint, delimiter token) (*UserStatement, uint, bool, error) {
+	// DELUSER <username> | SETACCESS <username> <access_level>
+	// PASSWD <old_password> <new_password> | PASSWD <username> TO <new_password>
+	// LISTUSERS | WHOAMI
+	cursor := initialCursor
+
+	var action keyword
+	for _, kw := range []keyword{deluserKeyword, passwdKeyword, setaccessKeyword, listusersKeyword, whoamiKeyword} {
+		if expectToken(tokens, cursor, tokenFromKeyword(kw)) {
+			action = kw
+			break
+		}
+	}
+	if action == "" {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	stmt := &UserStatement{action: string(action)}
+
+	switch action {
+	case listusersKeyword, whoamiKeyword:
+		return stmt, cursor, true, nil
+	case passwdKeyword:
+		first, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected the current password or a username"))
+		}
+		cursor = newCursor
+
+		// PASSWD <username> TO <new_password> forces the password upon another user
+		forced := expectWord(tokens, cursor, toKeyword)
+		if forced {
+			cursor++
+		}
+
+		password, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a new password"))
+		}
+
+		if forced {
+			stmt.username = first.val
+		} else {
+			stmt.oldPassword = first.val
+		}
+		stmt.password = password.val
+
+		return stmt, newCursor, true, nil
+	}
+
+	// DELUSER and SETACCESS
+	username, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a username"))
+	}
+	cursor = newCursor
+	stmt.username = username.val
+
+	if action == setaccessKeyword {
+		level, newCursor, ok := parseToken(tokens, cursor, numericType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected an access level"))
+		}
+
+		access, err := strconv.ParseUint(level.val, 10, 16)
+		if err != nil {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid access level provided"))
+		}
+		cursor = newCursor
+		stmt.access = uint(access)
+	}
+
+	return stmt, cursor, true, nil
+}
+
+func parseLockStatement(tokens []*token, initialCursor uint, delimiter token) (*LockStatement, uint, bool, error) {
+	// LOCKS | UNLOCK <pattern>
+	cursor := initialCursor
+
+	if expectWord(tokens, cursor, locksKeyword) {
+		return &LockStatement{action: string(locksKeyword)}, cursor + 1, true, nil
+	}
+
+	if !expectWord(tokens, cursor, unlockKeyword) {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	// Look for the pattern of the targets
+	pattern, newCursor, ok := parsePattern(tokens, cursor)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a user:<username> or addr:<address> pattern"))
+	}
+
+	return &LockStatement{action: string(unlockKeyword), pattern: pattern}, newCursor, true, nil
+}
+
+func parseExpireStatement(tokens []*token, initialCursor uint, delimiter token) (*ExpireStatement, uint, bool, error) {
+	// TTL <key> | PTTL <key> | PERSIST <key>
+	// EXPIRE <key> <duration> | EXPIREAT <key> <unix_time_ms | "RFC 3339 time">
+	cursor := initialCursor
+
+	var action keyword
+	for _, kw := range []keyword{ttlKeyword, pttlKeyword, expireKeyword, expireatKeyword, persistKeyword} {
+		if expectWord(tokens, cursor, kw) {
+			action = kw
+			break
+		}
+	}
+	if action == "" {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	// Look for the key name
+	key, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a key name"))
+	}
+	cursor = newCursor
+
+	stmt := &ExpireStatement{action: string(action), key: key.val}
+
+	switch action {
+	case expireKeyword:
+		exp, newCursor, ok := parseDuration(tokens, cursor)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a duration like 500ms, 10s, 5m or 1h"))
+		}
+		stmt.expireIn = exp
+
+		return stmt, newCursor, true, nil
+	case expireatKeyword:
+		if t, newCursor, ok := parseToken(tokens, cursor, numericType); ok {
+			ms, err := strconv.ParseInt(t.val, 10, 64)
+			if err != nil {
+				return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid unix time provided"))
+			}
+			stmt.expireAt = time.Unix(0, ms*int64(time.Millisecond))
+
+			return stmt, newCursor, true, nil
+		}
+
+		t, newCursor, ok := parseToken(tokens, cursor, stringType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a unix time in milliseconds or an RFC 3339 time"))
+		}
+
+		at, err := time.Parse(time.RFC3339, t.val)
+		if err != nil {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid RFC 3339 time provided"))
+		}
+		stmt.expireAt = at
+
+		return stmt, newCursor, true, nil
+	}
+
+	return stmt, cursor, true, nil
+}
+
+func parseCounterStatement(tokens []*token, initialCursor uint, delimiter token) (*CounterStatement, uint, bool, error) {
+	// INCR <key> | DECR <key> | INCRBY <key> <integer> | INCRBYFLOAT <key> <number>
+	cursor := initialCursor
+
+	var action keyword
+	for _, kw := range []keyword{incrKeyword, decrKeyword, incrbyKeyword, incrbyfloatKeyword} {
+		if expectToken(tokens, cursor, tokenFromKeyword(kw)) {
+			action = kw
+			break
+		}
+	}
+	if action == "" {
+		return nil, initialCursor, false, nil
+	}
+	cursor++
+
+	// Look for the key name
+	key, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a key name"))
+	}
+	cursor = newCursor
+
+	stmt := &CounterStatement{action: string(action), key: key.val}
+
+	switch action {
+	case incrKeyword:
+		stmt.by = 1
+	case decrKeyword:
+		stmt.by = -1
+	case incrbyKeyword:
+		t, newCursor, ok := parseToken(tokens, cursor, numericType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected an integer"))
+		}
+
+		by, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid integer provided"))
+		}
+		stmt.by = by
+
+		return stmt, newCursor, true, nil
+	case incrbyfloatKeyword:
+		t, newCursor, ok := parseToken(tokens, cursor, numericType)
+		if !ok {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Expected a number"))
+		}
+
+		by, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, cursor, true, errors.New(helpMessage(tokens, cursor, "Invalid number provided"))
+		}
+		stmt.byFloat = by
+
+		return stmt, newCursor, true, nil
+	}
+
+	return stmt, cursor, true, nil
+}
+
+// parseDuration parses a duration like 500ms, 10s, 5m or 1h. A
+// number without a unit is a duration in milliseconds
+func parseDuration(tokens []*token, initialCursor uint) (time.Duration, uint, bool) {
+	val, cursor, ok := parseToken(tokens, initialCursor, numericType)
+	if !ok {
+		return 0, initialCursor, false
+	}
+
+	// The unit is lexed as an identifier right next to the number
+	if cursor < uint(len(tokens)) && tokens[cursor].typ == identifierType && adjacent(val, tokens[cursor]) {
+		d, err := time.ParseDuration(val.val + tokens[cursor].val)
+		if err != nil {
+			return 0, initialCursor, false
+		}
+
+		return d, cursor + 1, true
+	}
+
+	ms, err := strconv.ParseUint(val.val, 10, 32)
+	if err != nil {
+		return 0, initialCursor, false
+	}
+
+	return time.Duration(ms) * time.Millisecond, cursor, true
+}
+
+// parsePermissions parses the names of the permissions until the
+// delimiter, TO or FROM. Permissions can be identifiers, strings or
+// keywords as some of the permissions are named after the commands
+func parsePermissions(tokens []*token, initialCursor uint, delimiter token) ([]string, uint) {
+	cursor := initialCursor
+	perms := []string{}
+
+	for cursor < uint(len(tokens)) {
+		t := tokens[cursor]
+		if t.equals(&delimiter) || expectWord(tokens, cursor, toKeyword) || expectWord(tokens, cursor, fromKeyword) {
+			break
+		}
+
+		if t.typ != identifierType && t.typ != stringType && t.typ != keywordType {
+			break
+		}
+
+		perms = append(perms, t.val)
+		cursor++
+	}
+
+	return perms, cursor
+}
+
+// parsePattern parses a glob pattern which is either a string or
+// a run of identifiers and asterisks like janitor* or *
+func parsePattern(tokens []*token, initialCursor uint) (string, uint, bool) {
+	cursor := initialCursor
+
+	if str, newCursor, ok := parseToken(tokens, cursor, stringType); ok {
+		return str.val, newCursor, true
+	}
+
+	pattern := ""
+	for cursor < uint(len(tokens)) {
+		t := tokens[cursor]
+		if t.typ != identifierType && !t.equals(&token{val: string(asteriskSymbol), typ: symbolType}) {
+			break
+		}
+
+		// The parts of a pattern are written without spaces
+		if pattern != "" && !adjacent(tokens[cursor-1], t) {
+			break
+		}
+
+		pattern += t.val
+		cursor++
+	}
+
+	return pattern, cursor, pattern != ""
+}
+
+// parseSettingValue parses the value of a setting which is either a
+// string, an identifier, a number or a duration like 10s or 1m30s
+func parseSettingValue(tokens []*token, initialCursor uint) (string, uint, bool) {
+	cursor := initialCursor
+
+	val, newCursor, ok := parseExpression(tokens, cursor)
+	if !ok {
+		return "", initialCursor, false
+	}
+	cursor = newCursor
+
+	// The unit of a duration is lexed as an identifier
+	// right next to the number
+	if val.typ == numericType && cursor < uint(len(tokens)) {
+		unit := tokens[cursor]
+		if unit.typ == identifierType && adjacent(val, unit) {
+			return val.val + unit.val, cursor + 1, true
+		}
+	}
+
+	return val.val, cursor, true
+}
+
+// adjacent returns true if the token b follows
+// the token a without any space in between
+func adjacent(a, b *token) bool {
+	return a.loc.line == b.loc.line && b.loc.col == a.loc.col+uint(len(a.val))
+}
+
+// parseCondition parses the optional IF clause of a statement, it returns
+// nil if there is none. AND binds tighter than OR and the parentheses
+// group the conditions:
+//
+//	IF k1 == 4 AND (EXISTS(k2) OR k3 != "done")
+func parseCondition(tokens []*token, initialCursor uint) (*expression, uint, error) {
+	// Look for the IF keyword
+	if !expectToken(tokens, initialCursor, tokenFromKeyword(ifKeyword)) {
+		return nil, initialCursor, nil
+	}
+
+	return parseOr(tokens, initialCursor+1)
+}
+
+// parseOr parses the conditions joined by OR
+func parseOr(tokens []*token, initialCursor uint) (*expression, uint, error) {
+	return parseLogical(tokens, initialCursor, orKeyword, parseAnd)
+}
+
+// parseAnd parses the conditions joined by AND
+func parseAnd(tokens []*token, initialCursor uint) (*expression, uint, error) {
+	return parseLogical(tokens, initialCursor, andKeyword, parsePredicate)
+}
+
+// parseLogical parses the operands, parsed by parseOperand, joined by
+// the operator. The operators are left associative
+func parseLogical(
+	tokens []*token,
+	initialCursor uint,
+	operator keyword,
+	parseOperand func([]*token, uint) (*expression, uint, error),
+) (*expression, uint, error) {
+	a, cursor, err := parseOperand(tokens, initialCursor)
+	if err != nil {
+		return nil, initialCursor, err
+	}
+
+	for expectToken(tokens, cursor, tokenFromKeyword(operator)) {
+		op := tokens[cursor]
+
+		b, newCursor, err := parseOperand(tokens, cursor+1)
+		if err != nil {
+			return nil, initialCursor, err
+		}
+		cursor = newCursor
+
+		a = &expression{binary: &binaryExpression{*a, *b, *op}, typ: binaryType}
+	}
+
+	return a, cursor, nil
+}
+
+// parsePredicate parses a condition in parentheses, an EXISTS(<key>)
+// or the comparison of the value of a key with a value
+func parsePredicate(tokens []*token, initialCursor uint) (*expression, uint, error) {
+	cursor := initialCursor
+
+	// Look for a condition in parentheses
+	if expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+		cond, newCursor, err := parseOr(tokens, cursor+1)
+		if err != nil {
+			return nil, initialCursor, err
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+			return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a closing parenthesis"))
+		}
+
+		return cond, cursor + 1, nil
+	}
+
+	// Look for EXISTS(<key>)
+	if expectToken(tokens, cursor, tokenFromKeyword(existsKeyword)) {
+		cursor++
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(leftParenSymbol)) {
+			return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected an opening parenthesis"))
+		}
+		cursor++
+
+		key, newCursor, ok := parseToken(tokens, cursor, identifierType)
+		if !ok {
+			return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a key name"))
+		}
+		cursor = newCursor
+
+		if !expectToken(tokens, cursor, tokenFromSymbol(rightParenSymbol)) {
+			return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a closing parenthesis"))
+		}
+
+		return &expression{literal: key, typ: existsType}, cursor + 1, nil
+	}
+
+	// Look for the key name
+	key, newCursor, ok := parseToken(tokens, cursor, identifierType)
+	if !ok {
+		return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a condition"))
+	}
+	cursor = newCursor
+
+	// Look for the comparison operator
+	op, ok := parseComparison(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a comparison operator"))
+	}
+	cursor++
+
+	// Look for the value
+	val, newCursor, ok := parseExpression(tokens, cursor)
+	if !ok {
+		return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Expected a value"))
+	}
+
+	value, err := literalValue(val)
+	if err != nil {
+		return nil, initialCursor, errors.New(helpMessage(tokens, cursor, "Invalid value"))
+	}
+	cursor = newCursor
+
+	return &expression{
+		binary: &binaryExpression{
+			a:  expression{literal: key, typ: literalType},
+			b:  expression{literal: val, value: value, typ: literalType},
+			op: *op,
+		},
+		typ: binaryType,
+	}, cursor, nil
+}
+
+// parseComparison returns the comparison operator at the cursor
+func parseComparison(tokens []*token, cursor uint) (*token, bool) {
+	operators := []symbol{eqSymbol, neqSymbol, ltSymbol, lteSymbol, gtSymbol, gteSymbol}
+	for _, op := range operators {
+		if expectToken(tokens, cursor, tokenFromSymbol(op)) {
+			return tokens[cursor], true
+		}
+	}
+
+	return nil, false
+}
+
 func parseExpression(tokens []*token, initialCursor uint) (*token, uint, bool) {
 	cursor := initialCursor
 
-	types := []tokenType{identifierType, numericType, stringType}
+	types := []tokenType{identifierType, numericType, stringType, boolType, nullType, jsonType}
 	for _, typ := range types {
 		t, newCursor, ok := parseToken(tokens, cursor, typ)
 		if ok {
@@ -3,6 +3,7 @@ package rql
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -81,6 +82,162 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"SET STATEMENT WITH NEGATIVE INTEGER",
+			args{`SET data -42 100;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: int64(-42),
+							exp: 100,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH FLOAT",
+			args{`SET data 2.5e3;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: 2500.0,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH OVERFLOWING INTEGER",
+			args{`SET data 9223372036854775808;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: SetType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"SET STATEMENT WITH OVERFLOWING INTEGER IN JSON",
+			args{`SET data {"n": -9223372036854775809};`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: SetType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"SET STATEMENT WITH LARGE FLOAT",
+			args{`SET data 9223372036854775808.0;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: 9223372036854775808.0,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH BOOLEAN",
+			args{`SET data FALSE;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: false,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH NULL",
+			args{`SET data null;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: nil,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH QUOTED LITERAL",
+			args{`SET data "true";`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: "true",
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH JSON",
+			args{`SET data {"a": [1, 2.5, "x"], "b": {"c": null}};`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: map[string]interface{}{
+								"a": []interface{}{int64(1), 2.5, "x"},
+								"b": map[string]interface{}{"c": nil},
+							},
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH JSON ARRAY",
+			args{`SET data [];`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: []interface{}{},
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
 		{
 			"SET STATEMENT WITH EXPIRY",
 			args{`SET data "Hello World" 234;`},
@@ -98,6 +255,70 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"SET STATEMENT WITH EXPIREIN",
+			args{`SET data "Hello World" EXPIREIN 1m30s;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: "Hello World",
+							exp: 90000,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH EXPIREIN WITHOUT UNIT",
+			args{`SET data "Hello World" EXPIREIN 234;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "data",
+							val: "Hello World",
+							exp: 234,
+						},
+						Typ: SetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SET STATEMENT WITH EXPIREIN BELOW A MILLISECOND",
+			args{`SET data "Hello World" EXPIREIN 10us;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: SetType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"SET STATEMENT WITH EXPIREIN WITH UNKNOWN UNIT",
+			args{`SET data "Hello World" EXPIREIN 2d;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: SetType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"SET STATEMENT WITH EXPIREIN WITH SEPARATED UNIT",
+			args{`SET data "Hello World" EXPIREIN 10 s;`},
+			nil,
+			true,
+		},
 		{
 			"MULTI SET STATEMENTS",
 			args{`SET data "Hello World" 234; SET data1 3454 565;`},
@@ -114,7 +335,7 @@ func TestParse(t *testing.T) {
 					{
 						SetStatement: &SetStatement{
 							key: "data1",
-							val: "3454",
+							val: int64(3454),
 							exp: 565,
 						},
 						Typ: SetType,
@@ -182,6 +403,29 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"VALID LOCKOUT PING STATEMENT",
+			args{`PING ON LOCKOUT; PING OFF lockout;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						PingStatement: &PingStatement{
+							operation: "lockout",
+							on:        true,
+						},
+						Typ: PingType,
+					},
+					{
+						PingStatement: &PingStatement{
+							operation: "lockout",
+							on:        false,
+						},
+						Typ: PingType,
+					},
+				},
+			},
+			false,
+		},
 		{
 			"VALID UNPING STATEMENT",
 			args{`PING OFF GET;`},
@@ -238,16 +482,655 @@ func TestParse(t *testing.T) {
 			},
 			false,
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := Parse(tt.args.source)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("Parse() = %v, want %v", got, tt.want)
+		{
+			"CONFIG GET UNQUOTED PATTERN STARTING WITH UNDERSCORE",
+			args{`CONFIG GET *_interval;`},
+			nil,
+			true,
+		},
+		{
+			"CONFIG STATEMENTS",
+			args{`CONFIG GET "*_interval"; CONFIG GET janitor*; CONFIG SET persist_interval 1m30s; CONFIG SET fsync always; CONFIG SET max_clients 10; CONFIG REWRITE;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						ConfigStatement: &ConfigStatement{action: "get", name: "*_interval"},
+						Typ:             ConfigType,
+					},
+					{
+						ConfigStatement: &ConfigStatement{action: "get", name: "janitor*"},
+						Typ:             ConfigType,
+					},
+					{
+						ConfigStatement: &ConfigStatement{"set", "persist_interval", "1m30s"},
+						Typ:             ConfigType,
+					},
+					{
+						ConfigStatement: &ConfigStatement{"set", "fsync", "always"},
+						Typ:             ConfigType,
+					},
+					{
+						ConfigStatement: &ConfigStatement{"set", "max_clients", "10"},
+						Typ:             ConfigType,
+					},
+					{
+						ConfigStatement: &ConfigStatement{action: "rewrite"},
+						Typ:             ConfigType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"CONFIG WORDS AS KEYS",
+			args{`SET config rewrite; GET rewrite config;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{key: "config", val: "rewrite"},
+						Typ:          SetType,
+					},
+					{
+						GetStatement: &GetStatement{keys: []string{"rewrite", "config"}},
+						Typ:          GetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"ROLE STATEMENTS",
+			args{`CREATEROLE ops read wipe "manage-users"; GRANT write config TO ops; REVOKE wipe FROM ops; ASSIGN ops TO bob; UNASSIGN ops FROM bob; DROPROLE ops; ROLES;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						RoleStatement: &RoleStatement{action: "createrole", role: "ops", permissions: []string{"read", "wipe", "manage-users"}},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "grant", role: "ops", permissions: []string{"write", "config"}},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "revoke", role: "ops", permissions: []string{"wipe"}},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "assign", role: "ops", username: "bob"},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "unassign", role: "ops", username: "bob"},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "droprole", role: "ops"},
+						Typ:           RoleType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "roles"},
+						Typ:           RoleType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"ROLE WORDS AS KEYS",
+			args{`SET to from; GET roles Grant user:1; ASSIGN to TO from;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{key: "to", val: "from"},
+						Typ:          SetType,
+					},
+					{
+						GetStatement: &GetStatement{keys: []string{"roles", "Grant", "user:1"}},
+						Typ:          GetType,
+					},
+					{
+						RoleStatement: &RoleStatement{action: "assign", role: "to", username: "from"},
+						Typ:           RoleType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"GRANT STATEMENT WITHOUT ROLE",
+			args{`GRANT read ops;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: RoleType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"ACL STATEMENTS",
+			args{`ACL ADD team tenantA:* read write; ACL add team "config:?" read; ACL DEL team tenantA:*; ACL LIST team; GET tenantA:k1 acl;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						ACLStatement: &ACLStatement{action: "add", username: "team", pattern: "tenantA:*", permissions: []string{"read", "write"}},
+						Typ:          ACLType,
+					},
+					{
+						ACLStatement: &ACLStatement{action: "add", username: "team", pattern: "config:?", permissions: []string{"read"}},
+						Typ:          ACLType,
+					},
+					{
+						ACLStatement: &ACLStatement{action: "del", username: "team", pattern: "tenantA:*"},
+						Typ:          ACLType,
+					},
+					{
+						ACLStatement: &ACLStatement{action: "list", username: "team"},
+						Typ:          ACLType,
+					},
+					{
+						GetStatement: &GetStatement{
+							keys: []string{"tenantA:k1", "acl"},
+						},
+						Typ: GetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"ACL ADD STATEMENT WITHOUT PERMISSIONS",
+			args{`ACL ADD team tenantA:*;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: ACLType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"REGUSER STATEMENT WITH REPLACE",
+			args{`REGUSER user pass 2 REPLACE; REGUSER user pass replace;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						RegUserStatement: &RegUserStatement{username: "user", password: "pass", access: 2, replace: true},
+						Typ:              RegUserType,
+					},
+					{
+						RegUserStatement: &RegUserStatement{username: "user", password: "pass", replace: true},
+						Typ:              RegUserType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"USER STATEMENTS",
+			args{`DELUSER bob; PASSWD old new; PASSWD bob TO secret; SETACCESS bob 2; LISTUSERS; WHOAMI;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						UserStatement: &UserStatement{action: "deluser", username: "bob"},
+						Typ:           UserType,
+					},
+					{
+						UserStatement: &UserStatement{action: "passwd", oldPassword: "old", password: "new"},
+						Typ:           UserType,
+					},
+					{
+						UserStatement: &UserStatement{action: "passwd", username: "bob", password: "secret"},
+						Typ:           UserType,
+					},
+					{
+						UserStatement: &UserStatement{action: "setaccess", username: "bob", access: 2},
+						Typ:           UserType,
+					},
+					{
+						UserStatement: &UserStatement{action: "listusers"},
+						Typ:           UserType,
+					},
+					{
+						UserStatement: &UserStatement{action: "whoami"},
+						Typ:           UserType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"SETACCESS STATEMENT WITHOUT ACCESS LEVEL",
+			args{`SETACCESS bob;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: UserType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"PASSWD STATEMENT WITHOUT NEW PASSWORD",
+			args{`PASSWD bob TO;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: UserType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"LOCK STATEMENTS",
+			args{`LOCKS; UNLOCK user:bob; UNLOCK addr:127.0.0.1; UNLOCK *; GET locks unlock lockout;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						LockStatement: &LockStatement{action: "locks"},
+						Typ:           LockType,
+					},
+					{
+						LockStatement: &LockStatement{action: "unlock", pattern: "user:bob"},
+						Typ:           LockType,
+					},
+					{
+						LockStatement: &LockStatement{action: "unlock", pattern: "addr:127.0.0.1"},
+						Typ:           LockType,
+					},
+					{
+						LockStatement: &LockStatement{action: "unlock", pattern: "*"},
+						Typ:           LockType,
+					},
+					{
+						GetStatement: &GetStatement{keys: []string{"locks", "unlock", "lockout"}},
+						Typ:          GetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"UNLOCK STATEMENT WITHOUT PATTERN",
+			args{`UNLOCK;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: LockType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"TTL STATEMENTS",
+			args{`TTL data; PTTL data; PERSIST data;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						ExpireStatement: &ExpireStatement{action: "ttl", key: "data"},
+						Typ:             ExpireType,
+					},
+					{
+						ExpireStatement: &ExpireStatement{action: "pttl", key: "data"},
+						Typ:             ExpireType,
+					},
+					{
+						ExpireStatement: &ExpireStatement{action: "persist", key: "data"},
+						Typ:             ExpireType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"EXPIRE STATEMENTS",
+			args{`EXPIRE data 10s; EXPIRE data 500;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						ExpireStatement: &ExpireStatement{action: "expire", key: "data", expireIn: 10 * time.Second},
+						Typ:             ExpireType,
+					},
+					{
+						ExpireStatement: &ExpireStatement{action: "expire", key: "data", expireIn: 500 * time.Millisecond},
+						Typ:             ExpireType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"EXPIRY WORDS AS KEYS",
+			args{`GET ttl pttl; SET expire persist; TTL expireat;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						GetStatement: &GetStatement{keys: []string{"ttl", "pttl"}},
+						Typ:          GetType,
+					},
+					{
+						SetStatement: &SetStatement{key: "expire", val: "persist"},
+						Typ:          SetType,
+					},
+					{
+						ExpireStatement: &ExpireStatement{action: "ttl", key: "expireat"},
+						Typ:             ExpireType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"EXPIREAT STATEMENTS",
+			args{`EXPIREAT data 1700000000000; EXPIREAT data "2030-01-02T15:04:05Z";`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						ExpireStatement: &ExpireStatement{action: "expireat", key: "data", expireAt: time.Unix(1700000000, 0)},
+						Typ:             ExpireType,
+					},
+					{
+						ExpireStatement: &ExpireStatement{action: "expireat", key: "data", expireAt: time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)},
+						Typ:             ExpireType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"EXPIRE STATEMENT WITHOUT DURATION",
+			args{`EXPIRE data;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: ExpireType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"EXPIREAT STATEMENT WITH INVALID TIME",
+			args{`EXPIREAT data "tomorrow";`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: ExpireType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"TTL STATEMENT WITHOUT KEY",
+			args{`TTL;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: ExpireType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"COUNTER STATEMENTS",
+			args{`INCR data; DECR data; INCRBY data -5; INCRBYFLOAT data 0.25;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						CounterStatement: &CounterStatement{action: "incr", key: "data", by: 1},
+						Typ:              CounterType,
+					},
+					{
+						CounterStatement: &CounterStatement{action: "decr", key: "data", by: -1},
+						Typ:              CounterType,
+					},
+					{
+						CounterStatement: &CounterStatement{action: "incrby", key: "data", by: -5},
+						Typ:              CounterType,
+					},
+					{
+						CounterStatement: &CounterStatement{action: "incrbyfloat", key: "data", byFloat: 0.25},
+						Typ:              CounterType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"INCRBY STATEMENT WITH FLOAT",
+			args{`INCRBY data 1.5;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CounterType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"INCRBY STATEMENT WITHOUT INCREMENT",
+			args{`INCRBY data;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CounterType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"INCRBYFLOAT STATEMENT WITH STRING",
+			args{`INCRBYFLOAT data "1.5";`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CounterType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"INCR STATEMENT WITHOUT KEY",
+			args{`INCR;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CounterType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"VERSION STATEMENTS",
+			args{`GET data data1 WITH VERSION; CAS data 3 true EXPIREIN 1s; DEL data IF VERSION 4;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						GetStatement: &GetStatement{keys: []string{"data", "data1"}, withVersion: true},
+						Typ:          GetType,
+					},
+					{
+						CasStatement: &CasStatement{key: "data", version: 3, val: true, exp: 1000},
+						Typ:          CasType,
+					},
+					{
+						DeleteStatement: &DeleteStatement{keys: []string{"data"}, version: 4, checkVersion: true},
+						Typ:             DeleteType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"VERSION WORDS AS KEYS",
+			args{`GET data WITH; GET version; SET k with; CAS cas 1 version;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						GetStatement: &GetStatement{keys: []string{"data", "WITH"}},
+						Typ:          GetType,
+					},
+					{
+						GetStatement: &GetStatement{keys: []string{"version"}},
+						Typ:          GetType,
+					},
+					{
+						SetStatement: &SetStatement{key: "k", val: "with"},
+						Typ:          SetType,
+					},
+					{
+						CasStatement: &CasStatement{key: "cas", version: 1, val: "version"},
+						Typ:          CasType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"CAS STATEMENT WITHOUT VERSION",
+			args{`CAS data value;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CasType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"CAS STATEMENT WITH NEGATIVE VERSION",
+			args{`CAS data -1 value;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: CasType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"DEL STATEMENT WITH SEVERAL KEYS AND VERSION",
+			args{`DEL data data1 IF VERSION 1;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: DeleteType,
+					},
+				},
+			},
+			true,
+		},
+		{
+			"CONFIG STATEMENT WITHOUT ACTION",
+			args{`CONFIG;`},
+			&Ast{
+				Statements: []*Statement{
+					{
+						Typ: ConfigType,
+					},
+				},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.args.source)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	type args struct {
+		source string
+		args   []string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *Ast
+		wantErr bool
+	}{
+		{
+			"PLACEHOLDERS",
+			args{`SET ? ?; GET ? k2;`, []string{"multi\nline key", "\x00;\"", "k1"}},
+			&Ast{
+				Statements: []*Statement{
+					{
+						SetStatement: &SetStatement{
+							key: "multi\nline key",
+							val: "\x00;\"",
+						},
+						Typ: SetType,
+					},
+					{
+						GetStatement: &GetStatement{
+							keys: []string{"k1", "k2"},
+						},
+						Typ: GetType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"EMPTY ARGUMENT",
+			args{`AUTH admin ?;`, []string{""}},
+			&Ast{
+				Statements: []*Statement{
+					{
+						AuthStatement: &AuthStatement{
+							username: "admin",
+							password: "",
+						},
+						Typ: AuthType,
+					},
+				},
+			},
+			false,
+		},
+		{
+			"MISSING ARGUMENT",
+			args{`SET k1 ?;`, nil},
+			nil,
+			true,
+		},
+		{
+			"EXTRA ARGUMENT",
+			args{`SET k1 ?;`, []string{"v1", "v2"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseArgs(tt.args.source, tt.args.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseArgs() = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,119 @@
+package rql
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Codes of the errors which aren't returned by the database
+const (
+	// CodeParse is the code of the invalid queries
+	CodeParse = "PARSE"
+
+	// CodeUnknown is the code of the errors without a code
+	CodeUnknown = "ERR"
+)
+
+// Result is the typed result of a single statement of a query
+//
+// Statements like GET and DEL return the Values, in which the keys that
+// don't exist are nil. Statements like LISTUSERS, ROLES and CONFIG GET
+// return the Pairs, every other statement returns a Message
+type Result struct {
+	// Statement is the keyword of the statement like "GET" or "ACL ADD"
+	Statement string `json:"statement"`
+
+	// OK is false if the statement failed, the Error describes why
+	OK bool `json:"ok"`
+
+	Message string              `json:"message,omitempty"`
+	Values  []interface{}       `json:"values,omitempty"`
+	Pairs   map[string][]string `json:"pairs,omitempty"`
+	Error   *Error              `json:"error,omitempty"`
+
+	// Found tells for every key of a GET or of a DEL without a
+	// condition if it existed, the value of a missing key is nil
+	Found []bool `json:"found,omitempty"`
+
+	// err is the error returned by the database
+	err error
+}
+
+// Error describes why a statement failed
+type Error struct {
+	// Code identifies the error, it is either one of the codes
+	// of the database, CodeParse or CodeUnknown
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// coder is implemented by the errors of
+// the database which carry an error code
+type coder interface {
+	Code() string
+}
+
+// failed returns the result of the statement which failed with the error
+func failed(statement, code string, err error) Result {
+	var c coder
+	if code == "" {
+		code = CodeUnknown
+		if errors.As(err, &c) {
+			code = c.Code()
+		}
+	}
+
+	return Result{Statement: statement, Error: &Error{code, strings.TrimSpace(err.Error())}, err: err}
+}
+
+// Text returns the result as it is written in the text protocol
+func (r Result) Text() string {
+	switch {
+	case r.Values != nil:
+		values := make([]string, len(r.Values))
+		for i, v := range r.Values {
+			values[i] = stringify(v)
+		}
+		return "[" + strings.Join(values, " ") + "]"
+	case r.Pairs != nil:
+		return stringify(joinPairs(r.Pairs))
+	default:
+		return r.Message
+	}
+}
+
+// EncodeJSON encodes the results of a query as a single line JSON document
+// like {"results":[...]}. The values which cannot be encoded as JSON, like
+// the NaN floats stored by an embedding application, are sent as text
+func EncodeJSON(results []Result) string {
+	if results == nil {
+		results = []Result{}
+	}
+
+	doc := struct {
+		Results []Result `json:"results"`
+	}{results}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		doc.Results = make([]Result, len(results))
+		for i, r := range results {
+			if r.Values != nil {
+				values := make([]interface{}, len(r.Values))
+				for j, v := range r.Values {
+					if _, err := json.Marshal(v); err != nil {
+						v = stringify(v)
+					}
+					values[j] = v
+				}
+				r.Values = values
+			}
+			doc.Results[i] = r
+		}
+
+		b, _ = json.Marshal(doc)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,383 @@
+package rql
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// codedError is an error of the database which carries a code
+type codedError struct{ code, msg string }
+
+func (e codedError) Error() string { return e.msg }
+func (e codedError) Code() string  { return e.code }
+
+// MockDB implements the operations used by the tests, the
+// other operations of the embedded SecureDB are never called
+type MockDB struct {
+	SecureDB
+	data map[string]interface{}
+}
+
+func (db *MockDB) Set(key string, data interface{}, expireIn time.Duration) error {
+	db.data[key] = data
+	return nil
+}
+
+func (db *MockDB) SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) (bool, error) {
+	if !cond(db.get) {
+		return false, nil
+	}
+
+	return true, db.Set(key, data, expireIn)
+}
+
+func (db *MockDB) Get(key string) (interface{}, bool, error) {
+	v, ok := db.get(key)
+	return v, ok, nil
+}
+
+func (db *MockDB) Delete(key string) (interface{}, bool, error) {
+	v, ok := db.get(key)
+	delete(db.data, key)
+	return v, ok, nil
+}
+
+func (db *MockDB) DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool, error) {
+	if !cond(db.get) {
+		return nil, false, nil
+	}
+
+	deleted := make([]interface{}, len(keys))
+	for i, key := range keys {
+		deleted[i] = db.data[key]
+		delete(db.data, key)
+	}
+
+	return deleted, true, nil
+}
+
+func (db *MockDB) ExpireAt(key string) (time.Time, bool, error) {
+	_, ok := db.data[key]
+	return time.Time{}, ok, nil
+}
+
+func (db *MockDB) SetExpireAt(key string, expireAt time.Time) (bool, error) {
+	_, ok := db.data[key]
+	return ok, nil
+}
+
+// GetVersion returns the version 1 for every key which exists
+func (db *MockDB) GetVersion(key string) (interface{}, uint64, bool, error) {
+	v, ok := db.get(key)
+	if !ok {
+		return nil, 0, false, nil
+	}
+
+	return v, 1, true, nil
+}
+
+func (db *MockDB) SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool, error) {
+	if _, v, _, _ := db.GetVersion(key); v != version {
+		return 0, false, nil
+	}
+
+	return 1, true, db.Set(key, data, expireIn)
+}
+
+func (db *MockDB) DeleteIfVersion(key string, version uint64) (interface{}, bool, error) {
+	v, current, _, _ := db.GetVersion(key)
+	if current != version {
+		return nil, false, nil
+	}
+
+	delete(db.data, key)
+	return v, true, nil
+}
+
+func (db *MockDB) Incr(key string, delta int64) (int64, error) {
+	n, ok := db.data[key].(int64)
+	if _, exists := db.data[key]; exists && !ok {
+		return 0, codedError{"NOTNUMBER", "Value is not an integer"}
+	}
+
+	db.data[key] = n + delta
+	return n + delta, nil
+}
+
+func (db *MockDB) IncrFloat(key string, delta float64) (float64, error) {
+	f, _ := db.data[key].(float64)
+	db.data[key] = f + delta
+	return f + delta, nil
+}
+
+func (db *MockDB) get(key string) (interface{}, bool) {
+	v, ok := db.data[key]
+	return v, ok
+}
+
+func (db *MockDB) Wipe() error {
+	return codedError{"DENIED", "Access denied"}
+}
+
+func (db *MockDB) Users() (map[string][]string, error) {
+	return nil, nil
+}
+
+func (db *MockDB) ConfigGet(pattern string) (map[string]string, error) {
+	return map[string]string{"fsync": "always"}, nil
+}
+
+func TestDriver_Execute(t *testing.T) {
+	d := New(&MockDB{data: map[string]interface{}{"k1": "<nil>", "k2": ""}})
+
+	tests := []struct {
+		name  string
+		query string
+		want  []Result
+		text  string
+	}{
+		{
+			"MISSING AND EMPTY VALUES",
+			"GET k1 k2 k3;",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{"<nil>", "", nil}, Found: []bool{true, true, false}}},
+			"[<nil>  <nil>]",
+		},
+		{
+			"MULTIPLE STATEMENTS",
+			`SET k4 12; CONFIG GET fsync;`,
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "CONFIG GET", OK: true, Pairs: map[string][]string{"fsync": {"always"}}},
+			},
+			"Success\n[fsync=always]",
+		},
+		{
+			"EMPTY PAIRS",
+			"LISTUSERS;",
+			[]Result{{Statement: "LISTUSERS", OK: true, Pairs: map[string][]string{}}},
+			"[]",
+		},
+		{
+			"EXECUTION STOPS AT THE FAILED STATEMENT",
+			"SET k5 1; WIPE; SET k6 1;",
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "WIPE", Error: &Error{Code: "DENIED"}},
+			},
+			"Success",
+		},
+		{
+			"CONDITIONS",
+			`SET k7 1 IF EXISTS(k1) AND k2 == ""; GET k7; SET k8 1 IF k7 > 1;`,
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "GET", OK: true, Values: []interface{}{int64(1)}, Found: []bool{true}},
+				{Statement: "SET", OK: true, Message: "Condition not met"},
+			},
+			"Success\n[1]\nCondition not met",
+		},
+		{
+			"TYPED VALUES",
+			`SET k11 {"a": [1, 2.5]}; SET k12 -3; SET k13 TRUE; SET k14 null; GET k11 k12 k13 k14; SET k15 1 IF k11 == {"a":[1,2.5]} AND k13 == true;`,
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "GET", OK: true, Values: []interface{}{
+					map[string]interface{}{"a": []interface{}{int64(1), 2.5}}, int64(-3), true, nil,
+				}, Found: []bool{true, true, true, true}},
+				{Statement: "SET", OK: true, Message: "Success"},
+			},
+			"Success\nSuccess\nSuccess\nSuccess\n[{\"a\":[1,2.5]} -3 true <nil>]\nSuccess",
+		},
+		{
+			"DELETE",
+			`SET k16 1; DEL k16 missing;`,
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "DEL", OK: true, Values: []interface{}{int64(1), nil}, Found: []bool{true, false}},
+			},
+			"Success\n[1 <nil>]",
+		},
+		{
+			"CONDITIONAL DELETE",
+			`DEL k9 k10 IF EXISTS(missing); DEL k9 k10 IF EXISTS(k1);`,
+			[]Result{
+				{Statement: "DEL", OK: true, Message: "Condition not met"},
+				{Statement: "DEL", OK: true, Values: []interface{}{nil, nil}},
+			},
+			"Condition not met\n[<nil> <nil>]",
+		},
+		{
+			"EXPIRY",
+			`TTL k1; PTTL missing; EXPIRE k1 10s; PERSIST missing;`,
+			[]Result{
+				{Statement: "TTL", OK: true, Values: []interface{}{int64(-1)}},
+				{Statement: "PTTL", OK: true, Values: []interface{}{int64(-2)}},
+				{Statement: "EXPIRE", OK: true, Values: []interface{}{true}},
+				{Statement: "PERSIST", OK: true, Values: []interface{}{false}},
+			},
+			"[-1]\n[-2]\n[true]\n[false]",
+		},
+		{
+			"COUNTERS",
+			`INCR c1; INCRBY c1 10; DECR c1; INCRBY c1 -10; INCRBYFLOAT c2 1.5; INCRBYFLOAT c2 -1.5; INCR k2;`,
+			[]Result{
+				{Statement: "INCR", OK: true, Values: []interface{}{int64(1)}},
+				{Statement: "INCRBY", OK: true, Values: []interface{}{int64(11)}},
+				{Statement: "DECR", OK: true, Values: []interface{}{int64(10)}},
+				{Statement: "INCRBY", OK: true, Values: []interface{}{int64(0)}},
+				{Statement: "INCRBYFLOAT", OK: true, Values: []interface{}{1.5}},
+				{Statement: "INCRBYFLOAT", OK: true, Values: []interface{}{0.0}},
+				{Statement: "INCR", Error: &Error{Code: "NOTNUMBER"}},
+			},
+			"[1]\n[11]\n[10]\n[0]\n[1.5]\n[0]",
+		},
+		{
+			"VERSIONS",
+			`GET k1 missing WITH VERSION; CAS k20 0 1; CAS k20 0 2; DEL k20 IF VERSION 2; DEL k20 IF VERSION 1;`,
+			[]Result{
+				{Statement: "GET", OK: true, Values: []interface{}{"<nil>", int64(1), nil, int64(0)}, Found: []bool{true, false}},
+				{Statement: "CAS", OK: true, Values: []interface{}{int64(1)}},
+				{Statement: "CAS", OK: true, Message: "Condition not met"},
+				{Statement: "DEL", OK: true, Message: "Condition not met"},
+				{Statement: "DEL", OK: true, Values: []interface{}{int64(1)}},
+			},
+			"[<nil> 1 <nil> 0]\n[1]\nCondition not met\nCondition not met\n[1]",
+		},
+		{
+			"VERSION AS KEY",
+			`SET version 2; DEL version IF version == 2;`,
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "DEL", OK: true, Values: []interface{}{int64(2)}},
+			},
+			"Success\n[2]",
+		},
+		{
+			"INVALID QUERY",
+			"SET k1;",
+			[]Result{{Error: &Error{Code: CodeParse}}},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the codes of the errors are compared
+			got := d.Execute(tt.query)
+			for i := range got {
+				got[i].err = nil
+				if got[i].Error != nil {
+					got[i].Error.Message = ""
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Driver.Execute() = %+v, want %+v", got, tt.want)
+			}
+
+			if text, _ := d.Operate(tt.query); text != tt.text {
+				t.Errorf("Driver.Operate() = %q, want %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{
+			"NO RESULTS",
+			nil,
+			`{"results":[]}`,
+		},
+		{
+			"VALUES",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{int64(1), "", nil}}},
+			`{"results":[{"statement":"GET","ok":true,"values":[1,"",null]}]}`,
+		},
+		{
+			"FOUND KEYS",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{int64(1), nil}, Found: []bool{true, false}}},
+			`{"results":[{"statement":"GET","ok":true,"values":[1,null],"found":[true,false]}]}`,
+		},
+		{
+			"VALUES WHICH CANNOT BE ENCODED",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{math.NaN(), 1.5}}},
+			`{"results":[{"statement":"GET","ok":true,"values":["NaN",1.5]}]}`,
+		},
+		{
+			"ERROR",
+			[]Result{failed("WIPE", "", errors.New("Disk full"))},
+			`{"results":[{"statement":"WIPE","ok":false,"error":{"code":"ERR","message":"Disk full"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeJSON(tt.results); got != tt.want {
+				t.Errorf("EncodeJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+	}{
+		{
+			"NO RESULTS",
+			nil,
+		},
+		{
+			"VALUES",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{"\x00\n\xff", int64(-1), 1.5, true, nil}}},
+		},
+		{
+			"FOUND KEYS",
+			[]Result{{Statement: "DEL", OK: true, Values: []interface{}{"v", nil}, Found: []bool{true, false}}},
+		},
+		{
+			"JSON VALUES",
+			[]Result{{Statement: "GET", OK: true, Values: []interface{}{
+				map[string]interface{}{"a": []interface{}{int64(1), 2.5, nil}, "b": "<c>"},
+				[]interface{}{},
+			}}},
+		},
+		{
+			"PAIRS AND MESSAGES",
+			[]Result{
+				{Statement: "SET", OK: true, Message: "Success"},
+				{Statement: "LISTUSERS", OK: true, Pairs: map[string][]string{"admin": {"admin"}, "bob": {}}},
+			},
+		},
+		{
+			"ERROR",
+			[]Result{{Statement: "WIPE", Error: &Error{Code: "DENIED", Message: "Access denied"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeBinary(EncodeBinary(tt.results))
+			if err != nil {
+				t.Fatalf("DecodeBinary() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.results) {
+				t.Errorf("DecodeBinary() = %+v, want %+v", got, tt.results)
+			}
+		})
+	}
+
+	// Truncated results are rejected
+	b := EncodeBinary([]Result{{Statement: "SET", OK: true, Message: "Success"}})
+	if _, err := DecodeBinary(b[:len(b)-1]); err != ErrMalformed {
+		t.Errorf("DecodeBinary() error = %v, want %v", err, ErrMalformed)
+	}
+}
@@ -0,0 +1,85 @@
+package rql
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// literalValue returns the typed value represented by the token
+//
+// The numbers without a fraction or an exponent are int64 and the
+// other numbers are float64, an integer out of the int64 range is an
+// error. The JSON objects and arrays are decoded into
+// map[string]interface{} and []interface{}, the identifiers and the
+// strings are kept as strings
+func literalValue(t *token) (interface{}, error) {
+	switch t.typ {
+	case numericType:
+		if !strings.ContainsAny(t.val, ".eE") {
+			return strconv.ParseInt(t.val, 10, 64)
+		}
+		return strconv.ParseFloat(t.val, 64)
+	case boolType:
+		return strings.ToLower(t.val) == trueLiteral, nil
+	case nullType:
+		return nil, nil
+	case jsonType:
+		return decodeJSON(t.val)
+	}
+
+	return t.val, nil
+}
+
+// decodeJSON decodes the JSON document, its numbers are
+// typed like the numbers of the queries
+func decodeJSON(s string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return typeNumbers(v)
+}
+
+// typeNumbers replaces the json.Number values of the
+// decoded document by int64 or float64 values
+func typeNumbers(v interface{}) (interface{}, error) {
+	var err error
+
+	switch v := v.(type) {
+	case json.Number:
+		return literalValue(&token{val: v.String(), typ: numericType})
+	case map[string]interface{}:
+		for k, e := range v {
+			if v[k], err = typeNumbers(e); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			if v[i], err = typeNumbers(e); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// encodeJSON returns the compact JSON text of the document
+func encodeJSON(v interface{}) string {
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return ""
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// valueTag identifies the type of the value stored in an entry
+type valueTag uint8
+
+const (
+	tagNil valueTag = iota
+	tagString
+	tagBool
+	tagInt
+	tagInt64
+	tagUint
+	tagUint64
+	tagFloat64
+	tagBytes
+	// tagGob is used for every other type. Such types
+	// must be registered via RegisterType
+	tagGob
+)
+
+const (
+	// snapshotVersion is the version of the binary snapshot format
+	// written by the store. The version 1 snapshots, whose items
+	// aren't versioned, are still read
+	snapshotVersion uint16 = 2
+
+	// snapshotHeaderSize is the size of the magic, the format
+	// version, the number of entries and the last version given
+	// to an item in a snapshot
+	snapshotHeaderSize = 22
+
+	// snapshotV1HeaderSize is the size of the header of the version
+	// 1 snapshots, which don't hold the last version of the items
+	snapshotV1HeaderSize = 14
+
+	// snapshotCRCSize is the size of the trailing checksum
+	snapshotCRCSize = 4
+)
+
+// snapshotMagic marks the start of a binary snapshot
+var snapshotMagic = []byte("RPDB")
+
+// byteReader is the reader expected by the decoder
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func init() {
+	// Decoded JSON documents are made up of these
+	RegisterType(map[string]interface{}{})
+	RegisterType([]interface{}{})
+}
+
+// RegisterType registers the concrete type of the passed value so
+// that the values of that type can be persisted by the store and
+// come back with the same type when loaded
+//
+// Only the types which are not natively supported by the store have
+// to be registered. Natively supported types are nil, string, bool,
+// int, int64, uint, uint64, float64 and []byte
+func RegisterType(value interface{}) {
+	gob.Register(value)
+}
+
+// encodeSnapshot encodes the data in the binary snapshot format,
+// version is the last version given to an item by the store
+//
+// The format consists of the magic, the format version, the number
+// of entries and the last version of the items followed by the
+// entries themselves. The snapshot ends with the CRC32 of
+// everything before it
+func encodeSnapshot(data map[string]Item, version uint64) ([]byte, error) {
+	b := make([]byte, snapshotHeaderSize, snapshotHeaderSize+64*len(data))
+	copy(b, snapshotMagic)
+	binary.BigEndian.PutUint16(b[4:6], snapshotVersion)
+	binary.BigEndian.PutUint64(b[6:14], uint64(len(data)))
+	binary.BigEndian.PutUint64(b[14:22], version)
+
+	var err error
+	for key, item := range data {
+		if b, err = appendEntry(b, key, item); err != nil {
+			return nil, err
+		}
+	}
+
+	crc := make([]byte, snapshotCRCSize)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(b))
+
+	return append(b, crc...), nil
+}
+
+// isBinarySnapshot returns true if the passed bytes
+// start like a binary snapshot
+func isBinarySnapshot(b []byte) bool {
+	return len(b) >= len(snapshotMagic) && bytes.Equal(b[:len(snapshotMagic)], snapshotMagic)
+}
+
+// decodeSnapshot verifies and decodes a binary snapshot, it returns
+// the data and the last version given to an item by the store
+func decodeSnapshot(b []byte) (map[string]Item, uint64, error) {
+	if len(b) < snapshotV1HeaderSize+snapshotCRCSize || !isBinarySnapshot(b) {
+		return nil, 0, errors.New("Snapshot is too short")
+	}
+
+	n := len(b) - snapshotCRCSize
+	if crc32.ChecksumIEEE(b[:n]) != binary.BigEndian.Uint32(b[n:]) {
+		return nil, 0, errors.New("Snapshot checksum mismatch")
+	}
+
+	var version uint64
+	headerSize := snapshotV1HeaderSize
+
+	switch v := binary.BigEndian.Uint16(b[4:6]); v {
+	case 1:
+		// The items of the version 1 snapshots aren't versioned
+	case snapshotVersion:
+		if n < snapshotHeaderSize {
+			return nil, 0, errors.New("Snapshot is too short")
+		}
+		version = binary.BigEndian.Uint64(b[14:22])
+		headerSize = snapshotHeaderSize
+	default:
+		return nil, 0, fmt.Errorf("Unsupported snapshot version %d", v)
+	}
+
+	count := binary.BigEndian.Uint64(b[6:14])
+	data := make(map[string]Item, count)
+
+	r := bytes.NewReader(b[headerSize:n])
+	for i := uint64(0); i < count; i++ {
+		key, item, err := readEntry(r, headerSize == snapshotHeaderSize)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid entry %d: %w", i, err)
+		}
+		data[key] = item
+	}
+
+	if r.Len() != 0 {
+		return nil, 0, errors.New("Unexpected data after the last entry")
+	}
+
+	return data, version, nil
+}
+
+// appendEntry appends the encoded entry to the passed bytes. An
+// entry consists of the type tag of the value, the length prefixed
+// key, the ExpireAt, the Version and the length prefixed encoded value
+func appendEntry(b []byte, key string, item Item) ([]byte, error) {
+	tag, payload, err := encodeValue(item.Data)
+	if err != nil {
+		return b, fmt.Errorf("Cannot encode value of %s: %w", key, err)
+	}
+
+	var scratch [binary.MaxVarintLen64]byte
+
+	b = append(b, byte(tag))
+
+	n := binary.PutUvarint(scratch[:], uint64(len(key)))
+	b = append(b, scratch[:n]...)
+	b = append(b, key...)
+
+	var exp [8]byte
+	binary.BigEndian.PutUint64(exp[:], uint64(item.ExpireAt))
+	b = append(b, exp[:]...)
+
+	var version [8]byte
+	binary.BigEndian.PutUint64(version[:], item.Version)
+	b = append(b, version[:]...)
+
+	n = binary.PutUvarint(scratch[:], uint64(len(payload)))
+	b = append(b, scratch[:n]...)
+	b = append(b, payload...)
+
+	return b, nil
+}
+
+// readEntry reads an entry written by appendEntry, the entries
+// written before the items were versioned carry no version
+func readEntry(r byteReader, versioned bool) (string, Item, error) {
+	var item Item
+
+	tag, err := r.ReadByte()
+	if err != nil {
+		return "", item, err
+	}
+
+	key, err := readPrefixed(r)
+	if err != nil {
+		return "", item, err
+	}
+
+	var exp [8]byte
+	if _, err := io.ReadFull(r, exp[:]); err != nil {
+		return "", item, err
+	}
+	item.ExpireAt = int64(binary.BigEndian.Uint64(exp[:]))
+
+	if versioned {
+		var version [8]byte
+		if _, err := io.ReadFull(r, version[:]); err != nil {
+			return "", item, err
+		}
+		item.Version = binary.BigEndian.Uint64(version[:])
+	}
+
+	payload, err := readPrefixed(r)
+	if err != nil {
+		return "", item, err
+	}
+
+	if item.Data, err = decodeValue(valueTag(tag), payload); err != nil {
+		return "", item, err
+	}
+
+	return string(key), item, nil
+}
+
+// readPrefixed reads a length prefixed chunk of bytes
+func readPrefixed(r byteReader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// encodeValue returns the type tag and the encoded bytes of the value
+func encodeValue(v interface{}) (valueTag, []byte, error) {
+	var b [8]byte
+
+	switch v := v.(type) {
+	case nil:
+		return tagNil, nil, nil
+	case string:
+		return tagString, []byte(v), nil
+	case []byte:
+		return tagBytes, v, nil
+	case bool:
+		if v {
+			return tagBool, []byte{1}, nil
+		}
+		return tagBool, []byte{0}, nil
+	case int:
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		return tagInt, b[:], nil
+	case int64:
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		return tagInt64, b[:], nil
+	case uint:
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		return tagUint, b[:], nil
+	case uint64:
+		binary.BigEndian.PutUint64(b[:], v)
+		return tagUint64, b[:], nil
+	case float64:
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		return tagFloat64, b[:], nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return tagNil, nil, err
+	}
+
+	return tagGob, buf.Bytes(), nil
+}
+
+// decodeValue decodes the bytes encoded by encodeValue
+func decodeValue(tag valueTag, b []byte) (interface{}, error) {
+	switch tag {
+	case tagNil:
+		return nil, nil
+	case tagString:
+		return string(b), nil
+	case tagBytes:
+		return b, nil
+	case tagBool:
+		if len(b) != 1 {
+			return nil, errors.New("Invalid bool value")
+		}
+		return b[0] == 1, nil
+	case tagGob:
+		var v interface{}
+		if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+
+	if len(b) != 8 {
+		return nil, fmt.Errorf("Invalid value of type %d", tag)
+	}
+	u := binary.BigEndian.Uint64(b)
+
+	switch tag {
+	case tagInt:
+		return int(u), nil
+	case tagInt64:
+		return int64(u), nil
+	case tagUint:
+		return uint(u), nil
+	case tagUint64:
+		return u, nil
+	case tagFloat64:
+		return math.Float64frombits(u), nil
+	}
+
+	return nil, fmt.Errorf("Unknown value type %d", tag)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+type codecTestUser struct {
+	Username string
+	Access   uint
+	Events   []uint
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	RegisterType(codecTestUser{})
+
+	data := map[string]Item{
+		"nil":     {0, nil, 1},
+		"string":  {0, "Hello World", 2},
+		"bool":    {0, true, 3},
+		"int":     {0, -42, 4},
+		"int64":   {1234, int64(1) << 40, 5},
+		"uint":    {0, uint(5), 6},
+		"uint64":  {0, uint64(1) << 63, 7},
+		"float64": {0, 345.0983, 8},
+		"bytes":   {0, []byte{0, 1, '\n', 255}, 9},
+		"user":    {0, codecTestUser{"utkarsh", 5, []uint{1, 2}}, 10},
+		"json":    {0, map[string]interface{}{"a": []interface{}{1.5, "b"}}, 11},
+		"typed":   {0, map[string]interface{}{"a": []interface{}{int64(-1), nil, true}, "b": map[string]interface{}{"c": nil}}, 12},
+		"array":   {0, []interface{}{int64(1), map[string]interface{}{}}, 13},
+	}
+
+	b, err := encodeSnapshot(data, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, version, err := load(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, data) || version != 42 {
+		t.Errorf("load() = %v, %d, want %v, 42", got, version, data)
+	}
+
+	// Flip a bit in the middle of the snapshot
+	b[len(b)/2] ^= 1
+	if _, _, err := load(bytes.NewReader(b)); err == nil {
+		t.Error("load() accepted a corrupted snapshot")
+	}
+}
+
+func TestLoadJSONSnapshot(t *testing.T) {
+	src := `{"k1":{"ExpireAt":0,"Data":"v1"},"k2":{"ExpireAt":10,"Data":12}}`
+
+	got, _, err := load(bytes.NewReader([]byte(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Item{"k1": {0, "v1", 0}, "k2": {10, float64(12), 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("load() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadV1Snapshot(t *testing.T) {
+	// A version 1 snapshot holding k1 = "v1" which never expires
+	b := append([]byte("RPDB"), 0, 1, 0, 0, 0, 0, 0, 0, 0, 1)
+	b = append(b, byte(tagString), 2, 'k', '1', 0, 0, 0, 0, 0, 0, 0, 0, 2, 'v', '1')
+
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(b))
+	b = append(b, crc...)
+
+	got, version, err := load(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Item{"k1": {0, "v1", 0}}
+	if !reflect.DeepEqual(got, want) || version != 0 {
+		t.Errorf("load() = %v, %d, want %v, 0", got, version, want)
+	}
+}
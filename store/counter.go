@@ -0,0 +1,125 @@
+package store
+
+import (
+	"math"
+	"strconv"
+)
+
+// Incr adds delta to the integer stored against the key and returns
+// the result, which is stored as an int64. A key which doesn't exist
+// counts from 0 and gets the default expiry of the store, an existing
+// key keeps its expiry
+//
+// Incr returns ErrNotInteger if the key holds something else than an
+// integer or a string representing one, and ErrOverflow if the result
+// doesn't fit in an int64
+func (store *Store) Incr(key string, delta int64) (int64, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		item = newItem(int64(0), store.expiry(NeverExpire))
+	}
+
+	n, ok := toInt64(item.Data)
+	if !ok {
+		return 0, ErrNotInteger
+	}
+
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+
+	item.Data = n + delta
+	item = store.stamp(item)
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
+
+	return n + delta, nil
+}
+
+// IncrFloat is like Incr except that delta is added to the number
+// stored against the key, an integer or a float, and that the result
+// is stored as a float64
+//
+// IncrFloat returns ErrNotNumber if the key holds something else than
+// a number or a string representing one, and ErrOverflow if the result
+// is infinite or not a number
+func (store *Store) IncrFloat(key string, delta float64) (float64, error) {
+	store.Lock()
+	defer store.Unlock()
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		item = newItem(float64(0), store.expiry(NeverExpire))
+	}
+
+	f, ok := toFloat64(item.Data)
+	if !ok {
+		return 0, ErrNotNumber
+	}
+
+	res := f + delta
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return 0, ErrOverflow
+	}
+
+	item.Data = res
+	item = store.stamp(item)
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
+
+	return res, nil
+}
+
+// toInt64 returns the integer held by the value
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint:
+		return int64(v), uint64(v) <= math.MaxInt64
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+// toFloat64 returns the number held by the value
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case float32:
+		return toFloat64(float64(v))
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+
+	n, ok := toInt64(v)
+	return float64(n), ok
+}
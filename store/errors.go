@@ -0,0 +1,37 @@
+package store
+
+// Codes of the errors returned by the store. Unlike the
+// messages of the errors, the clients can rely upon them
+const (
+	CodeNotNumber = "NOTNUMBER"
+	CodeOverflow  = "OVERFLOW"
+)
+
+// Error is an error of the store which carries a code
+type Error struct {
+	code string
+	msg  string
+}
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Code returns the code of the error
+func (e *Error) Code() string {
+	return e.code
+}
+
+var (
+	// ErrNotInteger is returned when an integer counter
+	// is stored against a key holding another value
+	ErrNotInteger = &Error{CodeNotNumber, "Value is not an integer"}
+
+	// ErrNotNumber is returned when a float counter is
+	// stored against a key holding another value
+	ErrNotNumber = &Error{CodeNotNumber, "Value is not a number"}
+
+	// ErrOverflow is returned when a counter would overflow
+	ErrOverflow = &Error{CodeOverflow, "Increment or decrement would overflow"}
+)
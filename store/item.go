@@ -13,6 +13,11 @@ type Item struct {
 
 	// Data can be anything of any type
 	Data interface{}
+
+	// Version changes whenever the item is written, the versions
+	// given by a store only ever increase. It is 0 for the items
+	// which haven't been stored yet
+	Version uint64
 }
 
 // newItem returns a new item that can be stored in the database
@@ -31,7 +36,7 @@ func newItem(data interface{}, expireIn time.Duration) Item {
 		expiry = time.Now().Add(expireIn).UnixNano()
 	}
 
-	return Item{expiry, data}
+	return Item{ExpireAt: expiry, Data: data}
 }
 
 // isExpired returns true if an item is expired
@@ -42,3 +47,13 @@ func (item Item) isExpired() bool {
 
 	return item.ExpireAt < time.Now().UnixNano()
 }
+
+// expireTime returns the time when the item expires, it
+// is the zero time if the item never expires
+func (item Item) expireTime() time.Time {
+	if item.ExpireAt == NeverExpire {
+		return time.Time{}
+	}
+
+	return time.Unix(0, item.ExpireAt)
+}
@@ -1,15 +1,19 @@
 package store
 
 import (
+	"errors"
 	"runtime"
+	"sync"
 	"time"
 )
 
 // janitor is responsible for cleaning up the
 // expired items at a regular interval
 type janitor struct {
+	mu       sync.Mutex
 	interval time.Duration
 	sigStop  chan bool
+	sigReset chan time.Duration
 }
 
 // newJanitor creates a new janitor and sets the interval,
@@ -18,6 +22,7 @@ func newJanitor(interval time.Duration) *janitor {
 	return &janitor{
 		interval: interval,
 		sigStop:  make(chan bool),
+		sigReset: make(chan time.Duration),
 	}
 }
 
@@ -30,6 +35,9 @@ func (j *janitor) run(store *Store) {
 		select {
 		case <-ticker.C:
 			store.DeleteExpired()
+		case interval := <-j.sigReset:
+			ticker.Stop()
+			ticker = time.NewTicker(interval)
 		case <-j.sigStop:
 			ticker.Stop()
 			return
@@ -37,6 +45,38 @@ func (j *janitor) run(store *Store) {
 	}
 }
 
+// JanitorInterval returns the interval at which the janitor runs
+func (store *Store) JanitorInterval() time.Duration {
+	store.janitor.mu.Lock()
+	defer store.janitor.mu.Unlock()
+
+	return store.janitor.interval
+}
+
+// SetJanitorInterval changes the interval at which the janitor runs.
+// The janitor of a store created without one cannot be enabled later
+func (store *Store) SetJanitorInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("Janitor interval must be greater than 0")
+	}
+
+	j := store.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.interval <= 0 {
+		return errors.New("Janitor is disabled for this store")
+	}
+
+	select {
+	case j.sigReset <- interval:
+		j.interval = interval
+		return nil
+	case <-j.sigStop:
+		return errors.New("Store has been closed")
+	}
+}
+
 // setupJanitor takes in the store and sets up a janitor for that
 // store. It also adds a finalizer function to stop the janitor
 // whenever required
@@ -52,9 +92,9 @@ func runJanitor(store *Store) {
 	go store.janitor.run(store)
 }
 
-// stopJanitor stops the janitor by sending a stop signal
-// to the janitor. This function is intended to be used by
-// the go runtime as a finalizer function
+// stopJanitor stops the janitor by closing the stop signal
+// channel of the janitor. This function is intended to be used by
+// the go runtime as a finalizer function and by Close
 func stopJanitor(store *Store) {
-	store.janitor.sigStop <- true
+	close(store.janitor.sigStop)
 }
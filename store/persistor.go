@@ -1,23 +1,79 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 type persistor struct {
-	interval time.Duration
-	bckup    string
-	sigStop  chan bool
+	// mu makes sure that only one snapshot
+	// is being written at a time
+	mu sync.Mutex
+
+	// intervalMu guards the interval
+	intervalMu sync.Mutex
+	interval   time.Duration
+	bckup      string
+	sigStop    chan bool
+	sigReset   chan time.Duration
 }
 
 // newPersistor returns a pointer to a new instance of the persistor
 func newPersistor(interval time.Duration, bckup string) *persistor {
-	return &persistor{interval, bckup, make(chan bool)}
+	return &persistor{
+		interval: interval,
+		bckup:    bckup,
+		sigStop:  make(chan bool),
+		sigReset: make(chan time.Duration),
+	}
+}
+
+// PersistorInterval returns the interval at which the data is persisted
+func (store *Store) PersistorInterval() time.Duration {
+	store.persistor.intervalMu.Lock()
+	defer store.persistor.intervalMu.Unlock()
+
+	return store.persistor.interval
+}
+
+// SetPersistorInterval changes the interval at which the data is persisted.
+// The persistor of a store created without one cannot be enabled later
+func (store *Store) SetPersistorInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("Persistor interval must be greater than 0")
+	}
+
+	p := store.persistor
+	p.intervalMu.Lock()
+	defer p.intervalMu.Unlock()
+
+	if p.interval <= 0 {
+		return errors.New("Persistor is disabled for this store")
+	}
+
+	// Without a backup location the persistor
+	// stops right after its first tick
+	if p.bckup == "" {
+		p.interval = interval
+		return nil
+	}
+
+	select {
+	case p.sigReset <- interval:
+		p.interval = interval
+		return nil
+	case <-p.sigStop:
+		return errors.New("Store has been closed")
+	}
 }
 
 // setupPersistor sets up the persistor and a mechanism to
@@ -33,21 +89,52 @@ func setupPersistor(store *Store) {
 // make it non blocking while the retrieveData method is started
 // up on the main thread to block the startup until the data has
 // loaded into the memory
+//
+// The snapshot is only considered complete once the write-ahead
+// log kept next to it has been replayed on top of it
 func runPersistor(store *Store) {
-	// Run the persistor in a goroutine
-	go store.persistor.persist(store)
-
 	// Load the data in the main thread
 	store.persistor.retrieve(store)
+
+	// Replay the mutations which happened after the snapshot
+	setupWAL(store)
+
+	// Version the items loaded from the formats without versions
+	store.versionLegacyItems()
+
+	// Run the persistor in a goroutine
+	go store.persistor.persist(store)
 }
 
-// stopPersistor simply sends a stop signal to the persistor
-// func stopPersistor(store *Store) {
-// 	store.persistor.sigStop <- true
-// }
+// stopPersistor stops the persistor and writes a final snapshot
+// of the data so that nothing is left only in the write-ahead log
+func stopPersistor(store *Store) error {
+	close(store.persistor.sigStop)
+
+	if store.persistor.interval <= 0 || store.persistor.bckup == "" {
+		return nil
+	}
+
+	llog(store.log, "Writing final snapshot to", store.persistor.bckup)
+
+	err := store.persistor.snapshot(store)
+	if err == nil {
+		err = store.wal.discardRotated()
+	}
+
+	if cerr := store.wal.close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
 
 // persist stores the data onto the disk at regular
 // intervals
+//
+// A failed snapshot never touches the previously written ones
+// hence the persistor just logs the failure and tries again
+// at the next tick
 func (p *persistor) persist(store *Store) error {
 	ticker := time.NewTicker(p.interval)
 	for {
@@ -63,22 +150,17 @@ func (p *persistor) persist(store *Store) error {
 				return nil
 			}
 
-			osf, err := os.Create(p.bckup)
-			if err != nil {
-				llog(store.log, err)
-				return err
+			if err := p.snapshot(store); err != nil {
+				llog(store.log, "Failed to persist the data:", err)
+				continue
 			}
 
-			if err = save(store, osf); err != nil {
-				llog(store.log, err)
-				osf.Close()
-				return err
-			}
+			// The snapshot now covers the rotated log
+			store.logErr(store.wal.discardRotated())
 
-			if err = osf.Close(); err != nil {
-				llog(store.log, err)
-				return err
-			}
+		case interval := <-p.sigReset:
+			ticker.Stop()
+			ticker = time.NewTicker(interval)
 
 		case <-p.sigStop:
 			ticker.Stop()
@@ -87,40 +169,110 @@ func (p *persistor) persist(store *Store) error {
 	}
 }
 
+// snapshot writes the data of the store onto the disk. The data is
+// first written to a temporary file which is flushed and then renamed
+// over the backup file, so a crash never leaves a partially written
+// backup behind. The replaced backup is retained as the previous
+// generation
+func (p *persistor) snapshot(store *Store) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tmp := p.bckup + ".tmp"
+
+	osf, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err = save(store, osf); err == nil {
+		err = osf.Sync()
+	}
+	if cerr := osf.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// Retain the current generation as the previous one
+	if err := os.Rename(p.bckup, p.prevPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Rename(tmp, p.bckup); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(p.bckup))
+}
+
 // retrieve retreives data from the disk and
 // store in the main memory of the program
+//
+// If the backup file is corrupted then the previous generation
+// is used instead. The data in the memory is never replaced with
+// the contents of a corrupted file
 func (p *persistor) retrieve(store *Store) error {
 	if p.bckup == "" {
 		llog(store.log, "No backup location provided, skipping data retrieval")
 		return nil
 	}
 
-	llog(store.log, "Started retrieving data from", p.bckup)
+	var err error
+	for _, path := range []string{p.bckup, p.prevPath()} {
+		llog(store.log, "Started retrieving data from", path)
 
-	osf, err := os.Open(p.bckup)
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		llog(store.log, err)
-		return err
+		var data map[string]Item
+		var version uint64
+		data, version, err = readSnapshot(path)
+		if errors.Is(err, os.ErrNotExist) {
+			llog(store.log, "No backup found at", path)
+			continue
+		}
+		if err != nil {
+			llog(store.log, "Refusing to load", path, ":", err)
+			continue
+		}
+
+		store.Lock()
+		store.data = data
+		store.version = version
+		store.Unlock()
+
+		llog(store.log, "Completed data retrieval from", path)
+		return nil
 	}
 
-	if err = load(store, osf); err != nil {
-		llog(store.log, err)
-		osf.Close()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return err
 	}
 
-	if err = osf.Close(); err != nil {
-		llog(store.log, err)
-	}
+	return nil
+}
 
-	llog(store.log, "Completed data retrieval from", p.bckup)
-	return err
+// prevPath returns the path of the previous generation of the backup
+func (p *persistor) prevPath() string {
+	return p.bckup + ".prev"
 }
 
 /////////////////////////// HELPER FUNCTIONS //////////////////////////
 
+// snapshotTrailerMagic marks the end of a checksummed json
+// snapshot. The trailer consists of this magic followed by the
+// CRC32 of the data. Such snapshots are no longer written but
+// are still read for migration
+var snapshotTrailerMagic = []byte("RSUM")
+
+// snapshotTrailerSize is the size of the trailer of a json snapshot
+const snapshotTrailerSize = 8
+
 // save is the internal function which encodes the
-// store data as json
+// store data in the binary snapshot format
+//
+// The write-ahead log is rotated while the data is captured so
+// that the rotated log holds exactly the mutations in the snapshot
 func save(store *Store, w io.Writer) (err error) {
 	defer func() {
 		if x := recover(); x != nil {
@@ -129,30 +281,75 @@ func save(store *Store, w io.Writer) (err error) {
 	}()
 
 	store.RLock()
-	b, err := json.Marshal(store.data)
+	b, err := encodeSnapshot(store.data, store.version)
+	if err == nil {
+		err = store.wal.rotate()
+	}
 	store.RUnlock()
 
+	if err != nil {
+		return err
+	}
+
 	_, err = w.Write(b)
 	return err
 }
 
-// load decodes the json data
-func load(store *Store, r io.Reader) error {
-	data := make(map[string]Item)
+// readSnapshot reads and verifies the snapshot at the given path
+func readSnapshot(path string) (map[string]Item, uint64, error) {
+	osf, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer osf.Close()
+
+	return load(osf)
+}
 
+// load verifies and decodes the snapshot, it returns the data and
+// the last version given to an item by the store
+//
+// Snapshots written in the json format are still accepted so
+// that the existing backups can be migrated. Json snapshots
+// written before checksums were introduced have no trailer
+// and are only validated by decoding them
+func load(r io.Reader) (map[string]Item, uint64, error) {
 	// Read into the bytes
 	b, err := read(r)
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+
+	if isBinarySnapshot(b) {
+		return decodeSnapshot(b)
 	}
+
+	if n := len(b) - snapshotTrailerSize; n >= 0 && bytes.Equal(b[n:n+4], snapshotTrailerMagic) {
+		if crc32.ChecksumIEEE(b[:n]) != binary.BigEndian.Uint32(b[n+4:]) {
+			return nil, 0, errors.New("Snapshot checksum mismatch")
+		}
+		b = b[:n]
+	}
+
 	// Unmarshal the data
-	err = json.Unmarshal(b, &data)
+	data := make(map[string]Item)
+	if err = json.Unmarshal(b, &data); err != nil {
+		return nil, 0, err
+	}
 
-	store.Lock()
-	store.data = data
-	store.Unlock()
+	return data, 0, nil
+}
 
-	return err
+// syncDir flushes the directory entry so that the
+// renames done inside the directory are durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
 }
 
 // read reads the data from the file
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistorSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bckup := filepath.Join(dir, "rapido.db")
+
+	ts := New(NeverExpire, nil, bckup)
+	ts.Set("k1", "v1", NeverExpire)
+
+	if err := ts.persistor.snapshot(ts); err != nil {
+		t.Fatal(err)
+	}
+	ts.wal.discardRotated()
+
+	ts.Set("k2", "v2", NeverExpire)
+
+	if err := ts.persistor.snapshot(ts); err != nil {
+		t.Fatal(err)
+	}
+	ts.wal.discardRotated()
+
+	// Both generations should be valid
+	for _, path := range []string{bckup, ts.persistor.prevPath()} {
+		if _, _, err := readSnapshot(path); err != nil {
+			t.Errorf("readSnapshot(%s) error = %v", path, err)
+		}
+	}
+
+	// Corrupt the current generation
+	b, err := ioutil.ReadFile(bckup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[2] ^= 0xff
+	if err := ioutil.WriteFile(bckup, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := readSnapshot(bckup); err == nil {
+		t.Error("readSnapshot() accepted a corrupted snapshot")
+	}
+
+	// The store must fall back to the previous generation
+	rs := New(NeverExpire, nil, bckup)
+
+	if got, _ := rs.Get("k1"); got != "v1" {
+		t.Errorf("Get(k1) = %v, want %v", got, "v1")
+	}
+	if got, ok := rs.Get("k2"); ok {
+		t.Errorf("Get(k2) = %v, want nothing from the previous generation", got)
+	}
+
+	// Corrupt the previous generation as well, the data in
+	// the memory must not be replaced
+	if err := ioutil.WriteFile(rs.persistor.prevPath(), b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.persistor.retrieve(rs); err == nil {
+		t.Error("retrieve() loaded corrupted snapshots")
+	}
+	if got, _ := rs.Get("k1"); got != "v1" {
+		t.Errorf("Get(k1) = %v, want %v after a failed retrieve", got, "v1")
+	}
+}
+
+func TestStoreClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bckup := filepath.Join(dir, "rapido.db")
+
+	ts := New(NeverExpire, nil, bckup)
+	ts.Set("k1", "v1", NeverExpire)
+
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing twice is a no-op
+	if err := ts.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The final snapshot should hold the data and the
+	// write-ahead log should be empty
+	data, _, err := readSnapshot(bckup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["k1"].Data != "v1" {
+		t.Errorf("Final snapshot = %v, want k1 = v1", data)
+	}
+
+	if fi, err := os.Stat(bckup + ".wal"); err != nil || fi.Size() != 0 {
+		t.Errorf("Expected an empty write-ahead log after close, got %v %v", fi, err)
+	}
+}
+
+func TestLegacyItemsVersioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bckup := filepath.Join(dir, "rapido.db")
+
+	// The json snapshots don't version the items
+	src := `{"k1":{"ExpireAt":0,"Data":"v1"},"k2":{"ExpireAt":0,"Data":"v2"}}`
+	if err := ioutil.WriteFile(bckup, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := New(NeverExpire, nil, bckup)
+	defer ts.Close()
+
+	_, v1, _ := ts.GetVersion("k1")
+	_, v2, _ := ts.GetVersion("k2")
+	if v1 == 0 || v2 == 0 || v1 == v2 {
+		t.Errorf("GetVersion() = %d and %d, want distinct versions", v1, v2)
+	}
+}
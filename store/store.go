@@ -2,6 +2,7 @@ package store
 
 import (
 	"log"
+	"runtime"
 	"sync"
 	"time"
 )
@@ -11,34 +12,80 @@ const (
 	// for not removing an item from the database
 	NeverExpire = 0
 
-	// This is the interval at which the janitor would be triggered
-	// It is hardcoded at the moment but shall be made configurable
-	// in the future
-	janitorInterval = 10 * time.Second
+	// DefaultJanitorInterval is the interval at which the janitor
+	// is triggered unless configured otherwise
+	DefaultJanitorInterval = 10 * time.Second
 
-	// Peristence interval will determin that at what intervals the
-	// the persistor will be triggered to store the data onto the disk
-	persistorInterval = 1 * time.Minute
+	// DefaultPersistorInterval determines at what intervals the
+	// persistor is triggered to store the data onto the disk
+	// unless configured otherwise
+	DefaultPersistorInterval = 1 * time.Minute
 )
 
+// Options holds the settings used to create a store
+type Options struct {
+	// DefaultExpiry is the expiry of the items stored
+	// without an explicit expiry
+	DefaultExpiry time.Duration
+
+	// JanitorInterval is the interval at which the expired
+	// items are removed. The janitor is disabled if it is zero
+	JanitorInterval time.Duration
+
+	// PersistorInterval is the interval at which the data is
+	// stored onto the disk. Persistence is disabled if it is zero
+	PersistorInterval time.Duration
+
+	// FsyncPolicy is the policy used to flush the write-ahead log
+	FsyncPolicy FsyncPolicy
+
+	// Backup is the path of the file where the data is persisted
+	// Persistence is disabled if it is empty
+	Backup string
+
+	// Log is the logger used by the store, it can be nil
+	Log *log.Logger
+}
+
 // Store struct encapsulates the store used by the database
 type Store struct {
 	sync.RWMutex
 	defaultExpiry time.Duration
 	data          map[string]Item
+	version       uint64
 	janitor       *janitor
 	persistor     *persistor
+	wal           *wal
 	log           *log.Logger
+	closeOnce     sync.Once
 }
 
 // New returns a new store
 func New(defaultExpiry time.Duration, log *log.Logger, bckup string) *Store {
+	return NewWithOptions(Options{
+		DefaultExpiry:     defaultExpiry,
+		JanitorInterval:   DefaultJanitorInterval,
+		PersistorInterval: DefaultPersistorInterval,
+		FsyncPolicy:       FsyncEverySec,
+		Backup:            bckup,
+		Log:               log,
+	})
+}
+
+// NewWithOptions returns a new store created with the passed options
+func NewWithOptions(opts Options) *Store {
 	s := &Store{
-		defaultExpiry: defaultExpiry,
+		defaultExpiry: opts.DefaultExpiry,
 		data:          make(map[string]Item),
-		janitor:       newJanitor(janitorInterval),
-		persistor:     newPersistor(persistorInterval, bckup),
-		log:           log,
+		janitor:       newJanitor(opts.JanitorInterval),
+		persistor:     newPersistor(opts.PersistorInterval, opts.Backup),
+		log:           opts.Log,
+	}
+
+	// Every mutation is recorded in the write-ahead
+	// log kept right next to the backup file
+	if opts.Backup != "" && opts.PersistorInterval > 0 {
+		s.wal = newWAL(opts.Backup+".wal", opts.FsyncPolicy)
 	}
 
 	// Setup janitor for this store
@@ -51,14 +98,38 @@ func New(defaultExpiry time.Duration, log *log.Logger, bckup string) *Store {
 }
 
 // Set adds an entry to the map with the corresponding key and data
+// The item expires after expireIn, the default expiry of the store
+// is used if expireIn is NeverExpire
 func (store *Store) Set(key string, data interface{}, expireIn time.Duration) {
 	// Lock the map
 	store.Lock()
-	store.data[key] = newItem(data, expireIn)
+	item := store.stamp(newItem(data, store.expiry(expireIn)))
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
 	// Unlock the map
 	store.Unlock()
 }
 
+// SetIf is like Set except that the data is only stored if cond returns
+// true. cond is called with the store locked so the check and the write
+// are atomic, get returns the data of the items like Get
+//
+// SetIf returns true if the data has been stored
+func (store *Store) SetIf(key string, data interface{}, expireIn time.Duration, cond func(get func(key string) (interface{}, bool)) bool) bool {
+	store.Lock()
+	defer store.Unlock()
+
+	if !cond(store.get) {
+		return false
+	}
+
+	item := store.stamp(newItem(data, store.expiry(expireIn)))
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
+
+	return true
+}
+
 // Get returns the data stored corresponding to the given key
 // if the data is not found then it returns nil
 func (store *Store) Get(key string) (interface{}, bool) {
@@ -74,6 +145,21 @@ func (store *Store) Get(key string) (interface{}, bool) {
 	return item.Data, true
 }
 
+// Keys returns the keys of every item in the store which hasn't expired
+func (store *Store) Keys() []string {
+	store.RLock()
+	defer store.RUnlock()
+
+	keys := make([]string, 0, len(store.data))
+	for k, v := range store.data {
+		if !v.isExpired() {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
 // Delete method deletes a key from the store. If the key doesn't exists
 // then it's a no-op
 //
@@ -94,6 +180,7 @@ func (store *Store) Delete(key string) (interface{}, bool) {
 		// delete function, the runtime doesn't crashes even
 		// if the key doesn't exists in the map
 		delete(store.data, key)
+		store.logErr(store.wal.append(walRecord{Op: walDelete, Key: key}))
 		store.Unlock()
 		return item.Data, ok
 	}
@@ -103,6 +190,188 @@ func (store *Store) Delete(key string) (interface{}, bool) {
 	return item.Data, ok
 }
 
+// DeleteIf is like Delete except that the keys are only deleted if cond
+// returns true, see SetIf. It returns the deleted items, which are nil
+// for the keys that didn't exist, and true if the keys have been deleted
+func (store *Store) DeleteIf(keys []string, cond func(get func(key string) (interface{}, bool)) bool) ([]interface{}, bool) {
+	store.Lock()
+	defer store.Unlock()
+
+	if !cond(store.get) {
+		return nil, false
+	}
+
+	deleted := make([]interface{}, len(keys))
+	for i, key := range keys {
+		item, ok := store.data[key]
+		if !ok {
+			continue
+		}
+
+		delete(store.data, key)
+		store.logErr(store.wal.append(walRecord{Op: walDelete, Key: key}))
+		deleted[i] = item.Data
+	}
+
+	return deleted, true
+}
+
+// GetVersion is like Get except that it also returns the version of
+// the item, which is 0 if the key doesn't exist
+func (store *Store) GetVersion(key string) (interface{}, uint64, bool) {
+	store.RLock()
+	defer store.RUnlock()
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return nil, 0, false
+	}
+
+	return item.Data, item.Version, true
+}
+
+// SetIfVersion is like Set except that the data is only stored if the
+// version of the item is the passed version, 0 meaning that the key
+// must not exist. The check and the write are atomic
+//
+// SetIfVersion returns the new version of the item and true if the
+// data has been stored
+func (store *Store) SetIfVersion(key string, version uint64, data interface{}, expireIn time.Duration) (uint64, bool) {
+	store.Lock()
+	defer store.Unlock()
+
+	if store.versionOf(key) != version {
+		return 0, false
+	}
+
+	item := store.stamp(newItem(data, store.expiry(expireIn)))
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
+
+	return item.Version, true
+}
+
+// DeleteIfVersion is like Delete except that the key is only deleted
+// if the version of the item is the passed version, see SetIfVersion.
+// It returns the deleted item, which is nil if the key didn't exist,
+// and true if the version matched
+func (store *Store) DeleteIfVersion(key string, version uint64) (interface{}, bool) {
+	store.Lock()
+	defer store.Unlock()
+
+	if store.versionOf(key) != version {
+		return nil, false
+	}
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return nil, true
+	}
+
+	delete(store.data, key)
+	store.logErr(store.wal.append(walRecord{Op: walDelete, Key: key}))
+
+	return item.Data, true
+}
+
+// get is like Get except that the store must be locked by the caller
+func (store *Store) get(key string) (interface{}, bool) {
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return nil, false
+	}
+
+	return item.Data, true
+}
+
+// expiry returns the expiry of an item stored with the passed one
+// which is the default expiry of the store if it is NeverExpire.
+// The store must be locked by the caller
+func (store *Store) expiry(expireIn time.Duration) time.Duration {
+	if expireIn == NeverExpire {
+		return store.defaultExpiry
+	}
+
+	return expireIn
+}
+
+// versionOf returns the version of the item, 0 if the key doesn't
+// exist. The store must be locked by the caller
+func (store *Store) versionOf(key string) uint64 {
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return 0
+	}
+
+	return item.Version
+}
+
+// stamp gives the next version to the item which is about to be
+// written. The store must be locked by the caller
+func (store *Store) stamp(item Item) Item {
+	store.version++
+	item.Version = store.version
+
+	return item
+}
+
+// versionLegacyItems gives a version to the items loaded from the
+// formats written before the items were versioned
+func (store *Store) versionLegacyItems() {
+	store.Lock()
+	defer store.Unlock()
+
+	for key, item := range store.data {
+		if item.Version == 0 {
+			store.data[key] = store.stamp(item)
+		}
+	}
+}
+
+// ExpireAt returns the time when the item expires, the zero time if it
+// never expires. It returns false if the key doesn't exist
+func (store *Store) ExpireAt(key string) (time.Time, bool) {
+	store.RLock()
+	defer store.RUnlock()
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return time.Time{}, false
+	}
+
+	return item.expireTime(), true
+}
+
+// SetExpireAt changes the time when the item expires, the zero time
+// keeps it forever and a time in the past deletes it right away. It
+// returns false if the key doesn't exist
+func (store *Store) SetExpireAt(key string, expireAt time.Time) bool {
+	store.Lock()
+	defer store.Unlock()
+
+	item, ok := store.data[key]
+	if !ok || item.isExpired() {
+		return false
+	}
+
+	if !expireAt.IsZero() && !expireAt.After(time.Now()) {
+		delete(store.data, key)
+		store.logErr(store.wal.append(walRecord{Op: walDelete, Key: key}))
+		return true
+	}
+
+	item.ExpireAt = NeverExpire
+	if !expireAt.IsZero() {
+		item.ExpireAt = expireAt.UnixNano()
+	}
+
+	item = store.stamp(item)
+	store.data[key] = item
+	store.logErr(store.wal.append(walRecord{walSet, key, item}))
+
+	return true
+}
+
 // DeleteExpired loops through the store and deletes
 // all the expired items
 func (store *Store) DeleteExpired() {
@@ -128,14 +397,64 @@ func (store *Store) DeleteExpired() {
 func (store *Store) Wipe() {
 	store.Lock()
 	store.data = make(map[string]Item)
+	store.logErr(store.wal.append(walRecord{Op: walWipe}))
 	store.Unlock()
 }
 
+// Close stops the janitor and the persistor of the store and writes
+// a final snapshot of the data onto the disk. The store must not be
+// used once it has been closed
+func (store *Store) Close() error {
+	var err error
+
+	store.closeOnce.Do(func() {
+		// The janitor is stopped here so the
+		// finalizer is no longer required
+		runtime.SetFinalizer(store, nil)
+		stopJanitor(store)
+
+		err = stopPersistor(store)
+	})
+
+	return err
+}
+
 // DefaultExpiry returns the default expiry of the store items
 func (store *Store) DefaultExpiry() time.Duration {
+	store.RLock()
+	defer store.RUnlock()
+
 	return store.defaultExpiry
 }
 
+// SetDefaultExpiry changes the default expiry of the store items
+// It only affects the items stored after the change, NeverExpire
+// keeps the items stored without an explicit expiry forever
+func (store *Store) SetDefaultExpiry(defaultExpiry time.Duration) {
+	store.Lock()
+	store.defaultExpiry = defaultExpiry
+	store.Unlock()
+}
+
+// SetFsyncPolicy changes the policy used to flush the write-ahead
+// log onto the disk. It is a no-op if the store isn't backed by a file
+func (store *Store) SetFsyncPolicy(policy FsyncPolicy) {
+	if store.wal == nil {
+		return
+	}
+
+	store.wal.Lock()
+	store.wal.policy = policy
+	store.wal.Unlock()
+}
+
+// logErr logs the error if it isn't nil
+func (store *Store) logErr(err error) {
+	if err != nil {
+		llog(store.log, err)
+	}
+}
+
 // llog takes in a pointer to the logger and the message to
 // be printed. If the logger is nil then it does nothing
 func llog(logger *log.Logger, cmd ...interface{}) {
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"math"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -82,6 +84,264 @@ func TestStore(t *testing.T) {
 	}
 }
 
+func TestStoreConditional(t *testing.T) {
+	ts := New(NeverExpire, nil, "")
+	ts.Set("k1", 1, NeverExpire)
+	ts.Set("expired", 1, -time.Second)
+
+	equals := func(key string, want interface{}) func(func(string) (interface{}, bool)) bool {
+		return func(get func(string) (interface{}, bool)) bool {
+			v, ok := get(key)
+			return ok && v == want
+		}
+	}
+
+	// The condition doesn't hold
+	if ts.SetIf("k2", 2, NeverExpire, equals("k1", 2)) {
+		t.Error("Set k2 even though k1 isn't 2")
+	}
+
+	// Expired items don't exist for the condition
+	if ts.SetIf("k2", 2, NeverExpire, equals("expired", 1)) {
+		t.Error("Set k2 even though the item is expired")
+	}
+
+	if !ts.SetIf("k2", 2, NeverExpire, equals("k1", 1)) {
+		t.Error("Didn't set k2 even though k1 is 1")
+	}
+
+	if v, ok := ts.Get("k2"); !ok || v != 2 {
+		t.Error("Expected k2 to be 2, got", v)
+	}
+
+	if _, ok := ts.DeleteIf([]string{"k1", "k2"}, equals("k1", 3)); ok {
+		t.Error("Deleted the keys even though k1 isn't 3")
+	}
+
+	deleted, ok := ts.DeleteIf([]string{"k1", "k2", "k3"}, equals("k2", 2))
+	if !ok || deleted[0] != 1 || deleted[1] != 2 || deleted[2] != nil {
+		t.Error("Expected the keys to be deleted, got", deleted)
+	}
+
+	if keys := ts.Keys(); len(keys) != 0 {
+		t.Error("Keys exist after being deleted", keys)
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	ts := New(NeverExpire, nil, "")
+	ts.Set("k1", 1, NeverExpire)
+	ts.Set("k2", 2, time.Hour)
+
+	if at, ok := ts.ExpireAt("k1"); !ok || !at.IsZero() {
+		t.Error("Expected k1 to never expire, got", at, ok)
+	}
+
+	if at, ok := ts.ExpireAt("k2"); !ok || time.Until(at) <= 59*time.Minute {
+		t.Error("Expected k2 to expire in an hour, got", at, ok)
+	}
+
+	if _, ok := ts.ExpireAt("k3"); ok {
+		t.Error("Found the expiry of k3 even though it doesn't exist")
+	}
+
+	// Make k1 expire and k2 persist
+	at := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	if !ts.SetExpireAt("k1", at) || !ts.SetExpireAt("k2", time.Time{}) {
+		t.Error("Couldn't change the expiry of k1 and k2")
+	}
+
+	if got, _ := ts.ExpireAt("k1"); !got.Equal(at) {
+		t.Error("Expected k1 to expire at", at, "got", got)
+	}
+
+	if got, _ := ts.ExpireAt("k2"); !got.IsZero() {
+		t.Error("Expected k2 to never expire, got", got)
+	}
+
+	if ts.SetExpireAt("k3", at) {
+		t.Error("Changed the expiry of k3 even though it doesn't exist")
+	}
+
+	// A time in the past deletes the key
+	if !ts.SetExpireAt("k1", time.Now().Add(-time.Second)) {
+		t.Error("Couldn't change the expiry of k1")
+	}
+
+	if v, ok := ts.Get("k1"); ok {
+		t.Error("k1 exists after its expiry", v)
+	}
+}
+
+func TestStoreDefaultExpiry(t *testing.T) {
+	ts := New(time.Hour, nil, "")
+	ts.Set("k1", 1, NeverExpire)
+	ts.SetIf("k2", 2, NeverExpire, func(func(string) (interface{}, bool)) bool { return true })
+	ts.SetIfVersion("k3", 0, 3, NeverExpire)
+	ts.Incr("k4", 4)
+	ts.Set("k5", 5, time.Minute)
+
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		if at, ok := ts.ExpireAt(key); !ok || time.Until(at) <= 59*time.Minute {
+			t.Error("Expected", key, "to expire in an hour, got", at, ok)
+		}
+	}
+
+	if at, _ := ts.ExpireAt("k5"); time.Until(at) > time.Minute {
+		t.Error("Expected k5 to expire in a minute, got", at)
+	}
+
+	// The items stored afterwards get the new default expiry
+	ts.SetDefaultExpiry(NeverExpire)
+	ts.Set("k1", 1, NeverExpire)
+
+	if at, _ := ts.ExpireAt("k1"); !at.IsZero() {
+		t.Error("Expected k1 to never expire, got", at)
+	}
+}
+
+func TestStoreVersions(t *testing.T) {
+	ts := New(NeverExpire, nil, "")
+	ts.Set("k1", "a", NeverExpire)
+	ts.Set("k2", "b", NeverExpire)
+
+	_, v1, _ := ts.GetVersion("k1")
+	_, v2, _ := ts.GetVersion("k2")
+	if v1 == 0 || v2 <= v1 {
+		t.Fatalf("Expected increasing versions, got %d and %d", v1, v2)
+	}
+
+	if _, v, ok := ts.GetVersion("missing"); ok || v != 0 {
+		t.Error("Expected the version of a missing key to be 0, got", v)
+	}
+
+	// The version must match
+	if _, ok := ts.SetIfVersion("k1", v2, "c", NeverExpire); ok {
+		t.Error("Set k1 even though its version doesn't match")
+	}
+
+	v3, ok := ts.SetIfVersion("k1", v1, "c", NeverExpire)
+	if !ok || v3 <= v2 {
+		t.Error("Expected k1 to be set with a new version, got", v3, ok)
+	}
+
+	if v, version, _ := ts.GetVersion("k1"); v != "c" || version != v3 {
+		t.Error("Expected k1 to be c at the version", v3, "got", v, version)
+	}
+
+	// The version 0 only matches a key which doesn't exist
+	if _, ok := ts.SetIfVersion("k1", 0, "d", NeverExpire); ok {
+		t.Error("Set k1 with the version 0 even though it exists")
+	}
+	if _, ok := ts.SetIfVersion("k3", 0, "d", NeverExpire); !ok {
+		t.Error("Couldn't create k3 with the version 0")
+	}
+
+	// Changing the expiry changes the version
+	ts.SetExpireAt("k2", time.Now().Add(time.Hour))
+	if _, v, _ := ts.GetVersion("k2"); v == v2 {
+		t.Error("Expected a new version of k2 after changing its expiry")
+	}
+
+	if _, ok := ts.DeleteIfVersion("k1", v1); ok {
+		t.Error("Deleted k1 even though its version doesn't match")
+	}
+	if v, ok := ts.DeleteIfVersion("k1", v3); !ok || v != "c" {
+		t.Error("Expected k1 to be deleted, got", v, ok)
+	}
+
+	// A key created again never gets an old version back
+	ts.Set("k1", "a", NeverExpire)
+	if _, v, _ := ts.GetVersion("k1"); v <= v3 {
+		t.Error("Expected a new version of k1, got", v)
+	}
+}
+
+func TestStoreCounters(t *testing.T) {
+	ts := New(NeverExpire, nil, "")
+	ts.Set("int", 5, NeverExpire)
+	ts.Set("string", "41", NeverExpire)
+	ts.Set("float", 1.5, NeverExpire)
+	ts.Set("text", "hello", NeverExpire)
+	ts.Set("max", int64(math.MaxInt64), NeverExpire)
+	ts.Set("max float", math.MaxFloat64, NeverExpire)
+	ts.Set("ttl", int64(1), time.Hour)
+
+	tests := []struct {
+		name    string
+		key     string
+		delta   int64
+		want    int64
+		wantErr error
+	}{
+		{"MISSING KEY", "missing", 1, 1, nil},
+		{"INT", "int", -10, -5, nil},
+		{"NUMERIC STRING", "string", 1, 42, nil},
+		{"EXPIRING KEY", "ttl", 2, 3, nil},
+		{"FLOAT", "float", 1, 0, ErrNotInteger},
+		{"STRING", "text", 1, 0, ErrNotInteger},
+		{"OVERFLOW", "max", 1, 0, ErrOverflow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ts.Incr(tt.key, tt.delta)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("Incr() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// The counters are stored as int64 and keep their expiry
+	if v, _ := ts.Get("string"); v != int64(42) {
+		t.Errorf("Get(string) = %#v, want int64(42)", v)
+	}
+	if at, _ := ts.ExpireAt("ttl"); at.IsZero() {
+		t.Error("Incr() removed the expiry of ttl")
+	}
+	if v, _ := ts.Get("text"); v != "hello" {
+		t.Errorf("Get(text) = %#v, want the value untouched", v)
+	}
+
+	floatTests := []struct {
+		name    string
+		key     string
+		delta   float64
+		want    float64
+		wantErr error
+	}{
+		{"MISSING KEY", "missing float", 0.5, 0.5, nil},
+		{"FLOAT", "float", 1.25, 2.75, nil},
+		{"INT", "int", 0.5, -4.5, nil},
+		{"STRING", "text", 1, 0, ErrNotNumber},
+		{"OVERFLOW", "max float", math.MaxFloat64, 0, ErrOverflow},
+	}
+	for _, tt := range floatTests {
+		t.Run("FLOAT "+tt.name, func(t *testing.T) {
+			got, err := ts.IncrFloat(tt.key, tt.delta)
+			if err != tt.wantErr || got != tt.want {
+				t.Errorf("IncrFloat() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	// Concurrent increments are never lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ts.Incr("concurrent", 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if v, _ := ts.Get("concurrent"); v != int64(1000) {
+		t.Errorf("Get(concurrent) = %v, want 1000", v)
+	}
+}
+
 func TestStoreJanitor(t *testing.T) {
 	// Create a store without using the new method
 	// to pass in a custom janitor interval
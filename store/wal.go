@@ -0,0 +1,450 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy determines how often the write-ahead log
+// is flushed from the OS buffers onto the disk
+type FsyncPolicy uint
+
+const (
+	// FsyncEverySec flushes the write-ahead log once every second
+	// if anything has been written to it since the last flush.
+	// This is the default policy
+	FsyncEverySec FsyncPolicy = iota
+
+	// FsyncAlways flushes the write-ahead log after every single
+	// mutation. This is the safest but also the slowest policy
+	FsyncAlways
+
+	// FsyncNever never flushes the write-ahead log explicitly and
+	// leaves it up to the operating system to do so
+	FsyncNever
+)
+
+// walOp represents the operation recorded in the write-ahead log
+type walOp uint8
+
+const (
+	walSet walOp = iota + 1
+	walDelete
+	walWipe
+)
+
+// walVersioned is set on the operation of the records whose entry
+// carries the version of the item, the records written before the
+// items were versioned don't have it
+const walVersioned = 0x80
+
+const (
+	// walSyncInterval is the interval at which the write-ahead log
+	// is flushed when FsyncEverySec policy is in use
+	walSyncInterval = 1 * time.Second
+
+	// walHeaderSize is the size of the header of every record
+	// which consists of the payload length and its checksum
+	walHeaderSize = 8
+)
+
+// ParseFsyncPolicy converts the passed string into a FsyncPolicy.
+// Valid values are "always", "everysec" and "never"
+func ParseFsyncPolicy(policy string) (FsyncPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec":
+		return FsyncEverySec, nil
+	case "never":
+		return FsyncNever, nil
+	default:
+		return FsyncEverySec, fmt.Errorf("Invalid fsync policy %q, valid values are always, everysec and never", policy)
+	}
+}
+
+// String returns the string representation of the FsyncPolicy
+func (p FsyncPolicy) String() string {
+	switch p {
+	case FsyncAlways:
+		return "always"
+	case FsyncNever:
+		return "never"
+	default:
+		return "everysec"
+	}
+}
+
+// walRecord is a single entry of the write-ahead log
+type walRecord struct {
+	Op   walOp
+	Key  string
+	Item Item
+}
+
+// wal is an append-only log of every mutation performed on the
+// store. It is replayed on top of the last snapshot on startup
+// so that the mutations done after the last snapshot are not lost
+type wal struct {
+	sync.Mutex
+	path    string
+	file    *os.File
+	policy  FsyncPolicy
+	dirty   bool
+	sigStop chan bool
+}
+
+// newWAL returns a pointer to a new instance of the write-ahead log
+// the log file is not opened until open is called on it
+func newWAL(path string, policy FsyncPolicy) *wal {
+	return &wal{path: path, policy: policy, sigStop: make(chan bool)}
+}
+
+// setupWAL replays the write-ahead log of the store (if any) on
+// top of the already loaded snapshot and then opens the log for
+// appending new mutations
+func setupWAL(store *Store) {
+	if store.wal == nil {
+		return
+	}
+
+	// Replay the log left behind by an interrupted snapshot first
+	// and then the current one
+	for _, path := range []string{store.wal.rotatedPath(), store.wal.path} {
+		if err := replay(store, path); err != nil {
+			llog(store.log, err)
+		}
+	}
+
+	if err := store.wal.open(); err != nil {
+		llog(store.log, "Failed to open the write-ahead log:", err)
+		store.wal = nil
+		return
+	}
+
+	go store.wal.run()
+}
+
+// open opens the log file for appending
+func (w *wal) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	w.file = f
+	return nil
+}
+
+// rotatedPath returns the path where the log is moved while
+// a snapshot covering its records is being written
+func (w *wal) rotatedPath() string {
+	return w.path + ".old"
+}
+
+// run flushes the log at regular intervals when
+// FsyncEverySec policy is in use
+func (w *wal) run() {
+	ticker := time.NewTicker(walSyncInterval)
+	for {
+		select {
+		case <-ticker.C:
+			w.Lock()
+			if w.dirty && w.policy == FsyncEverySec && w.file != nil {
+				w.file.Sync()
+				w.dirty = false
+			}
+			w.Unlock()
+		case <-w.sigStop:
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+// close stops flushing the log at regular intervals, flushes it one
+// last time and closes the log file. It is a no-op if the log is nil
+func (w *wal) close() error {
+	if w == nil {
+		return nil
+	}
+
+	close(w.sigStop)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+
+	return err
+}
+
+// append writes the record at the end of the log and flushes it
+// according to the fsync policy. It is a no-op if the log is nil
+//
+// append is supposed to be called while holding the lock of the
+// store so that the order of the records matches the order of
+// the mutations
+func (w *wal) append(rec walRecord) error {
+	if w == nil {
+		return nil
+	}
+
+	payload, err := appendEntry([]byte{byte(rec.Op) | walVersioned}, rec.Key, rec.Item)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walHeaderSize:], payload)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return errors.New("Write-ahead log is not open")
+	}
+
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+
+	if w.policy == FsyncAlways {
+		return w.file.Sync()
+	}
+
+	w.dirty = true
+	return nil
+}
+
+// rotate moves the current log aside and starts a fresh one. It
+// is supposed to be called while holding the lock of the store,
+// right when the data for a snapshot is captured, so that the
+// moved log contains exactly the records covered by the snapshot
+func (w *wal) rotate() error {
+	if w == nil {
+		return nil
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file != nil {
+		w.file.Sync()
+		w.file.Close()
+		w.file = nil
+	}
+
+	if _, err := os.Stat(w.rotatedPath()); err == nil {
+		// The last snapshot failed and its log is still around,
+		// keep its records as the next snapshot has to cover both
+		if err := appendFile(w.rotatedPath(), w.path); err != nil {
+			return err
+		}
+		if err := os.Remove(w.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	} else if err := os.Rename(w.path, w.rotatedPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	w.dirty = false
+	return w.open()
+}
+
+// appendFile appends the contents of the src file at the end of the
+// dst file. A missing src file is treated as an empty one
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// discardRotated removes the log moved aside by rotate. It must
+// only be called once the snapshot covering it is on the disk
+func (w *wal) discardRotated() error {
+	if w == nil {
+		return nil
+	}
+
+	err := os.Remove(w.rotatedPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// replay applies every valid record in the log at the given path
+// onto the store. A torn or corrupted tail, which is what a crash
+// in the middle of an append leaves behind, ends the replay and
+// is cut off from the file
+func replay(store *Store, path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	llog(store.log, "Replaying write-ahead log", path)
+
+	r := bufio.NewReader(f)
+	var offset int64
+	applied := 0
+
+	store.Lock()
+	defer store.Unlock()
+
+	for {
+		rec, n, err := readRecord(r, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			llog(store.log, "Discarding corrupted tail of the write-ahead log at offset", offset, ":", err)
+			if err := f.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		applyRecord(store, rec)
+		offset += n
+		applied++
+	}
+
+	llog(store.log, "Replayed", applied, "records from", path)
+	return nil
+}
+
+// readRecord reads the next record from the log, of which at most
+// left bytes remain. It returns io.EOF only if the log ends exactly
+// at a record boundary
+func readRecord(r io.Reader, left int64) (walRecord, int64, error) {
+	var rec walRecord
+
+	header := make([]byte, walHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return rec, 0, io.EOF
+	}
+	if err != nil {
+		return rec, int64(n), fmt.Errorf("Incomplete record header: %w", err)
+	}
+
+	// The length of a torn or corrupted header can be anything, it
+	// mustn't be trusted before it is known to fit in the log
+	size := binary.BigEndian.Uint32(header[0:4])
+	if int64(size) > left-walHeaderSize {
+		return rec, 0, fmt.Errorf("Record length %d exceeds the log", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return rec, 0, fmt.Errorf("Incomplete record: %w", err)
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, 0, errors.New("Checksum mismatch")
+	}
+
+	if err := decodeRecord(payload, &rec); err != nil {
+		return rec, 0, err
+	}
+
+	return rec, int64(walHeaderSize + len(payload)), nil
+}
+
+// decodeRecord decodes the payload of a record. A record starts with
+// the operation followed by the entry in the snapshot encoding. Logs
+// written before the binary encoding was introduced hold json records
+func decodeRecord(payload []byte, rec *walRecord) error {
+	if len(payload) > 0 && payload[0] == '{' {
+		return json.Unmarshal(payload, rec)
+	}
+
+	if len(payload) == 0 {
+		return errors.New("Empty record")
+	}
+
+	versioned := payload[0]&walVersioned != 0
+
+	key, item, err := readEntry(bytes.NewReader(payload[1:]), versioned)
+	if err != nil {
+		return err
+	}
+
+	rec.Op, rec.Key, rec.Item = walOp(payload[0]&^walVersioned), key, item
+	return nil
+}
+
+// applyRecord applies the record onto the data of the store
+// without taking any locks or writing to the log
+func applyRecord(store *Store, rec walRecord) {
+	switch rec.Op {
+	case walSet:
+		// The versions of the expired items aren't given again either
+		if rec.Item.Version > store.version {
+			store.version = rec.Item.Version
+		}
+		if rec.Item.isExpired() {
+			delete(store.data, rec.Key)
+			return
+		}
+		store.data[rec.Key] = rec.Item
+	case walDelete:
+		delete(store.data, rec.Key)
+	case walWipe:
+		store.data = make(map[string]Item)
+	}
+}
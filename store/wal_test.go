@@ -0,0 +1,125 @@
+package store
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWALReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rapido")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	bckup := filepath.Join(dir, "rapido.db")
+
+	ts := New(NeverExpire, nil, bckup)
+	ts.SetFsyncPolicy(FsyncAlways)
+
+	ts.Set("k1", "v1", NeverExpire)
+	ts.Set("k2", "v2", NeverExpire)
+	ts.Delete("k1")
+	ts.Wipe()
+	ts.Set("k3", "v3", NeverExpire)
+	ts.Set("k4", "v4", NeverExpire)
+	ts.Set("k6", "v6", NeverExpire)
+	ts.SetExpireAt("k3", time.Now().Add(time.Hour))
+	ts.SetExpireAt("k6", time.Now().Add(-time.Second))
+	ts.Incr("k7", 5)
+	ts.Set("k8", "v8", NeverExpire)
+	ts.Delete("k8")
+	_, version, _ := ts.GetVersion("k4")
+
+	// Simulate a crash in the middle of an append
+	f, err := os.OpenFile(bckup+".wal", os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 1})
+	f.Close()
+
+	// A new store over the same backup should replay the log
+	rs := New(NeverExpire, nil, bckup)
+
+	for key, want := range map[string]interface{}{"k1": nil, "k2": nil, "k3": "v3", "k4": "v4", "k6": nil, "k7": int64(5)} {
+		got, _ := rs.Get(key)
+		if got != want {
+			t.Errorf("Get(%s) = %v, want %v", key, got, want)
+		}
+	}
+
+	if at, _ := rs.ExpireAt("k3"); at.IsZero() {
+		t.Error("ExpireAt(k3) is zero, want the expiry set before the crash")
+	}
+
+	if _, v, _ := rs.GetVersion("k4"); v != version {
+		t.Errorf("GetVersion(k4) = %d, want %d", v, version)
+	}
+
+	// The versions given before the crash, even to the deleted
+	// keys, aren't given again
+	rs.Set("k8", "v8", NeverExpire)
+	if _, v, _ := rs.GetVersion("k8"); v <= version+4 {
+		t.Errorf("GetVersion(k8) = %d, want more than %d", v, version+4)
+	}
+
+	// Writes after the torn tail must survive another replay
+	rs.SetFsyncPolicy(FsyncAlways)
+	rs.Set("k5", "v5", NeverExpire)
+
+	rs2 := New(NeverExpire, nil, bckup)
+	if got, _ := rs2.Get("k5"); got != "v5" {
+		t.Errorf("Get(k5) = %v, want %v", got, "v5")
+	}
+}
+
+func TestReadRecordLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+		left   int64
+	}{
+		{"READ LENGTH BEYOND THE LOG", []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}, 16},
+		{"READ LENGTH OF THE HEADER ONLY", []byte{0, 0, 0, 1, 0, 0, 0, 0}, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(append(tt.header, make([]byte, tt.left-walHeaderSize)...))
+			if _, _, err := readRecord(r, tt.left); err == nil || err == io.EOF {
+				t.Errorf("readRecord() error = %v, want the record to be rejected", err)
+			}
+		})
+	}
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		want    FsyncPolicy
+		wantErr bool
+	}{
+		{"PARSE ALWAYS", "always", FsyncAlways, false},
+		{"PARSE MIXED CASE EVERYSEC", "EverySec", FsyncEverySec, false},
+		{"PARSE NEVER", "never", FsyncNever, false},
+		{"PARSE INVALID POLICY", "sometimes", FsyncEverySec, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFsyncPolicy(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFsyncPolicy() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ParseFsyncPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
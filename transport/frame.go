@@ -0,0 +1,157 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The binary protocol is negotiated by sending "PROTOCOL binary" in the
+// text or JSON protocol, the confirmation is the first frame sent by the
+// server. Every later message is a frame, the integers are big endian
+//
+//	request  := uint32 id, uint32 length, body
+//	body     := string query, uint32 count, string args...
+//	string   := uint32 length, bytes
+//	response := uint32 id, uint8 type, uint32 length, payload
+//
+// The "?" placeholders of the query are replaced by the args, which can
+// hold any bytes. A client can send several requests without waiting for
+// their responses, the requests are executed one after the other and
+// each response carries the id of its request. The payload of the
+// responses of type frameResults are the results encoded by the driver,
+// the payload of the other types is text. The events and the notices
+// of the server are sent with the request id 0
+
+// DefaultMaxRequestSize is the default maximum size of a request
+const DefaultMaxRequestSize = 64 << 20
+
+// Types of the response frames
+const (
+	frameResults byte = iota
+	frameMessage
+	frameError
+)
+
+// errRequestTooLarge is returned by readFrame and readLine if the
+// client sent a request larger than the maximum request size
+var errRequestTooLarge = errors.New("Request too large")
+
+// readFrame reads the next request frame and executes it. It returns
+// false if the client has been closed. The connection is closed if
+// the request is larger than the maximum request size
+func (c *Client) readFrame() (bool, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, err
+	}
+
+	id := binary.BigEndian.Uint32(header[:4])
+	size := binary.BigEndian.Uint32(header[4:])
+
+	if max := c.maxRequest(); max > 0 && uint64(size) > uint64(max) {
+		c.writeFrame(id, frameError, fmt.Sprintf("Request of %d bytes exceeds the maximum of %d bytes", size, max))
+		c.conn.Close()
+		return false, errRequestTooLarge
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return false, err
+	}
+
+	query, args, err := decodeRequest(body)
+	if err != nil {
+		c.writeFrame(id, frameError, err.Error())
+		return !c.isClosed(), nil
+	}
+
+	return c.execFrame(id, query, args), nil
+}
+
+// execFrame passes the request to the driver and sends back the results.
+// It returns false if the client has been closed in the meantime
+func (c *Client) execFrame(id uint32, query string, args []string) bool {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	if name, ok := protocolCommand(query); ok && len(args) == 0 {
+		c.setProtocol(name)
+		return true
+	}
+
+	res := c.driver.OperateBinary(query, args)
+
+	c.writeMu.Lock()
+	c.conn.Write(encodeFrame(id, frameResults, res))
+	c.writeMu.Unlock()
+
+	return true
+}
+
+// writeFrame writes the text frame of the request id on the connection
+func (c *Client) writeFrame(id uint32, typ byte, msg string) {
+	c.writeMu.Lock()
+	c.conn.Write(encodeFrame(id, typ, []byte(msg)))
+	c.writeMu.Unlock()
+}
+
+// encodeFrame returns the response frame of the request id
+func encodeFrame(id uint32, typ byte, payload []byte) []byte {
+	b := make([]byte, 9, 9+len(payload))
+	binary.BigEndian.PutUint32(b, id)
+	b[4] = typ
+	binary.BigEndian.PutUint32(b[5:], uint32(len(payload)))
+
+	return append(b, payload...)
+}
+
+// decodeRequest returns the query and the args of the body of a request
+func decodeRequest(body []byte) (string, []string, error) {
+	errMalformed := errors.New("Malformed request")
+
+	next := func() (string, bool) {
+		if len(body) < 4 {
+			return "", false
+		}
+		n := binary.BigEndian.Uint32(body)
+		body = body[4:]
+		if uint64(n) > uint64(len(body)) {
+			return "", false
+		}
+		s := string(body[:n])
+		body = body[n:]
+		return s, true
+	}
+
+	query, ok := next()
+	if !ok || len(body) < 4 {
+		return "", nil, errMalformed
+	}
+
+	count := binary.BigEndian.Uint32(body)
+	body = body[4:]
+
+	// Every arg is at least 4 bytes long
+	if uint64(count)*4 > uint64(len(body)) {
+		return "", nil, errMalformed
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if args[i], ok = next(); !ok {
+			return "", nil, errMalformed
+		}
+	}
+
+	if len(body) != 0 {
+		return "", nil, errMalformed
+	}
+
+	return query, args, nil
+}
@@ -2,30 +2,85 @@ package transport
 
 import (
 	"bufio"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // TranslationDriver interface demands an object which
 // has an Operate method, it takes in string as input
 // and returns nothing
+//
+// OperateJSON is used by the clients which switched to the
+// JSON protocol, it returns the results as a JSON document.
+// OperateBinary is used by the clients which switched to the
+// binary protocol, it replaces the placeholders of the command
+// by the args and returns the results in a binary safe encoding
 type TranslationDriver interface {
 	Operate(cmd string) (string, error)
+	OperateJSON(cmd string) string
+	OperateBinary(cmd string, args []string) []byte
 }
 
+// Protocols the clients can switch to using "PROTOCOL <name>"
+const (
+	// ProtocolText sends the results as plain text lines and
+	// the errors prefixed by "ERR: ", it is the default
+	ProtocolText = "text"
+
+	// ProtocolJSON sends every response as a JSON document on a line
+	// of its own. The results of the commands are sent as
+	// {"results":[...]}, the messages like the events as
+	// {"message":"..."} and the errors as {"error":"..."}
+	ProtocolJSON = "json"
+
+	// ProtocolBinary exchanges length-prefixed frames carrying a
+	// request id, see the frame.go file for the format of the frames
+	ProtocolBinary = "binary"
+)
+
 // Client represents an active TCP client communicating
 // with the server
 type Client struct {
+	// maxRequestSize is the maximum size of a request, it is
+	// accessed atomically so it comes first to be 64-bit aligned
+	maxRequestSize int64
+
 	conn   net.Conn
+	r      *bufio.Reader
 	log    *log.Logger
 	driver TranslationDriver
+
+	// execMu is held while a command is being executed
+	// so that closing the client waits for it to finish
+	execMu sync.Mutex
+
+	// writeMu serializes the writes on the connection
+	writeMu sync.Mutex
+
+	// closed is set once the client has been closed
+	closed bool
+
+	// protocol is the protocol used by the client,
+	// it is guarded by writeMu
+	protocol string
 }
 
 // New returns a new client instance
 func New(conn net.Conn, l *log.Logger, d TranslationDriver) *Client {
-	c := &Client{conn, l, d}
+	c := &Client{
+		conn:           conn,
+		r:              bufio.NewReader(conn),
+		log:            l,
+		driver:         d,
+		maxRequestSize: DefaultMaxRequestSize,
+		protocol:       ProtocolText,
+	}
 
 	// Send the message to the client
 	c.Msg("Successfully connected to RapidoDB. Please run AUTH <user> <pass> to access the DB")
@@ -33,15 +88,33 @@ func New(conn net.Conn, l *log.Logger, d TranslationDriver) *Client {
 	return c
 }
 
+// SetMaxRequestSize sets the maximum size in bytes of the requests,
+// the lines of the text and JSON protocols or the frames of the binary
+// protocol, a client sending a larger request is disconnected. It can
+// be called while the client is served
+func (c *Client) SetMaxRequestSize(n int) {
+	atomic.StoreInt64(&c.maxRequestSize, int64(n))
+}
+
+// maxRequest returns the maximum size of a request
+func (c *Client) maxRequest() int {
+	return int(atomic.LoadInt64(&c.maxRequestSize))
+}
+
 // InitRead reads the input of the TCP clients and passes on the received command to the driver
 // after trimming the received command
 func (c *Client) InitRead() {
 	for {
-		// Read data from TCP client and parse it
-		cmd, err := bufio.NewReader(c.conn).ReadString('\n')
+		// Read data from TCP client and execute it
+		ok, err := c.read()
 
 		// Check for errors
 		if err != nil {
+			// The connection was closed on purpose
+			if c.isClosed() {
+				return
+			}
+
 			// If error is io.EOF then it indicates that the client has
 			// disconnected and hence closing the connection here
 			if err == io.EOF {
@@ -55,25 +128,182 @@ func (c *Client) InitRead() {
 			return
 		}
 
-		// Trim the data
-		cmd = strings.TrimSpace(cmd)
+		if !ok {
+			return
+		}
+	}
+}
 
-		// Pass the command to the driver
-		res, err := c.driver.Operate(cmd)
-		if err != nil {
-			c.Err(err)
-			continue
+// read reads the next command in the protocol used by the client and
+// executes it. It returns false if the client has been closed
+func (c *Client) read() (bool, error) {
+	if c.protocolName() == ProtocolBinary {
+		return c.readFrame()
+	}
+
+	cmd, err := c.readLine()
+	if err != nil {
+		return false, err
+	}
+
+	// Trim the data
+	return c.exec(strings.TrimSpace(cmd)), nil
+}
+
+// readLine reads the next line of the text and JSON protocols. The
+// connection is closed if the line is larger than the maximum request
+// size, which is checked before the line is read any further
+func (c *Client) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := c.r.ReadSlice('\n')
+		if max := c.maxRequest(); max > 0 && len(line)+len(b) > max {
+			c.Err(fmt.Errorf("Request exceeds the maximum of %d bytes", max))
+			c.conn.Close()
+			return "", errRequestTooLarge
+		}
+		line = append(line, b...)
+
+		if err != bufio.ErrBufferFull {
+			return string(line), err
 		}
-		c.Msg(res)
 	}
 }
 
+// exec passes the command to the driver and sends back the result.
+// It returns false if the client has been closed in the meantime
+func (c *Client) exec(cmd string) bool {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	if name, ok := protocolCommand(cmd); ok {
+		c.setProtocol(name)
+		return true
+	}
+
+	if c.protocolName() == ProtocolJSON {
+		c.writeLine(c.driver.OperateJSON(cmd))
+		return true
+	}
+
+	// Pass the command to the driver
+	res, err := c.driver.Operate(cmd)
+	if err != nil {
+		c.Err(err)
+		return true
+	}
+	c.Msg(res)
+
+	return true
+}
+
+// Close waits for the command being executed (if any) to finish,
+// sends the notice to the client and closes the connection. No
+// more commands are executed once the client has been closed
+func (c *Client) Close(notice string) error {
+	c.execMu.Lock()
+	defer c.execMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.writeMu.Lock()
+	c.closed = true
+	c.writeMu.Unlock()
+
+	if notice != "" {
+		c.Msg(notice)
+	}
+
+	return c.conn.Close()
+}
+
+// isClosed returns true if the client has been closed
+func (c *Client) isClosed() bool {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.closed
+}
+
 // Msg sends a message to the client
 func (c *Client) Msg(msg string) {
-	c.conn.Write([]byte(msg + "\n"))
+	c.write("", "message", msg)
 }
 
 // Err sends an error message to the client
 func (c *Client) Err(err error) {
-	c.conn.Write([]byte("ERR: " + err.Error() + "\n"))
+	c.write("ERR: ", "error", err.Error())
+}
+
+// write writes the prefixed message on the connection, the message
+// as the field of a JSON document in the JSON protocol or as a frame
+// of the request id 0 in the binary protocol
+func (c *Client) write(prefix, field, msg string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	switch c.protocol {
+	case ProtocolJSON:
+		b, _ := json.Marshal(map[string]string{field: msg})
+		c.conn.Write(append(b, '\n'))
+		return
+	case ProtocolBinary:
+		typ := frameMessage
+		if field == "error" {
+			typ = frameError
+		}
+		c.conn.Write(encodeFrame(0, typ, []byte(msg)))
+		return
+	}
+
+	c.conn.Write([]byte(prefix + msg + "\n"))
+}
+
+// writeLine writes the line as it is on the connection
+func (c *Client) writeLine(line string) {
+	c.writeMu.Lock()
+	c.conn.Write([]byte(line + "\n"))
+	c.writeMu.Unlock()
+}
+
+// setProtocol switches the client to the protocol, the
+// confirmation is sent in the protocol switched to
+func (c *Client) setProtocol(name string) {
+	switch name {
+	case ProtocolText, ProtocolJSON, ProtocolBinary:
+	default:
+		c.Err(fmt.Errorf("Unknown protocol %s, valid protocols are text, json and binary", name))
+		return
+	}
+
+	c.writeMu.Lock()
+	c.protocol = name
+	c.writeMu.Unlock()
+
+	c.Msg("Protocol " + name)
+}
+
+// protocolName returns the protocol used by the client
+func (c *Client) protocolName() string {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.protocol
+}
+
+// protocolCommand returns the name of the protocol if the
+// command is "PROTOCOL <name>", with an optional semicolon
+func protocolCommand(cmd string) (string, bool) {
+	fields := strings.Fields(strings.TrimSuffix(cmd, ";"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "PROTOCOL") {
+		return "", false
+	}
+
+	return strings.ToLower(fields[1]), true
 }
@@ -22,3 +22,21 @@ func PingClient(c ClientConn, eb *eventbus.EventBus, events ...string) {
 		}
 	}(muxcd)
 }
+
+// EventConn interface describes the clients which
+// are sent the events along with their keys and values
+type EventConn interface {
+	Event(event, key string, value interface{})
+}
+
+// PushEvents subscribes to the events on the event bus
+// and automatically pushes them to the client as they are
+func PushEvents(c EventConn, eb *eventbus.EventBus, events ...string) {
+	muxcd := eventbus.ChannelMultiplexer(eb, 0, events...)
+
+	go func(ch eventbus.DataChannel) {
+		for msg := range ch {
+			c.Event(msg.Event(), msg.Key(), msg.Value())
+		}
+	}(muxcd)
+}